@@ -25,6 +25,26 @@ type Config struct {
 	TwilioAuthToken        string
 	TwilioPhoneNumber      string
 	OTPLifetimeMinutes     int
+	MaxLoginAttempts       int
+	LoginLockoutMinutes    int
+	ImpersonationLifetimeMinutes int
+	UploadDir              string
+	ExportDir              string
+	PrivateUploadDir       string
+	DataExportRetentionHours int
+	AccountDeletionGraceDays int
+	DefaultTimeZone        string
+	VisitReminderMinutes   int
+	ContactProxyNumber     string
+	ContactProxySecret     string
+	ContactProxyHours      int
+	ContactRevealsPerDay   int
+	ListingReportThreshold int
+	RentalLetArchiveDays   int
+	PriceDropDays          int
+	DraftRetentionDays     int
+	ExpiryNoticeDays       int
+	TrustedProxies         string
 }
 
 
@@ -45,6 +65,26 @@ func LoadConfig() *Config {
 		TwilioAuthToken:        getSecureEnv("TWILIO_AUTH_TOKEN"),
 		TwilioPhoneNumber:      getSecureEnv("TWILIO_PHONE_NUMBER"),
 		OTPLifetimeMinutes:     parseIntEnv("OTP_LIFETIME_MINUTES",2),
+		MaxLoginAttempts:       parseIntEnv("MAX_LOGIN_ATTEMPTS", 5),
+		LoginLockoutMinutes:    parseIntEnv("LOGIN_LOCKOUT_MINUTES", 15),
+		ImpersonationLifetimeMinutes: parseIntEnv("IMPERSONATION_LIFETIME_MINUTES", 15),
+		UploadDir:              getEnv("UPLOAD_DIR", "./uploads"),
+		ExportDir:              getEnv("EXPORT_DIR", "./exports"),
+		PrivateUploadDir:       getEnv("PRIVATE_UPLOAD_DIR", "./private_uploads"),
+		DataExportRetentionHours: parseIntEnv("DATA_EXPORT_RETENTION_HOURS", 72),
+		AccountDeletionGraceDays: parseIntEnv("ACCOUNT_DELETION_GRACE_DAYS", 30),
+		DefaultTimeZone:        getEnv("DEFAULT_TIME_ZONE", "Asia/Kolkata"),
+		VisitReminderMinutes:   parseIntEnv("VISIT_REMINDER_MINUTES", 120),
+		ContactProxyNumber:     getEnv("CONTACT_PROXY_NUMBER", os.Getenv("TWILIO_PHONE_NUMBER")),
+		ContactProxySecret:     getSecureEnv("CONTACT_PROXY_SECRET"),
+		ContactProxyHours:      parseIntEnv("CONTACT_PROXY_HOURS", 168),
+		ContactRevealsPerDay:   parseIntEnv("CONTACT_REVEALS_PER_DAY", 10),
+		ListingReportThreshold: parseIntEnv("LISTING_REPORT_THRESHOLD", 3),
+		RentalLetArchiveDays:   parseIntEnv("RENTAL_LET_ARCHIVE_DAYS", 14),
+		PriceDropDays:          parseIntEnv("PRICE_DROP_DAYS", 14),
+		DraftRetentionDays:     parseIntEnv("DRAFT_RETENTION_DAYS", 30),
+		ExpiryNoticeDays:       parseIntEnv("LISTING_EXPIRY_NOTICE_DAYS", 3),
+		TrustedProxies:         os.Getenv("TRUSTED_PROXIES"), // comma separated IPs or CIDR ranges of the reverse proxies
 	}
 	logrus.Info("Configuration successfully loaded")
 	})
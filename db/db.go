@@ -108,3 +108,228 @@ func GetRefreshTokenCollection() *mongo.Collection {
 	}
     return cachedClient.Database("propertyAppDatabase").Collection("refresh_tokens")
 }
+
+//GetLoginAttemptCollection returns the failed login attempts collection
+func GetLoginAttemptCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("login_attempts")
+}
+
+//GetPropertyCollection returns the property listings collection
+func GetPropertyCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("properties")
+}
+
+//GetAuditLogCollection returns the audit log collection
+func GetAuditLogCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("audit_logs")
+}
+
+//GetDataExportCollection returns the personal data export requests collection
+func GetDataExportCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("data_exports")
+}
+
+//GetFavoriteCollection returns the favorite listings collection
+func GetFavoriteCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("favorites")
+}
+
+//GetNotificationCollection returns the in-app notifications collection
+func GetNotificationCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("notifications")
+}
+
+//GetSavedSearchCollection returns the saved searches collection
+func GetSavedSearchCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("saved_searches")
+}
+
+//GetLeadCollection returns the buyer inquiries (leads) collection
+func GetLeadCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("leads")
+}
+
+//GetVisitAvailabilityCollection returns the listings' site visit availability collection
+func GetVisitAvailabilityCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("visit_availability")
+}
+
+//GetSiteVisitCollection returns the booked site visits collection
+func GetSiteVisitCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("site_visits")
+}
+
+//GetConversationCollection returns the chat conversations collection
+func GetConversationCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("conversations")
+}
+
+//GetMessageCollection returns the chat messages collection
+func GetMessageCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("messages")
+}
+
+//GetUserBlockCollection returns the blocked users collection
+func GetUserBlockCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("user_blocks")
+}
+
+//GetMessageReportCollection returns the reported conversations collection
+func GetMessageReportCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("message_reports")
+}
+
+//GetContactRevealCollection returns the masked contact reveals collection
+func GetContactRevealCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("contact_reveals")
+}
+
+//GetContactRevealCounterCollection returns the daily contact reveal counters collection
+func GetContactRevealCounterCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("contact_reveal_counters")
+}
+
+//GetListingReportCollection returns the user reports on listings collection
+func GetListingReportCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("listing_reports")
+}
+
+//GetProjectCollection returns the builder projects collection
+func GetProjectCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("projects")
+}
+
+//GetLocalityCollection returns the locality master collection
+func GetLocalityCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("localities")
+}
+
+//GetAmenityCollection returns the amenity catalogue collection
+func GetAmenityCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("amenities")
+}
+
+//GetListingVersionCollection returns the listing change history collection
+func GetListingVersionCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("listing_versions")
+}
+
+//GetListingRefreshCollection returns the collection of listing refreshes
+func GetListingRefreshCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("listing_refreshes")
+}
+
+//GetListingRefreshCounterCollection returns the daily listing refresh counters collection
+func GetListingRefreshCounterCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("listing_refresh_counters")
+}
+
+//GetDuplicateGroupCollection returns the collection of likely duplicate listing groups
+func GetDuplicateGroupCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("duplicate_groups")
+}
+
+//GetPriceStatsCollection returns the collection of price statistics by place, listing type and property type
+func GetPriceStatsCollection() *mongo.Collection {
+	if cachedClient == nil {
+		log.Println("Database client not initialized!")
+		return nil
+	}
+	return cachedClient.Database("propertyAppDatabase").Collection("price_stats")
+}
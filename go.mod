@@ -6,6 +6,7 @@ require (
 	github.com/golang-jwt/jwt/v5 v5.2.2
 	github.com/joho/godotenv v1.5.1
 	go.mongodb.org/mongo-driver v1.17.4
+	golang.org/x/image v0.19.0
 )
 
 require golang.org/x/sys v0.23.0 // indirect
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RequestDataExport starts building a ZIP of everything we hold about the authenticated user
+func RequestDataExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		collection := database.GetDataExportCollection()
+
+		pending, _ := collection.CountDocuments(r.Context(), bson.M{"userId": userID, "status": models.ExportPending})
+		if pending > 0 {
+			http.Error(w, "An export is already being prepared", http.StatusConflict)
+			return
+		}
+
+		export := models.DataExport{
+			UserID:    userID,
+			Status:    models.ExportPending,
+			CreatedAt: time.Now(),
+		}
+		result, err := collection.InsertOne(r.Context(), export)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create data export")
+			http.Error(w, "Failed to request export", http.StatusInternalServerError)
+			return
+		}
+		export.ID = result.InsertedID.(primitive.ObjectID)
+
+		services.RecordAudit(r.Context(), models.AuditLog{Action: models.AuditDataExportRequested, ActorID: userID, SubjectID: userID, IP: utils.ClientIP(r)})
+		go services.BuildDataExport(context.Background(), export)
+
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Export requested, you will be notified when it is ready",
+			"export":  export,
+		})
+	}
+}
+
+// findOwnExport loads one of the authenticated user's export requests from the {id} path variable
+func findOwnExport(r *http.Request) (models.DataExport, bool) {
+	userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+	var export models.DataExport
+	exportID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		return export, false
+	}
+	err = database.GetDataExportCollection().FindOne(r.Context(), bson.M{"_id": exportID, "userId": userID}).Decode(&export)
+	return export, err == nil
+}
+
+// ListDataExports returns the authenticated user's export requests, newest first
+func ListDataExports() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		cursor, err := database.GetDataExportCollection().Find(r.Context(), bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list data exports")
+			http.Error(w, "Failed to list exports", http.StatusInternalServerError)
+			return
+		}
+		exports := []models.DataExport{}
+		if err = cursor.All(r.Context(), &exports); err != nil {
+			logrus.WithError(err).Error("Failed to decode data exports")
+			http.Error(w, "Failed to list exports", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"exports": exports,
+		})
+	}
+}
+
+// DownloadDataExport streams a ready export ZIP to its owner
+func DownloadDataExport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		export, ok := findOwnExport(r)
+		if !ok {
+			http.Error(w, "Export not found", http.StatusNotFound)
+			return
+		}
+		if export.Status != models.ExportReady || time.Now().After(export.ExpiresAt) {
+			http.Error(w, "Export is not available for download", http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="my-data-`+export.CreatedAt.Format("2006-01-02")+`.zip"`)
+		http.ServeFile(w, r, export.FilePath)
+	}
+}
+
+// RequestAccountDeletion schedules the authenticated user's account for deletion after the grace period
+func RequestAccountDeletion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		cfg := config.GetCachedConfig()
+		scheduledFor := time.Now().AddDate(0, 0, cfg.AccountDeletionGraceDays)
+
+		result, err := database.GetUserCollection().UpdateOne(r.Context(),
+			bson.M{"_id": userID, "role": models.RegularUser, "deletionScheduledFor": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"deletionScheduledFor": scheduledFor}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to schedule account deletion")
+			http.Error(w, "Failed to request account deletion", http.StatusInternalServerError)
+			return
+		}
+		if result.MatchedCount == 0 {
+			http.Error(w, "Account deletion is already scheduled or not available for this account", http.StatusConflict)
+			return
+		}
+
+		services.RecordAudit(r.Context(), models.AuditLog{Action: models.AuditAccountDeletionAsked, ActorID: userID, SubjectID: userID, IP: utils.ClientIP(r)})
+		logrus.Info("Account deletion scheduled for user: ", userID.Hex())
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":              "Account deletion scheduled. You can cancel it until then.",
+			"deletionScheduledFor": scheduledFor,
+		})
+	}
+}
+
+// CancelAccountDeletion withdraws a pending deletion request during the grace period
+func CancelAccountDeletion() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		result, err := database.GetUserCollection().UpdateOne(r.Context(),
+			bson.M{"_id": userID, "deletionScheduledFor": bson.M{"$gt": time.Now()}},
+			bson.M{"$unset": bson.M{"deletionScheduledFor": ""}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to cancel account deletion")
+			http.Error(w, "Failed to cancel account deletion", http.StatusInternalServerError)
+			return
+		}
+		if result.MatchedCount == 0 {
+			http.Error(w, "No pending account deletion", http.StatusNotFound)
+			return
+		}
+
+		services.RecordAudit(r.Context(), models.AuditLog{Action: models.AuditAccountDeletionUndo, ActorID: userID, SubjectID: userID, IP: utils.ClientIP(r)})
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Account deletion cancelled",
+		})
+	}
+}
@@ -2,81 +2,40 @@ package handlers
 
 import (
 	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
 	"PropertyAppBackend/models"
 	"PropertyAppBackend/utils"
 	"encoding/json"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-// Admin Login API
-func AdminLogin() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req models.User
-		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil || req.Username == nil || req.Password == nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		// **Find Admin**
-		var admin models.User
-		err = database.GetUserCollection().FindOne(r.Context(), bson.M{"username": req.Username, "role": models.Admin}).Decode(&admin)
-		if err != nil {
-			http.Error(w, "Admin not found", http.StatusUnauthorized)
-			return
-		}
-
-		// **Check Password**
-		if !utils.CheckPasswordHash(*req.Password, *admin.Password) {
-			http.Error(w, "Invalid password", http.StatusUnauthorized)
-			return
-		}
-
-		// **Generate Token**
-		token, _ := utils.GenerateJWT(admin.ID, string(admin.Role))
-
-		// **Return Response**
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message":  "Login successful",
-			"userID":   admin.ID.Hex(),
-			"username": admin.Username,
-			"token":    token,
-		})
-	}
-}
-
 // create Mini Admin
 
 func CreateMiniAdmin() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")
-		if authHeader == "" {
-			http.Error(w, "Unauthorized: Token is missing", http.StatusUnauthorized)
+		granter := r.Context().Value(middleware.StaffKey).(models.User)
+		var req models.User
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Username ==  nil || req.Password == nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
 			return
 		}
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-
-		println("TokenString", tokenString)
-		adminID, claims, err := utils.ParseJWT(tokenString, string(utils.JWTSecret))
-		if err != nil {
-			http.Error(w, "Unauthorized: Invalid token", http.StatusUnauthorized)
-			println(err)
+		// Without a list the mini-admin gets the role defaults, which the granter must hold too
+		if msg, ok := checkGrantablePermissions(granter, models.PermissionsFor(models.User{Role: models.MiniAdmin, Permissions: req.Permissions})); !ok {
+			http.Error(w, msg, http.StatusBadRequest)
 			return
 		}
-		role, ok := claims["role"].(string)
-		if !ok || role != string(models.Admin) {
-			http.Error(w, "Unauthorized: Only Admin can perform this action", http.StatusForbidden)
-			return
+		if req.Scope == nil && !models.Unscoped(granter) {
+			// Scoped staff hand out at most their own scope
+			scope := *granter.Scope
+			req.Scope = &scope
 		}
-		var req models.User
-		err = json.NewDecoder(r.Body).Decode(&req)
-		if err != nil || req.Username ==  nil || req.Password == nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+		if msg, ok := checkGrantableScope(r.Context(), granter, req.Scope); !ok {
+			http.Error(w, msg, http.StatusBadRequest)
 			return
 		}
 
@@ -87,25 +46,29 @@ func CreateMiniAdmin() http.HandlerFunc {
 			return
 		}
 
-		existingCount, _ := database.GetUserCollection().CountDocuments(r.Context(), bson.M{"username": req.Username, "role": models.MiniAdmin})
+		// Staff log in by username alone, so it has to be unique across every role
+		existingCount, _ := database.GetUserCollection().CountDocuments(r.Context(), bson.M{"username": req.Username})
 		if existingCount > 0 {
-			http.Error(w, "Mini-Admin with this username already exists", http.StatusConflict)
+			http.Error(w, "A staff account with this username already exists", http.StatusConflict)
 			return
 		}
 
 		//hashPassword
 
-		hashedPassword, _ := utils.HashPassword(*req.Password)
+		hashedPassword, err := utils.HashPassword(*req.Password)
 		if err != nil {
     http.Error(w, "Failed to hash password", http.StatusInternalServerError)
     return
 }
 		newMiniAdmin := models.User{
-			Username:  req.Username,
-			Password:  &hashedPassword,
-			Role:      models.MiniAdmin,
-			CreatedBy: adminID,
-			CreatedAt: time.Now(),
+			Username:    req.Username,
+			Password:    &hashedPassword,
+			PhoneNumber: req.PhoneNumber, // optional, used for security notices such as lockouts
+			Role:        models.MiniAdmin,
+			Permissions: req.Permissions, // nil keeps the mini-admin defaults
+			Scope:       req.Scope,
+			CreatedBy:   granter.ID,
+			CreatedAt:   time.Now(),
 		}
 
 		_, err = database.GetUserCollection().InsertOne(r.Context(), newMiniAdmin)
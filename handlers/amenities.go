@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Most amenities one listing or project can have
+const maxAmenitiesPerListing = 50
+
+// loadAmenityIDs checks the requested amenities exist in the catalogue and, when propertyType is set,
+// apply to that type of property. It writes the error response and returns false otherwise.
+func loadAmenityIDs(w http.ResponseWriter, r *http.Request, ids []string, propertyType string) ([]primitive.ObjectID, bool) {
+	if len(ids) == 0 {
+		return nil, true
+	}
+	if len(ids) > maxAmenitiesPerListing {
+		http.Error(w, "Too many amenities", http.StatusBadRequest)
+		return nil, false
+	}
+	amenityIDs := make([]primitive.ObjectID, 0, len(ids))
+	for _, id := range ids {
+		amenityID, err := primitive.ObjectIDFromHex(id)
+		if err != nil {
+			http.Error(w, "Invalid amenity ID "+id, http.StatusBadRequest)
+			return nil, false
+		}
+		amenityIDs = append(amenityIDs, amenityID)
+	}
+
+	cursor, err := database.GetAmenityCollection().Find(r.Context(), bson.M{"_id": bson.M{"$in": amenityIDs}})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load amenities")
+		http.Error(w, "Failed to load amenities", http.StatusInternalServerError)
+		return nil, false
+	}
+	var amenities []models.Amenity
+	if err = cursor.All(r.Context(), &amenities); err != nil {
+		logrus.WithError(err).Error("Failed to decode amenities")
+		http.Error(w, "Failed to load amenities", http.StatusInternalServerError)
+		return nil, false
+	}
+	found := make(map[primitive.ObjectID]models.Amenity, len(amenities))
+	for _, amenity := range amenities {
+		found[amenity.ID] = amenity
+	}
+
+	unique := amenityIDs[:0]
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range amenityIDs {
+		amenity, ok := found[id]
+		if !ok {
+			http.Error(w, "Unknown amenity "+id.Hex(), http.StatusBadRequest)
+			return nil, false
+		}
+		if propertyType != "" && !amenity.AppliesTo(propertyType) {
+			http.Error(w, amenity.Label+" doesn't apply to a "+propertyType, http.StatusBadRequest)
+			return nil, false
+		}
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique, true
+}
+
+// ListAmenities returns the amenity catalogue by category, only the amenities applying to
+// propertyType when it is given
+func ListAmenities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter := bson.M{}
+		if propertyType := r.URL.Query().Get("propertyType"); propertyType != "" {
+			filter["$or"] = []bson.M{{"propertyTypes": propertyType}, {"propertyTypes": bson.M{"$exists": false}}}
+		}
+		cursor, err := database.GetAmenityCollection().Find(r.Context(), filter,
+			options.Find().SetSort(bson.D{{Key: "category", Value: 1}, {Key: "label", Value: 1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list amenities")
+			http.Error(w, "Failed to load amenities", http.StatusInternalServerError)
+			return
+		}
+		amenities := []models.Amenity{}
+		if err = cursor.All(r.Context(), &amenities); err != nil {
+			logrus.WithError(err).Error("Failed to decode amenities")
+			http.Error(w, "Failed to load amenities", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"amenities": amenities,
+		})
+	}
+}
+
+// AmenityRequest is the payload for adding an amenity to the catalogue or editing one
+type AmenityRequest struct {
+	Label         *string   `json:"label"`
+	IconKey       *string   `json:"iconKey"`
+	Category      *string   `json:"category"`
+	PropertyTypes *[]string `json:"propertyTypes"`
+}
+
+// CreateAmenity adds an amenity to the catalogue
+func CreateAmenity() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req AmenityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Label == nil || strings.TrimSpace(*req.Label) == "" || req.IconKey == nil || req.Category == nil {
+			http.Error(w, "label, iconKey and category are required", http.StatusBadRequest)
+			return
+		}
+		if !models.IsValidAmenityCategory(*req.Category) {
+			http.Error(w, "category must be building, community, unit or safety", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		amenity := models.Amenity{
+			Label:     strings.TrimSpace(*req.Label),
+			IconKey:   strings.TrimSpace(*req.IconKey),
+			Category:  *req.Category,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if req.PropertyTypes != nil {
+			amenity.PropertyTypes = *req.PropertyTypes
+		}
+		result, err := database.GetAmenityCollection().InsertOne(r.Context(), amenity)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "An amenity with this label already exists", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create amenity")
+			http.Error(w, "Failed to create amenity", http.StatusInternalServerError)
+			return
+		}
+		amenity.ID = result.InsertedID.(primitive.ObjectID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(amenity)
+	}
+}
+
+// UpdateAmenity edits an amenity of the catalogue. Listings keep it when its property types change.
+func UpdateAmenity() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		amenityID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid amenity ID", http.StatusBadRequest)
+			return
+		}
+		var req AmenityRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		set := bson.M{"updatedAt": time.Now()}
+		update := bson.M{"$set": set}
+		if req.Label != nil {
+			if strings.TrimSpace(*req.Label) == "" {
+				http.Error(w, "label can't be empty", http.StatusBadRequest)
+				return
+			}
+			set["label"] = strings.TrimSpace(*req.Label)
+		}
+		if req.IconKey != nil {
+			set["iconKey"] = strings.TrimSpace(*req.IconKey)
+		}
+		if req.Category != nil {
+			if !models.IsValidAmenityCategory(*req.Category) {
+				http.Error(w, "category must be building, community, unit or safety", http.StatusBadRequest)
+				return
+			}
+			set["category"] = *req.Category
+		}
+		if req.PropertyTypes != nil {
+			if len(*req.PropertyTypes) == 0 {
+				update["$unset"] = bson.M{"propertyTypes": ""}
+			} else {
+				set["propertyTypes"] = *req.PropertyTypes
+			}
+		}
+
+		var previous, amenity models.Amenity
+		err = database.GetAmenityCollection().FindOneAndUpdate(r.Context(), bson.M{"_id": amenityID}, update).Decode(&previous)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "An amenity with this label already exists", http.StatusConflict)
+			return
+		}
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Amenity not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update amenity")
+			http.Error(w, "Failed to update amenity", http.StatusInternalServerError)
+			return
+		}
+		if err = database.GetAmenityCollection().FindOne(r.Context(), bson.M{"_id": amenityID}).Decode(&amenity); err != nil {
+			logrus.WithError(err).Error("Failed to reload amenity")
+			http.Error(w, "Failed to update amenity", http.StatusInternalServerError)
+			return
+		}
+
+		// Listings carry the label for the text index
+		if amenity.Label != previous.Label {
+			_, err = database.GetPropertyCollection().UpdateMany(r.Context(),
+				bson.M{"amenityIds": amenityID, "amenityLabels": previous.Label},
+				bson.M{"$set": bson.M{"amenityLabels.$[label]": amenity.Label}},
+				options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"label": previous.Label}}}))
+			if err != nil {
+				logrus.WithError(err).Error("Failed to relabel amenity ", amenityID.Hex(), " on listings")
+			}
+		}
+		json.NewEncoder(w).Encode(amenity)
+	}
+}
+
+// DeleteAmenity removes an amenity from the catalogue and from every listing and project offering it
+func DeleteAmenity() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		amenityID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid amenity ID", http.StatusBadRequest)
+			return
+		}
+
+		var amenity models.Amenity
+		err = database.GetAmenityCollection().FindOneAndDelete(r.Context(), bson.M{"_id": amenityID}).Decode(&amenity)
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Amenity not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to delete amenity")
+			http.Error(w, "Failed to delete amenity", http.StatusInternalServerError)
+			return
+		}
+		for _, collection := range []*mongo.Collection{database.GetPropertyCollection(), database.GetProjectCollection()} {
+			_, err = collection.UpdateMany(r.Context(), bson.M{"amenityIds": amenityID},
+				bson.M{"$pull": bson.M{"amenityIds": amenityID, "amenityLabels": amenity.Label}})
+			if err != nil {
+				logrus.WithError(err).Error("Failed to remove deleted amenity ", amenityID.Hex())
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Amenity deleted",
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"PropertyAppBackend/models"
 	"PropertyAppBackend/services"
 	"PropertyAppBackend/utils"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -36,6 +37,35 @@ type AuthResponse struct {
 	RefreshToken string      `json:"refreshToken"`
 	User         models.User `json:"user"`
 	Role         string `json:"role"`
+	Permissions  []models.Permission `json:"permissions,omitempty"`
+}
+
+// issueTokenPair signs an access and a refresh token for the user and stores the refresh token
+func issueTokenPair(ctx context.Context, user models.User) (string, string, error) {
+	cfg := config.GetCachedConfig()
+	accessToken, err := utils.GenerateAccessToken(user.ID, string(user.Role), cfg.JWTSecret)
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err := utils.GenerateRefreshToken(user.ID, cfg.RefreshTokenSecret, cfg.RefreshTokenLifetimeHours)
+	if err != nil {
+		return "", "", err
+	}
+	_, err = database.GetRefreshTokenCollection().InsertOne(ctx, models.RefreshToken{
+		Token:     refreshToken,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Duration(cfg.RefreshTokenLifetimeHours) * time.Hour),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return accessToken, refreshToken, nil
+}
+
+// loginOTPFilter matches the login/sign-up OTP of a phone number, never one issued for another purpose
+func loginOTPFilter(phoneNumber string) bson.M {
+	return bson.M{"phoneNumber": phoneNumber, "purpose": bson.M{"$exists": false}}
 }
 
 // Send OTP
@@ -91,7 +121,7 @@ func SendOTP() http.HandlerFunc {
 			CreatedAt:   time.Now(),
 		}
 
-		_, err = otpCollection.UpdateOne(database.Ctx, bson.M{"phoneNumber": req.PhoneNumber}, bson.M{"$set": otpRecord}, options.Update().SetUpsert(true))
+		_, err = otpCollection.UpdateOne(database.Ctx, loginOTPFilter(req.PhoneNumber), bson.M{"$set": otpRecord}, options.Update().SetUpsert(true))
 		if err != nil {
 			logrus.Error("Failed to store OTP in database:", err)
 			http.Error(w, "Failed to store OTP: "+err.Error(), http.StatusInternalServerError)
@@ -128,16 +158,14 @@ func VerifyOTP() http.HandlerFunc {
 			return
 		}
 
-		cfg := config.GetCachedConfig()
 		database.GetCachedClient()
 		otpCollection := database.GetOTPCollection()
 		userCollection := database.GetUserCollection()
-		refreshTokenCollection := database.GetRefreshTokenCollection() // Get refresh token collection
 
 		var storedOTP models.OTPRecord
-		err = otpCollection.FindOne(database.Ctx, bson.M{"phoneNumber": req.PhoneNumber}).Decode(&storedOTP)
+		err = otpCollection.FindOne(database.Ctx, loginOTPFilter(req.PhoneNumber)).Decode(&storedOTP)
 		if err != nil || time.Now().After(storedOTP.ExpiresAt) {
-			otpCollection.DeleteOne(database.Ctx, bson.M{"phoneNumber": req.PhoneNumber})
+			otpCollection.DeleteOne(database.Ctx, loginOTPFilter(req.PhoneNumber))
 			http.Error(w, "OTP expired or invalid", http.StatusUnauthorized)
 			return
 		}
@@ -147,7 +175,7 @@ func VerifyOTP() http.HandlerFunc {
 			http.Error(w, "Invalid OTP", http.StatusUnauthorized)
 			return
 		}
-		otpCollection.DeleteOne(database.Ctx, bson.M{"phoneNumber": req.PhoneNumber})
+		otpCollection.DeleteOne(database.Ctx, loginOTPFilter(req.PhoneNumber))
 		var user models.User
 		err = userCollection.FindOne(database.Ctx, bson.M{"phoneNumber": req.PhoneNumber}).Decode(&user)
 		if err == mongo.ErrNoDocuments {
@@ -165,9 +193,12 @@ func VerifyOTP() http.HandlerFunc {
 			userCollection.FindOne(database.Ctx, bson.M{"_id": insertResult.InsertedID}).Decode(&user)
 		}
 
-		accessToken, _ := utils.GenerateAccessToken(user.ID, cfg.JWTSecret)
-		refreshToken, _ := utils.GenerateRefreshToken(user.ID, cfg.RefreshTokenSecret, cfg.RefreshTokenLifetimeHours)
-		refreshTokenCollection.InsertOne(database.Ctx, models.RefreshToken{Token: refreshToken, UserID: user.ID, ExpiresAt: time.Now().Add(time.Duration(cfg.RefreshTokenLifetimeHours) * time.Hour)})
+		accessToken, refreshToken, err := issueTokenPair(r.Context(), user)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to issue tokens")
+			http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+			return
+		}
 		json.NewEncoder(w).Encode(AuthResponse{
 			Message:      "User successfully verified",
 			AccessToken:  accessToken,
@@ -177,41 +208,3 @@ func VerifyOTP() http.HandlerFunc {
 		})
 	}
 }
-
-// Mini-Admin Login API
-func MiniAdminLogin() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var req models.User
-		err := json.NewDecoder(r.Body).Decode(&req)
-		if err != nil || req.Username == nil || req.Password == nil {
-			http.Error(w, "Invalid request payload", http.StatusBadRequest)
-			return
-		}
-
-		// **Find Mini-Admin**
-		var miniAdmin models.User
-		err = database.GetUserCollection().FindOne(r.Context(), bson.M{"username": req.Username, "role": models.MiniAdmin}).Decode(&miniAdmin)
-		if err != nil {
-			http.Error(w, "Mini-Admin not found", http.StatusUnauthorized)
-			return
-		}
-
-		// **Check Password**
-		if !utils.CheckPasswordHash(*req.Password, *miniAdmin.Password) {
-			http.Error(w, "Invalid password", http.StatusUnauthorized)
-			return
-		}
-
-		// **Generate Token**
-		token, _ := utils.GenerateJWT(miniAdmin.ID, string(models.MiniAdmin))
-
-		// **Return Response**
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"message":  "Login successful",
-			"userID":   miniAdmin.ID.Hex(),
-			"username": miniAdmin.Username,
-			"token":    token,
-			"role":     miniAdmin.Role,
-		})
-	}
-}
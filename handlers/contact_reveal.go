@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ResolveProxyRequest is the telephony provider asking where to forward a call to the masked number
+type ResolveProxyRequest struct {
+	ProxyID      string `json:"proxyId"`
+	CallerNumber string `json:"callerNumber"`
+}
+
+// generateProxyID returns a random 8 digit proxy code
+func generateProxyID() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(90000000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate proxy code: %w", err)
+	}
+	return fmt.Sprintf("%08d", n.Int64()+10000000), nil
+}
+
+// RevealContact gives the authenticated user a temporary proxy code to call the lister of a
+// published listing through the masked number, and logs the reveal as a lead. The code only works
+// from the user's own phone number. Asking again while the code is valid returns the same code;
+// new reveals are limited per buyer per day.
+func RevealContact() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+		cfg := config.GetCachedConfig()
+		if cfg.ContactProxyNumber == "" {
+			http.Error(w, "Calling listers isn't available right now, send an inquiry instead", http.StatusServiceUnavailable)
+			return
+		}
+
+		var listing property.Property
+		if err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": propertyID, "status": property.Published}).Decode(&listing); err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if listing.OwnerID == userID {
+			http.Error(w, "This is your own listing", http.StatusBadRequest)
+			return
+		}
+
+		var buyer models.User
+		if err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID}).Decode(&buyer); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if buyer.PhoneNumber == nil || *buyer.PhoneNumber == "" {
+			http.Error(w, "Add a phone number to your profile to call listers", http.StatusBadRequest)
+			return
+		}
+
+		collection := database.GetContactRevealCollection()
+		now := time.Now()
+		var reveal models.ContactReveal
+		err = collection.FindOne(r.Context(), bson.M{"propertyId": propertyID, "buyerId": userID, "buyerPhone": *buyer.PhoneNumber, "expiresAt": bson.M{"$gt": now}}).Decode(&reveal)
+		if err == nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"proxyNumber": cfg.ContactProxyNumber,
+				"proxyId":     reveal.ProxyID,
+				"expiresAt":   reveal.ExpiresAt,
+			})
+			return
+		}
+
+		counters, counterKey := database.GetContactRevealCounterCollection(), bson.M{"buyerId": userID}
+		_, allowed, err := services.ClaimDailyCount(r.Context(), counters, counterKey, cfg.ContactRevealsPerDay, now)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to count contact reveal")
+			http.Error(w, "Failed to reveal contact", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, fmt.Sprintf("You can reveal at most %d contacts a day, send an inquiry instead", cfg.ContactRevealsPerDay), http.StatusTooManyRequests)
+			return
+		}
+
+		reveal = models.ContactReveal{
+			PropertyID: propertyID,
+			OwnerID:    listing.OwnerID,
+			BuyerID:    userID,
+			BuyerPhone: *buyer.PhoneNumber,
+			ExpiresAt:  now.Add(time.Duration(cfg.ContactProxyHours) * time.Hour),
+			CreatedAt:  now,
+		}
+		// Proxy codes are unique among active reveals; retry the rare collision
+		for attempt := 0; attempt < 5; attempt++ {
+			if reveal.ProxyID, err = generateProxyID(); err != nil {
+				break
+			}
+			if _, err = collection.InsertOne(r.Context(), reveal); !mongo.IsDuplicateKeyError(err) {
+				break
+			}
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create contact reveal")
+			if err = services.ReleaseDailyCount(r.Context(), counters, counterKey, now); err != nil {
+				logrus.WithError(err).Warn("Failed to release contact reveal count")
+			}
+			http.Error(w, "Failed to reveal contact", http.StatusInternalServerError)
+			return
+		}
+
+		_, err = database.GetLeadCollection().UpdateOne(r.Context(),
+			bson.M{"propertyId": propertyID, "buyerId": userID},
+			bson.M{"$setOnInsert": models.Lead{
+				PropertyID: propertyID,
+				OwnerID:    listing.OwnerID,
+				BuyerID:    userID,
+				Message:    "Asked for the contact number",
+				Source:     models.LeadSourceContactReveal,
+				Status:     models.LeadNew,
+				City:       listing.City,
+				Locality:   listing.Locality,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to log contact reveal as lead for ", propertyID.Hex())
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"proxyNumber": cfg.ContactProxyNumber,
+			"proxyId":     reveal.ProxyID,
+			"expiresAt":   reveal.ExpiresAt,
+		})
+	}
+}
+
+// ResolveContactProxy is called by the telephony provider with the code a caller dialled after the
+// masked number and the number they called from, and returns the lister's real number to forward
+// the call to. A code only resolves for the buyer it was given to. It is authenticated by the
+// shared secret in the X-Proxy-Secret header.
+func ResolveContactProxy() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := config.GetCachedConfig().ContactProxySecret
+		if secret == "" || !hmac.Equal([]byte(r.Header.Get("X-Proxy-Secret")), []byte(secret)) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req ResolveProxyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.ProxyID == "" || req.CallerNumber == "" {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		var reveal models.ContactReveal
+		err := database.GetContactRevealCollection().FindOne(r.Context(), bson.M{"proxyId": req.ProxyID, "buyerPhone": req.CallerNumber, "expiresAt": bson.M{"$gt": time.Now()}}).Decode(&reveal)
+		if err != nil {
+			http.Error(w, "Unknown or expired code", http.StatusNotFound)
+			return
+		}
+		var owner models.User
+		err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": reveal.OwnerID, "deletedAt": bson.M{"$exists": false}}).Decode(&owner)
+		if err != nil || owner.PhoneNumber == nil {
+			http.Error(w, "Lister can't be reached", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"phoneNumber": *owner.PhoneNumber})
+	}
+}
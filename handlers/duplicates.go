@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ResolveDuplicatesRequest is a staff decision on a group of likely duplicate listings
+type ResolveDuplicatesRequest struct {
+	Action    string             `json:"action"`              // confirm or dismiss
+	PrimaryID primitive.ObjectID `json:"primaryId,omitempty"` // listing kept on confirm, the group's primary by default
+}
+
+// DuplicateGroupItem is a group in the staff duplicates queue with its listings
+type DuplicateGroupItem struct {
+	models.DuplicateGroup
+	Listings []property.Property `json:"listings"`
+}
+
+// ListDuplicateGroups returns the groups of likely duplicate listings inside the caller's scope,
+// open ones by default, with the most alike first
+func ListDuplicateGroups() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = models.DuplicatesOpen
+		}
+		filter := models.ScopeFilter(staff, "city", "locality")
+		filter["status"] = status
+
+		cursor, err := database.GetDuplicateGroupCollection().Find(r.Context(), filter,
+			options.Find().SetSort(bson.D{{Key: "score", Value: -1}, {Key: "createdAt", Value: 1}}).SetLimit(100))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load duplicate groups")
+			http.Error(w, "Failed to load duplicates", http.StatusInternalServerError)
+			return
+		}
+		var groups []models.DuplicateGroup
+		if err = cursor.All(r.Context(), &groups); err != nil {
+			logrus.WithError(err).Error("Failed to decode duplicate groups")
+			http.Error(w, "Failed to load duplicates", http.StatusInternalServerError)
+			return
+		}
+
+		var ids []primitive.ObjectID
+		for _, group := range groups {
+			ids = append(ids, group.PropertyIDs...)
+		}
+		listings := map[primitive.ObjectID]property.Property{}
+		if len(ids) > 0 {
+			cursor, err = database.GetPropertyCollection().Find(r.Context(), bson.M{"_id": bson.M{"$in": ids}})
+			if err == nil {
+				var found []property.Property
+				if err = cursor.All(r.Context(), &found); err == nil {
+					for _, listing := range found {
+						listings[listing.ID] = listing
+					}
+				}
+			}
+			if err != nil {
+				logrus.WithError(err).Error("Failed to load duplicate listings")
+				http.Error(w, "Failed to load duplicates", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		items := make([]DuplicateGroupItem, 0, len(groups))
+		for _, group := range groups {
+			item := DuplicateGroupItem{DuplicateGroup: group, Listings: []property.Property{}}
+			for _, id := range group.PropertyIDs {
+				if listing, ok := listings[id]; ok {
+					item.Listings = append(item.Listings, listing)
+				}
+			}
+			items = append(items, item)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"groups": items,
+		})
+	}
+}
+
+// ResolveDuplicateGroup closes an open group of likely duplicates inside the caller's scope.
+// Confirming keeps one listing and takes the others down; dismissing keeps them all and stops
+// them being grouped together again.
+func ResolveDuplicateGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+		groupID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid group ID", http.StatusBadRequest)
+			return
+		}
+		var req ResolveDuplicatesRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		var group models.DuplicateGroup
+		err = database.GetDuplicateGroupCollection().FindOne(r.Context(), bson.M{"_id": groupID}).Decode(&group)
+		if err != nil || !models.InScope(staff, group.City, group.Locality) {
+			http.Error(w, "Duplicate group not found", http.StatusNotFound)
+			return
+		}
+		if group.Status != models.DuplicatesOpen {
+			http.Error(w, "Duplicate group is already "+group.Status, http.StatusConflict)
+			return
+		}
+
+		var status string
+		switch req.Action {
+		case "confirm":
+			status = models.DuplicatesConfirmed
+			if req.PrimaryID.IsZero() {
+				req.PrimaryID = group.PrimaryID
+			}
+			isMember := false
+			for _, id := range group.PropertyIDs {
+				isMember = isMember || id == req.PrimaryID
+			}
+			if !isMember {
+				http.Error(w, "primaryId must be a listing of the group", http.StatusBadRequest)
+				return
+			}
+		case "dismiss":
+			status = models.DuplicatesDismissed
+		default:
+			http.Error(w, "Action must be confirm or dismiss", http.StatusBadRequest)
+			return
+		}
+
+		// Close the group first so the detection job and other reviewers leave it alone
+		now := time.Now()
+		set := bson.M{"status": status, "resolvedBy": staff.ID, "resolvedAt": now, "updatedAt": now}
+		if status == models.DuplicatesConfirmed {
+			set["primaryId"] = req.PrimaryID
+		}
+		result, err := database.GetDuplicateGroupCollection().UpdateOne(r.Context(),
+			bson.M{"_id": groupID, "status": models.DuplicatesOpen}, bson.M{"$set": set})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to resolve duplicate group")
+			http.Error(w, "Failed to resolve duplicates", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Duplicate group was changed by someone else, reload and try again", http.StatusConflict)
+			return
+		}
+		_, err = database.GetPropertyCollection().UpdateMany(r.Context(),
+			bson.M{"_id": bson.M{"$in": group.PropertyIDs}, "duplicateGroupId": groupID},
+			bson.M{"$unset": bson.M{"duplicateGroupId": "", "duplicateOf": ""}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to unlink duplicate listings")
+		}
+
+		removed := 0
+		if status == models.DuplicatesConfirmed {
+			for _, id := range group.PropertyIDs {
+				if id == req.PrimaryID {
+					continue
+				}
+				var listing property.Property
+				if err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": id}).Decode(&listing); err != nil {
+					continue
+				}
+				// Listings that were sold or taken down meanwhile are left as they are
+				if !property.CanTransition(listing.Status, property.Rejected) {
+					continue
+				}
+				result, err = database.GetPropertyCollection().UpdateOne(r.Context(),
+					bson.M{"_id": id, "status": listing.Status},
+					bson.M{"$set": bson.M{"status": property.Rejected, "reviewedBy": staff.ID, "updatedAt": now, "rejectionReason": "Duplicate of another listing"}})
+				if err != nil {
+					logrus.WithError(err).Error("Failed to take down duplicate listing ", id.Hex())
+					continue
+				}
+				if result.ModifiedCount == 0 {
+					continue
+				}
+				removed++
+				services.RecordListingUpdate(r.Context(), listing, staff.ID, models.ChangedByStaff)
+				services.RefreshListingProject(r.Context(), listing)
+				notifyUser(r.Context(), listing.OwnerID, services.Notice{
+					Kind:  models.NotificationListingDuplicate,
+					Title: "Your listing was taken down",
+					Body:  "\"" + listing.Title + "\" advertises a property that is already listed.",
+					Data:  map[string]interface{}{"propertyId": listing.ID.Hex()},
+				})
+			}
+		}
+
+		services.RecordAudit(r.Context(), models.AuditLog{
+			Action:    models.AuditDuplicatesResolved,
+			ActorID:   staff.ID,
+			SubjectID: groupID,
+			IP:        utils.ClientIP(r),
+			Details:   map[string]interface{}{"action": req.Action, "primaryId": req.PrimaryID.Hex(), "removed": removed},
+		})
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": fmt.Sprintf("Duplicates %s, %d listings taken down", status, removed),
+			"status":  status,
+		})
+	}
+}
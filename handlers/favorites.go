@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// FavoriteItem is a shortlisted listing. Listings that were sold or taken down stay in the
+// shortlist but are flagged unavailable, with only enough of them to recognize them by; neither
+// is set when the listing no longer exists at all.
+type FavoriteItem struct {
+	PropertyID         primitive.ObjectID  `json:"propertyId"`
+	FavoritedAt        time.Time           `json:"favoritedAt"`
+	Available          bool                `json:"available"`
+	UnavailableReason  string              `json:"unavailableReason,omitempty"` // sold, let, removed, under_review, expired
+	Listing            *property.Property  `json:"listing,omitempty"`
+	UnavailableListing *UnavailableListing `json:"unavailableListing,omitempty"`
+}
+
+// UnavailableListing is what a shortlist still shows of a listing that is no longer on the market
+type UnavailableListing struct {
+	ID           primitive.ObjectID `json:"_id"`
+	Title        string             `json:"title"`
+	PropertyType string             `json:"propertyType"`
+	ListingType  string             `json:"listingType"`
+	City         string             `json:"city"`
+	Locality     string             `json:"locality,omitempty"`
+}
+
+// unavailableReason explains why a shortlisted listing can't be acted on, or "" if it can
+func unavailableReason(listing *property.Property) string {
+	if listing == nil {
+		return "removed"
+	}
+	switch listing.Status {
+	case property.Published:
+		return ""
+	case property.Sold:
+		return "sold"
+	case property.Let:
+		return "let"
+	case property.PendingReview, property.Draft, property.Suspended:
+		return "under_review"
+	case property.Expired:
+		return "expired"
+	default:
+		return "removed"
+	}
+}
+
+// markFavorited sets IsFavorited on the listings the user shortlisted
+func markFavorited(ctx context.Context, userID primitive.ObjectID, listings []property.Property) {
+	if len(listings) == 0 {
+		return
+	}
+	ids := make([]primitive.ObjectID, 0, len(listings))
+	for _, listing := range listings {
+		ids = append(ids, listing.ID)
+	}
+	cursor, err := database.GetFavoriteCollection().Find(ctx, bson.M{"userId": userID, "propertyId": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"propertyId": 1}))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load favorites")
+		return
+	}
+	var favorites []models.Favorite
+	if err = cursor.All(ctx, &favorites); err != nil {
+		logrus.WithError(err).Warn("Failed to decode favorites")
+		return
+	}
+	favorited := make(map[primitive.ObjectID]bool, len(favorites))
+	for _, favorite := range favorites {
+		favorited[favorite.PropertyID] = true
+	}
+	for i := range listings {
+		listings[i].IsFavorited = favorited[listings[i].ID]
+	}
+}
+
+// AddFavorite shortlists a published listing for the authenticated user. Adding it twice is a no-op.
+func AddFavorite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		count, _ := database.GetPropertyCollection().CountDocuments(r.Context(), bson.M{"_id": propertyID, "status": property.Published})
+		if count == 0 {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+
+		_, err = database.GetFavoriteCollection().InsertOne(r.Context(), models.Favorite{UserID: userID, PropertyID: propertyID, CreatedAt: time.Now()})
+		if mongo.IsDuplicateKeyError(err) {
+			json.NewEncoder(w).Encode(map[string]string{"message": "Listing already in favorites"})
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to add favorite")
+			http.Error(w, "Failed to add favorite", http.StatusInternalServerError)
+			return
+		}
+		database.GetPropertyCollection().UpdateOne(r.Context(), bson.M{"_id": propertyID}, bson.M{"$inc": bson.M{"favoriteCount": 1}})
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Listing added to favorites"})
+	}
+}
+
+// RemoveFavorite takes a listing off the authenticated user's shortlist
+func RemoveFavorite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		result, err := database.GetFavoriteCollection().DeleteOne(r.Context(), bson.M{"userId": userID, "propertyId": propertyID})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to remove favorite")
+			http.Error(w, "Failed to remove favorite", http.StatusInternalServerError)
+			return
+		}
+		if result.DeletedCount == 0 {
+			http.Error(w, "Listing not in favorites", http.StatusNotFound)
+			return
+		}
+		database.GetPropertyCollection().UpdateOne(r.Context(), bson.M{"_id": propertyID, "favoriteCount": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"favoriteCount": -1}})
+
+		json.NewEncoder(w).Encode(map[string]string{"message": "Listing removed from favorites"})
+	}
+}
+
+// ListFavorites returns the authenticated user's shortlist, newest first, with unavailable listings flagged
+func ListFavorites() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		cursor, err := database.GetFavoriteCollection().Find(r.Context(), bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list favorites")
+			http.Error(w, "Failed to list favorites", http.StatusInternalServerError)
+			return
+		}
+		var favorites []models.Favorite
+		if err = cursor.All(r.Context(), &favorites); err != nil {
+			logrus.WithError(err).Error("Failed to decode favorites")
+			http.Error(w, "Failed to list favorites", http.StatusInternalServerError)
+			return
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(favorites))
+		for _, favorite := range favorites {
+			ids = append(ids, favorite.PropertyID)
+		}
+		cursor, err = database.GetPropertyCollection().Find(r.Context(), bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load favorite listings")
+			http.Error(w, "Failed to list favorites", http.StatusInternalServerError)
+			return
+		}
+		listings := []property.Property{}
+		if err = cursor.All(r.Context(), &listings); err != nil {
+			logrus.WithError(err).Error("Failed to decode favorite listings")
+			http.Error(w, "Failed to list favorites", http.StatusInternalServerError)
+			return
+		}
+		attachListers(r.Context(), listings)
+		services.MarkPriceDrops(listings)
+
+		byID := make(map[primitive.ObjectID]*property.Property, len(listings))
+		for i := range listings {
+			listings[i].IsFavorited = true
+			byID[listings[i].ID] = &listings[i]
+		}
+		items := make([]FavoriteItem, 0, len(favorites))
+		for _, favorite := range favorites {
+			listing := byID[favorite.PropertyID]
+			reason := unavailableReason(listing)
+			item := FavoriteItem{
+				PropertyID:        favorite.PropertyID,
+				FavoritedAt:       favorite.CreatedAt,
+				Available:         reason == "",
+				UnavailableReason: reason,
+			}
+			switch {
+			case reason == "":
+				item.Listing = listing
+			case listing != nil:
+				item.UnavailableListing = &UnavailableListing{
+					ID:           listing.ID,
+					Title:        listing.Title,
+					PropertyType: listing.PropertyType,
+					ListingType:  listing.ListingType,
+					City:         listing.City,
+					Locality:     listing.Locality,
+				}
+			}
+			items = append(items, item)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"favorites": items,
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ImpersonateRequest explains why support needs to act as the user; it is kept in the audit log
+type ImpersonateRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ImpersonateUser issues an admin a short-lived access token acting as a regular user.
+// The token names the admin in its "act" claim and can't be refreshed.
+func ImpersonateUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		adminID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		if _, impersonating := r.Context().Value(middleware.ActorIDKey).(primitive.ObjectID); impersonating {
+			http.Error(w, "Forbidden: not allowed while impersonating", http.StatusForbidden)
+			return
+		}
+		userID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ImpersonateRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.Reason == "" {
+			http.Error(w, "A reason is required to impersonate a user", http.StatusBadRequest)
+			return
+		}
+
+		// Only regular app users can be impersonated, never staff
+		var user models.User
+		err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID, "role": models.RegularUser}).Decode(&user)
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+
+		cfg := config.GetCachedConfig()
+		lifetime := time.Duration(cfg.ImpersonationLifetimeMinutes) * time.Minute
+		token, err := utils.GenerateImpersonationToken(user.ID, string(user.Role), adminID, cfg.JWTSecret, lifetime)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to issue impersonation token")
+			http.Error(w, "Failed to issue impersonation token", http.StatusInternalServerError)
+			return
+		}
+
+		services.RecordAudit(r.Context(), models.AuditLog{
+			Action:    models.AuditImpersonationStart,
+			ActorID:   adminID,
+			SubjectID: user.ID,
+			IP:        utils.ClientIP(r),
+			Details:   map[string]interface{}{"reason": req.Reason, "lifetimeMinutes": cfg.ImpersonationLifetimeMinutes},
+		})
+		logrus.Warn("Admin ", adminID.Hex(), " started impersonating user ", user.ID.Hex())
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":       "Impersonation token issued",
+			"accessToken":   token,
+			"expiresAt":     time.Now().Add(lifetime),
+			"impersonating": user,
+		})
+	}
+}
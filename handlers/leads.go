@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxInquiryLength   = 1000
+	maxInquiriesPerDay = 20
+)
+
+// InquiryRequest is a buyer contacting the lister of a listing
+type InquiryRequest struct {
+	Message      string `json:"message"`
+	ShareContact bool   `json:"shareContact"`
+}
+
+// UpdateLeadRequest is the lister working a lead; fields left out are not changed.
+// An empty followUpAt clears the follow-up date.
+type UpdateLeadRequest struct {
+	Status     *models.LeadStatus `json:"status,omitempty"`
+	FollowUpAt *string            `json:"followUpAt,omitempty"`
+	Note       *string            `json:"note,omitempty"`
+}
+
+// attachLeadContacts fills in the other party of each lead as seen by the lister (forLister)
+// or by the buyer. Listers see the buyer's number when the buyer shared it; buyers only ever
+// see the masked number and proxy code of an active contact reveal.
+func attachLeadContacts(ctx context.Context, leads []models.Lead, forLister bool) {
+	ids := make([]primitive.ObjectID, 0, len(leads))
+	for _, lead := range leads {
+		if forLister {
+			ids = append(ids, lead.BuyerID)
+		} else {
+			ids = append(ids, lead.OwnerID)
+		}
+	}
+	cursor, err := database.GetUserCollection().Find(ctx, bson.M{"_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"name": 1, "phoneNumber": 1}))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load lead contacts")
+		return
+	}
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		logrus.WithError(err).Warn("Failed to decode lead contacts")
+		return
+	}
+	byID := make(map[primitive.ObjectID]models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+	proxies := map[primitive.ObjectID]string{}
+	if !forLister && len(leads) > 0 {
+		propertyIDs := make([]primitive.ObjectID, 0, len(leads))
+		for _, lead := range leads {
+			propertyIDs = append(propertyIDs, lead.PropertyID)
+		}
+		cursor, err = database.GetContactRevealCollection().Find(ctx, bson.M{
+			"buyerId":    leads[0].BuyerID,
+			"propertyId": bson.M{"$in": propertyIDs},
+			"expiresAt":  bson.M{"$gt": time.Now()},
+		})
+		var reveals []models.ContactReveal
+		if err == nil {
+			err = cursor.All(ctx, &reveals)
+		}
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to load contact reveals")
+		}
+		for _, reveal := range reveals {
+			proxies[reveal.PropertyID] = reveal.ProxyID
+		}
+	}
+
+	proxyNumber := config.GetCachedConfig().ContactProxyNumber
+	for i := range leads {
+		lead := &leads[i]
+		otherID := lead.OwnerID
+		if forLister {
+			otherID = lead.BuyerID
+		}
+		user, ok := byID[otherID]
+		if !ok {
+			continue
+		}
+		lead.Contact = &models.LeadContact{ID: user.ID, Name: user.Name}
+		if forLister && lead.RevealBuyerContact() && user.PhoneNumber != nil {
+			lead.Contact.PhoneNumber = *user.PhoneNumber
+		}
+		if proxyID, ok := proxies[lead.PropertyID]; ok {
+			lead.Contact.ProxyNumber, lead.Contact.ProxyID = proxyNumber, proxyID
+		}
+	}
+}
+
+// CreateInquiry sends the authenticated user's inquiry on a published listing to its lister
+func CreateInquiry() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var req InquiryRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		req.Message = strings.TrimSpace(req.Message)
+		if req.Message == "" || len(req.Message) > maxInquiryLength {
+			http.Error(w, fmt.Sprintf("Message is required and at most %d characters", maxInquiryLength), http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": propertyID, "status": property.Published}).Decode(&listing)
+		if err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if listing.OwnerID == userID {
+			http.Error(w, "You can't inquire about your own listing", http.StatusBadRequest)
+			return
+		}
+
+		collection := database.GetLeadCollection()
+		sent, _ := collection.CountDocuments(r.Context(), bson.M{"buyerId": userID, "createdAt": bson.M{"$gte": time.Now().Add(-24 * time.Hour)}})
+		if sent >= maxInquiriesPerDay {
+			http.Error(w, "Too many inquiries today, try again tomorrow", http.StatusTooManyRequests)
+			return
+		}
+
+		now := time.Now()
+		lead := models.Lead{
+			PropertyID:   propertyID,
+			OwnerID:      listing.OwnerID,
+			BuyerID:      userID,
+			Message:      req.Message,
+			Source:       models.LeadSourceInquiry,
+			ShareContact: req.ShareContact,
+			Status:       models.LeadNew,
+			City:         listing.City,
+			Locality:     listing.Locality,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		result, err := collection.InsertOne(r.Context(), lead)
+		if mongo.IsDuplicateKeyError(err) {
+			// A lead logged by revealing the lister's contact turns into this inquiry
+			err = collection.FindOneAndUpdate(r.Context(),
+				bson.M{"propertyId": propertyID, "buyerId": userID, "source": models.LeadSourceContactReveal},
+				bson.M{"$set": bson.M{"message": req.Message, "source": models.LeadSourceInquiry, "shareContact": req.ShareContact, "updatedAt": now}},
+				options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"notes": 0, "followUpAt": 0}),
+			).Decode(&lead)
+			if err != nil {
+				http.Error(w, "You already contacted the lister about this listing", http.StatusConflict)
+				return
+			}
+		} else if err != nil {
+			logrus.WithError(err).Error("Failed to create inquiry")
+			http.Error(w, "Failed to send inquiry", http.StatusInternalServerError)
+			return
+		} else {
+			lead.ID = result.InsertedID.(primitive.ObjectID)
+		}
+
+		var owner models.User
+		if err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": listing.OwnerID}).Decode(&owner); err == nil {
+			err = services.Notify(r.Context(), owner, services.Notice{
+				Kind:  models.NotificationNewLead,
+				Title: "New inquiry on " + listing.Title,
+				Body:  req.Message,
+				Data:  map[string]interface{}{"leadId": lead.ID.Hex(), "propertyId": propertyID.Hex()},
+			})
+		}
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to notify lister about inquiry ", lead.ID.Hex())
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Inquiry sent to the lister",
+			"lead":    lead,
+		})
+	}
+}
+
+// ListMyInquiries returns the inquiries the authenticated user sent, newest first
+func ListMyInquiries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		skip, limit := parsePagination(r.URL.Query())
+
+		cursor, err := database.GetLeadCollection().Find(r.Context(), bson.M{"buyerId": userID},
+			options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit).
+				SetProjection(bson.M{"notes": 0, "followUpAt": 0}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list inquiries")
+			http.Error(w, "Failed to list inquiries", http.StatusInternalServerError)
+			return
+		}
+		leads := []models.Lead{}
+		if err = cursor.All(r.Context(), &leads); err != nil {
+			logrus.WithError(err).Error("Failed to decode inquiries")
+			http.Error(w, "Failed to list inquiries", http.StatusInternalServerError)
+			return
+		}
+		attachLeadContacts(r.Context(), leads, false)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"inquiries": leads,
+		})
+	}
+}
+
+// ListLeads is the lister's leads inbox, most recently active first. It can be filtered by
+// ?status=, ?propertyId= and ?followUpBefore= (RFC 3339) for leads due a follow-up.
+func ListLeads() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		query := r.URL.Query()
+		skip, limit := parsePagination(query)
+
+		filter := bson.M{"ownerId": userID}
+		if status := models.LeadStatus(query.Get("status")); status != "" {
+			if !models.IsValidLeadStatus(status) {
+				http.Error(w, "Unknown lead status", http.StatusBadRequest)
+				return
+			}
+			filter["status"] = status
+		}
+		if value := query.Get("propertyId"); value != "" {
+			propertyID, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+				return
+			}
+			filter["propertyId"] = propertyID
+		}
+		if value := query.Get("followUpBefore"); value != "" {
+			before, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "followUpBefore must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			filter["followUpAt"] = bson.M{"$lte": before}
+		}
+
+		collection := database.GetLeadCollection()
+		total, err := collection.CountDocuments(r.Context(), filter)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to count leads")
+			http.Error(w, "Failed to list leads", http.StatusInternalServerError)
+			return
+		}
+		cursor, err := collection.Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}).SetSkip(skip).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list leads")
+			http.Error(w, "Failed to list leads", http.StatusInternalServerError)
+			return
+		}
+		leads := []models.Lead{}
+		if err = cursor.All(r.Context(), &leads); err != nil {
+			logrus.WithError(err).Error("Failed to decode leads")
+			http.Error(w, "Failed to list leads", http.StatusInternalServerError)
+			return
+		}
+		attachLeadContacts(r.Context(), leads, true)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"leads": leads,
+			"total": total,
+		})
+	}
+}
+
+// UpdateLead changes the status or follow-up date of one of the lister's leads, or adds a note.
+// The buyer is told when the lister first responds.
+func UpdateLead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		leadID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid lead ID", http.StatusBadRequest)
+			return
+		}
+
+		var req UpdateLeadRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		set := bson.M{"updatedAt": now}
+		update := bson.M{"$set": set}
+		if req.Status != nil {
+			if !models.IsValidLeadStatus(*req.Status) {
+				http.Error(w, "Unknown lead status", http.StatusBadRequest)
+				return
+			}
+			set["status"] = *req.Status
+		}
+		if req.FollowUpAt != nil {
+			if *req.FollowUpAt == "" {
+				update["$unset"] = bson.M{"followUpAt": ""}
+			} else {
+				followUpAt, err := time.Parse(time.RFC3339, *req.FollowUpAt)
+				if err != nil {
+					http.Error(w, "followUpAt must be an RFC 3339 time", http.StatusBadRequest)
+					return
+				}
+				set["followUpAt"] = followUpAt
+			}
+		}
+		if req.Note != nil {
+			text := strings.TrimSpace(*req.Note)
+			if text == "" || len(text) > maxInquiryLength {
+				http.Error(w, fmt.Sprintf("Note must be 1 to %d characters", maxInquiryLength), http.StatusBadRequest)
+				return
+			}
+			update["$push"] = bson.M{"notes": models.LeadNote{Text: text, CreatedAt: now}}
+		}
+		if len(update) == 1 && len(set) == 1 {
+			http.Error(w, "Nothing to update", http.StatusBadRequest)
+			return
+		}
+
+		var previous models.Lead
+		err = database.GetLeadCollection().FindOneAndUpdate(r.Context(), bson.M{"_id": leadID, "ownerId": userID}, update).Decode(&previous)
+		if err != nil {
+			http.Error(w, "Lead not found", http.StatusNotFound)
+			return
+		}
+
+		if previous.Status == models.LeadNew && req.Status != nil && *req.Status != models.LeadNew && *req.Status != models.LeadLost {
+			var buyer models.User
+			if err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": previous.BuyerID}).Decode(&buyer); err == nil {
+				err = services.Notify(r.Context(), buyer, services.Notice{
+					Kind:  models.NotificationLeadUpdate,
+					Title: "The lister responded to your inquiry",
+					Body:  "Reply to them in chat, or call them from the listing through our masked number.",
+					Data:  map[string]interface{}{"leadId": previous.ID.Hex(), "propertyId": previous.PropertyID.Hex()},
+				})
+			}
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to notify buyer about lead ", previous.ID.Hex())
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{"message": "Lead updated"})
+	}
+}
+
+// ListLeadsForStaff returns leads on listings in the caller's scope, newest first, optionally
+// filtered by ?status=. Contact details are left out.
+func ListLeadsForStaff() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+		skip, limit := parsePagination(r.URL.Query())
+
+		filter := models.ScopeFilter(staff, "city", "locality")
+		if status := models.LeadStatus(r.URL.Query().Get("status")); status != "" {
+			filter["status"] = status
+		}
+		cursor, err := database.GetLeadCollection().Find(r.Context(), filter, options.Find().
+			SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit).
+			SetProjection(bson.M{"notes": 0}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list leads for staff")
+			http.Error(w, "Failed to list leads", http.StatusInternalServerError)
+			return
+		}
+		leads := []models.Lead{}
+		if err = cursor.All(r.Context(), &leads); err != nil {
+			logrus.WithError(err).Error("Failed to decode leads for staff")
+			http.Error(w, "Failed to list leads", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"leads": leads,
+		})
+	}
+}
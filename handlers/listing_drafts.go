@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// markCompleteness fills in the completeness of the drafts among listings
+func markCompleteness(listings []property.Property) {
+	for i := range listings {
+		if listings[i].Status == property.Draft {
+			completeness := listings[i].CheckCompleteness()
+			listings[i].Completeness = &completeness
+		}
+	}
+}
+
+// CreateDraft starts an empty listing draft for the authenticated user, to be filled in step by
+// step. Drafts count towards the active listing limit.
+func CreateDraft() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		if !checkListingLimit(w, r, userID) {
+			return
+		}
+
+		now := time.Now()
+		draft := property.Property{
+			OwnerID:   userID,
+			Status:    property.Draft,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		result, err := database.GetPropertyCollection().InsertOne(r.Context(), draft)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create draft")
+			http.Error(w, "Failed to create draft", http.StatusInternalServerError)
+			return
+		}
+		draft.ID = result.InsertedID.(primitive.ObjectID)
+		completeness := draft.CheckCompleteness()
+		draft.Completeness = &completeness
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Draft created",
+			"listing": draft,
+		})
+	}
+}
+
+// ListMyDrafts returns the authenticated user's drafts, most recently saved first, with what each is missing
+func ListMyDrafts() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		cursor, err := database.GetPropertyCollection().Find(r.Context(), bson.M{"ownerId": userID, "status": property.Draft},
+			options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list drafts")
+			http.Error(w, "Failed to load drafts", http.StatusInternalServerError)
+			return
+		}
+		drafts := []property.Property{}
+		if err = cursor.All(r.Context(), &drafts); err != nil {
+			logrus.WithError(err).Error("Failed to decode drafts")
+			http.Error(w, "Failed to load drafts", http.StatusInternalServerError)
+			return
+		}
+		markCompleteness(drafts)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"drafts": drafts,
+		})
+	}
+}
+
+// SaveDraftStep saves one step of the listing form into a draft, checking only that step's fields.
+// A step may be saved any number of times, in any order, from any device.
+func SaveDraftStep() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+		step := mux.Vars(r)["step"]
+		if !property.IsValidStep(step) {
+			http.Error(w, "Step must be one of "+strings.Join(property.ListingSteps, ", "), http.StatusBadRequest)
+			return
+		}
+		var req CreateListingRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		var draft property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID, "ownerId": userID, "status": property.Draft}).Decode(&draft)
+		if err != nil {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+
+		edited := draft
+		switch step {
+		case property.StepBasics:
+			if strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.PropertyType) == "" {
+				http.Error(w, "title and propertyType are required", http.StatusBadRequest)
+				return
+			}
+			if req.ListingType != "sale" && req.ListingType != "rent" {
+				http.Error(w, "listingType must be sale or rent", http.StatusBadRequest)
+				return
+			}
+			edited.Title = strings.TrimSpace(req.Title)
+			edited.PropertyType = strings.TrimSpace(req.PropertyType)
+			if req.ListingType != draft.ListingType {
+				// A sale price isn't a rent: the pricing step is filled in again
+				edited.ListingType = req.ListingType
+				edited.Price, edited.Rental = 0, nil
+			}
+		case property.StepLocation:
+			location, ok := resolveLocation(w, r, req.LocalityID, req.City, req.Locality)
+			if !ok {
+				return
+			}
+			edited.CityID, edited.LocalityID = location.IDs()
+			edited.City, edited.Locality = location.Names()
+			edited.Address = strings.TrimSpace(req.Address)
+			if edited.Location, err = req.pin(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		case property.StepDetails:
+			if req.AreaSqft < 0 || req.Bedrooms < 0 {
+				http.Error(w, "areaSqft and bedrooms can't be negative", http.StatusBadRequest)
+				return
+			}
+			edited.AreaSqft, edited.Bedrooms, edited.Floor = req.AreaSqft, req.Bedrooms, req.Floor
+			edited.Description = strings.TrimSpace(req.Description)
+		case property.StepPricing:
+			switch draft.ListingType {
+			case "sale":
+				if req.Rental != nil {
+					http.Error(w, "rental terms only apply to rent listings", http.StatusBadRequest)
+					return
+				}
+				edited.Price = req.Price
+			case "rent":
+				if req.Rental == nil {
+					http.Error(w, "rent listings need rental terms", http.StatusBadRequest)
+					return
+				}
+				if err = req.Rental.Validate(); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				edited.Rental, edited.Price = req.Rental, req.Rental.MonthlyRent
+			default:
+				http.Error(w, "Save the basics step first", http.StatusBadRequest)
+				return
+			}
+			if edited.Price <= 0 {
+				http.Error(w, "price must be positive", http.StatusBadRequest)
+				return
+			}
+		case property.StepAmenities:
+			amenityIDs, ok := loadAmenityIDs(w, r, req.AmenityIDs, draft.PropertyType)
+			if !ok {
+				return
+			}
+			edited.AmenityIDs = amenityIDs
+			if edited.AmenityLabels, err = services.AmenityLabels(r.Context(), amenityIDs); err != nil {
+				logrus.WithError(err).Warn("Failed to load amenity labels for the text index")
+			}
+		case property.StepPhotos:
+			if err = checkListingPhotos(userID, req.Images, draft.Images); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			edited.Images = req.Images
+		}
+
+		// Drafts have no history: their versions start once submitted
+		changes, err := services.ListingChanges(draft, edited)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to compare draft edits")
+			http.Error(w, "Failed to save draft", http.StatusInternalServerError)
+			return
+		}
+		set, unset := changeSet(changes)
+		edited.UpdatedAt = time.Now()
+		set["updatedAt"] = edited.UpdatedAt
+		if step == property.StepAmenities {
+			set["amenityLabels"] = edited.AmenityLabels
+		}
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		result, err := database.GetPropertyCollection().UpdateOne(r.Context(), bson.M{"_id": listingID, "status": property.Draft}, update)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to save draft step")
+			http.Error(w, "Failed to save draft", http.StatusInternalServerError)
+			return
+		}
+		if result.MatchedCount == 0 {
+			http.Error(w, "Listing is no longer a draft", http.StatusConflict)
+			return
+		}
+
+		completeness := edited.CheckCompleteness()
+		edited.Completeness = &completeness
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Draft saved",
+			"listing": edited,
+		})
+	}
+}
+
+// submitDraft moves a complete draft to pending review, as its owner, and returns it as it is after
+// the quality checks. It writes the error response and returns false when the draft can't be submitted.
+func submitDraft(w http.ResponseWriter, r *http.Request, draft property.Property, userID primitive.ObjectID) (property.Property, bool) {
+	if completeness := draft.CheckCompleteness(); !completeness.Ready {
+		http.Error(w, "Draft is incomplete, continue with the "+completeness.NextStep+" step", http.StatusBadRequest)
+		return draft, false
+	}
+	if err := validateListing(listingRequest(draft)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return draft, false
+	}
+	if !property.CanTransition(draft.Status, property.PendingReview) {
+		http.Error(w, "Listing can't move from "+string(draft.Status)+" to "+string(property.PendingReview), http.StatusConflict)
+		return draft, false
+	}
+
+	submittedAt := time.Now()
+	result, err := database.GetPropertyCollection().UpdateOne(r.Context(),
+		bson.M{"_id": draft.ID, "status": property.Draft},
+		bson.M{"$set": bson.M{"status": property.PendingReview, "updatedAt": submittedAt}})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to submit draft")
+		http.Error(w, "Failed to submit draft", http.StatusInternalServerError)
+		return draft, false
+	}
+	if result.ModifiedCount == 0 {
+		http.Error(w, "Listing was changed meanwhile, reload and try again", http.StatusConflict)
+		return draft, false
+	}
+	services.RecordListingUpdate(r.Context(), draft, userID, models.ChangedByOwner)
+	draft.Status, draft.UpdatedAt = property.PendingReview, submittedAt
+	return screenListing(r, draft), true
+}
+
+// SubmitDraft sends a complete draft for review
+func SubmitDraft() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var draft property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID, "ownerId": userID, "status": property.Draft}).Decode(&draft)
+		if err != nil {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+		draft, ok := submitDraft(w, r, draft, userID)
+		if !ok {
+			return
+		}
+
+		message := "Listing submitted for review"
+		if draft.Status == property.Rejected {
+			message = draft.RejectionReason
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": message,
+			"status":  draft.Status,
+			"quality": draft.Quality,
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RenewListing extends one of the authenticated user's listings by another period of their plan.
+// Published listings can be renewed once they are within the expiry notice period; expired ones
+// go back to review, within the active listing limit, and get their new period when approved.
+func RenewListing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID, "ownerId": userID}).Decode(&listing)
+		if err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+
+		now := time.Now()
+		set := bson.M{"updatedAt": now}
+		switch listing.Status {
+		case property.Published:
+			noticeDays := config.GetCachedConfig().ExpiryNoticeDays
+			if listing.ExpiresAt.After(now.AddDate(0, 0, noticeDays)) {
+				http.Error(w, fmt.Sprintf("Listings can be renewed from %d days before they expire", noticeDays), http.StatusConflict)
+				return
+			}
+			// A renewal early in the notice period still adds a full period
+			from := now
+			if listing.ExpiresAt.After(now) {
+				from = listing.ExpiresAt
+			}
+			set["expiresAt"] = services.ListingExpiry(r.Context(), userID, from)
+		case property.Expired:
+			if !property.CanTransition(listing.Status, property.PendingReview) {
+				http.Error(w, "Listing can't move from "+string(listing.Status)+" to "+string(property.PendingReview), http.StatusConflict)
+				return
+			}
+			if !checkListingLimit(w, r, userID) {
+				return
+			}
+			set["status"] = property.PendingReview
+		default:
+			http.Error(w, "Only published or expired listings can be renewed", http.StatusConflict)
+			return
+		}
+
+		result, err := database.GetPropertyCollection().UpdateOne(r.Context(),
+			bson.M{"_id": listingID, "status": listing.Status},
+			bson.M{"$set": set, "$unset": bson.M{"expiryNoticeAt": ""}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to renew listing")
+			http.Error(w, "Failed to renew listing", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Listing was changed meanwhile, reload and try again", http.StatusConflict)
+			return
+		}
+		services.RecordListingUpdate(r.Context(), listing, userID, models.ChangedByOwner)
+		services.RefreshListingProject(r.Context(), listing)
+
+		if listing.Status == property.Expired {
+			listing.Status, listing.UpdatedAt = property.PendingReview, now
+			listing = screenListing(r, listing)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": "Listing sent for review",
+				"status":  listing.Status,
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":   "Listing renewed",
+			"expiresAt": set["expiresAt"],
+		})
+	}
+}
+
+// RefreshListing moves one of the authenticated user's published listings back to the top of
+// search, as many times a day as their plan allows across all their listings
+func RefreshListing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var user models.User
+		if err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID}).Decode(&user); err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID, "ownerId": userID}).Decode(&listing)
+		if err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if listing.Status != property.Published {
+			http.Error(w, "Only published listings can be refreshed", http.StatusConflict)
+			return
+		}
+
+		// The refresh is counted before the listing moves up, so concurrent ones can't pass the limit
+		now := time.Now()
+		limit := models.DailyRefreshLimit(user)
+		counters, counterKey := database.GetListingRefreshCounterCollection(), bson.M{"ownerId": userID}
+		refreshed, allowed, err := services.ClaimDailyCount(r.Context(), counters, counterKey, limit, now)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to count listing refresh")
+			http.Error(w, "Failed to refresh listing", http.StatusInternalServerError)
+			return
+		}
+		if !allowed {
+			http.Error(w, fmt.Sprintf("You can refresh listings at most %d times a day on your plan", limit), http.StatusTooManyRequests)
+			return
+		}
+
+		result, err := database.GetPropertyCollection().UpdateOne(r.Context(),
+			bson.M{"_id": listingID, "status": property.Published},
+			bson.M{"$set": bson.M{"bumpedAt": now}})
+		if err != nil || result.MatchedCount == 0 {
+			if releaseErr := services.ReleaseDailyCount(r.Context(), counters, counterKey, now); releaseErr != nil {
+				logrus.WithError(releaseErr).Warn("Failed to release listing refresh count")
+			}
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to refresh listing")
+			http.Error(w, "Failed to refresh listing", http.StatusInternalServerError)
+			return
+		}
+		if result.MatchedCount == 0 {
+			http.Error(w, "Listing was changed meanwhile, reload and try again", http.StatusConflict)
+			return
+		}
+		_, err = database.GetListingRefreshCollection().InsertOne(r.Context(), models.ListingRefresh{
+			PropertyID: listingID,
+			OwnerID:    userID,
+			CreatedAt:  now,
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to record listing refresh")
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":         "Listing moved to the top of search",
+			"refreshesLeft":   limit - refreshed,
+			"refreshesPerDay": limit,
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReviewListingRequest is a moderation decision on a listing
+type ReviewListingRequest struct {
+	Action string `json:"action"` // approve or reject
+	Reason string `json:"reason,omitempty"`
+}
+
+// ListListingsForReview returns listings in the caller's scope, pending review unless ?status= says otherwise;
+// ?duplicates=true keeps only listings grouped with likely duplicates and ?flagged=true only those
+// failing a hard quality check or likely giving a phone number
+func ListListingsForReview() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+
+		status := property.Status(r.URL.Query().Get("status"))
+		if status == "" {
+			status = property.PendingReview
+		}
+		filter := models.ScopeFilter(staff, "city", "locality")
+		filter["status"] = status
+		if r.URL.Query().Get("duplicates") == "true" {
+			filter["duplicateGroupId"] = bson.M{"$exists": true}
+		}
+		if r.URL.Query().Get("flagged") == "true" {
+			filter["quality.flagged"] = true
+		}
+
+		cursor, err := database.GetPropertyCollection().Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}}).SetLimit(100))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load listings for review")
+			http.Error(w, "Failed to load listings", http.StatusInternalServerError)
+			return
+		}
+		listings := []property.Property{}
+		if err = cursor.All(r.Context(), &listings); err != nil {
+			logrus.WithError(err).Error("Failed to decode listings for review")
+			http.Error(w, "Failed to load listings", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"listings": listings,
+		})
+	}
+}
+
+// ReviewListing approves or rejects a pending listing inside the caller's scope
+func ReviewListing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ReviewListingRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		var next property.Status
+		switch req.Action {
+		case "approve":
+			next = property.Published
+		case "reject":
+			if req.Reason == "" {
+				http.Error(w, "A reason is required to reject a listing", http.StatusBadRequest)
+				return
+			}
+			next = property.Rejected
+		default:
+			http.Error(w, "Action must be approve or reject", http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID}).Decode(&listing)
+		if err != nil || !models.InScope(staff, listing.City, listing.Locality) {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if !property.CanTransition(listing.Status, next) {
+			http.Error(w, "Listing can't move from "+string(listing.Status)+" to "+string(next), http.StatusConflict)
+			return
+		}
+
+		now := time.Now()
+		update := bson.M{"status": next, "reviewedBy": staff.ID, "updatedAt": now, "rejectionReason": req.Reason}
+		changes := bson.M{"$set": update}
+		// Re-approving an edit keeps the listing's place in search and its expiry: only the first
+		// publish and a renewal after it expired start a new period at the top
+		if next == property.Published && (listing.PublishedAt.IsZero() || !listing.ExpiresAt.After(now)) {
+			update["publishedAt"], update["bumpedAt"] = now, now
+			update["expiresAt"] = services.ListingExpiry(r.Context(), listing.OwnerID, now)
+			changes["$unset"] = bson.M{"expiryNoticeAt": ""}
+		}
+		// Match on the status we checked so two reviewers can't both act on the listing
+		result, err := database.GetPropertyCollection().UpdateOne(r.Context(), bson.M{"_id": listingID, "status": listing.Status}, changes)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to review listing")
+			http.Error(w, "Failed to review listing", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Listing was changed by someone else, reload and try again", http.StatusConflict)
+			return
+		}
+
+		services.RecordListingUpdate(r.Context(), listing, staff.ID, models.ChangedByStaff)
+		services.RefreshListingProject(r.Context(), listing)
+		logrus.Info("Listing ", listingID.Hex(), " ", next, " by ", staff.ID.Hex())
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Listing " + string(next),
+			"status":  next,
+		})
+	}
+}
+
+// GetListingHistory returns a listing in the caller's scope with its versions, newest first, so
+// reviewers can see what an edit changed
+func GetListingHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listing, _, ok := loadScopedListing(w, r)
+		if !ok {
+			return
+		}
+		cursor, err := database.GetListingVersionCollection().Find(r.Context(), bson.M{"propertyId": listing.ID},
+			options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load listing versions")
+			http.Error(w, "Failed to load history", http.StatusInternalServerError)
+			return
+		}
+		versions := []models.ListingVersion{}
+		if err = cursor.All(r.Context(), &versions); err != nil {
+			logrus.WithError(err).Error("Failed to decode listing versions")
+			http.Error(w, "Failed to load history", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"listing":  listing,
+			"versions": versions,
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const maxListingPhotoBytes = 10 * 1024 * 1024
+
+// listingPhotoFolder is the folder of the upload directory a user's listing photos are stored in
+func listingPhotoFolder(userID primitive.ObjectID) string {
+	return "listings/" + userID.Hex()
+}
+
+// checkListingPhotos returns an error unless every image is a photo the user uploaded for their
+// listings. Images the listing already shows are kept as they are.
+func checkListingPhotos(userID primitive.ObjectID, images, current []string) error {
+	prefix := services.UploadURLPrefix + listingPhotoFolder(userID) + "/"
+	for _, image := range images {
+		if slices.Contains(current, image) {
+			continue
+		}
+		name, ok := strings.CutPrefix(image, prefix)
+		if !ok || !isStoredFileName(name) || !services.UploadExists(image) {
+			return fmt.Errorf("images must be photos uploaded to /api/listings/photos")
+		}
+	}
+	return nil
+}
+
+// UploadListingPhoto stores a "photo" image of a multipart form for one of the authenticated
+// user's listings and returns the URL to put in its images
+func UploadListingPhoto() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		data, ext, err := readUpload(w, r, "photo", maxListingPhotoBytes, imageExtensions)
+		if err != nil {
+			http.Error(w, "Invalid photo: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		url, err := services.SaveUpload(listingPhotoFolder(userID), data, ext)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to store listing photo")
+			http.Error(w, "Failed to store photo", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"url": url})
+	}
+}
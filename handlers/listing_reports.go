@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxReportDetailsLength = 1000
+
+// ReportListingRequest is a user reporting a listing
+type ReportListingRequest struct {
+	Reason  string `json:"reason"` // fake, duplicate, already_sold, wrong_info, fraud or other
+	Details string `json:"details,omitempty"`
+}
+
+// ResolveListingReportsRequest is a staff decision on every open report of a listing
+type ResolveListingReportsRequest struct {
+	Action string `json:"action"` // uphold or dismiss
+	Note   string `json:"note,omitempty"`
+}
+
+// ReportedListing is a listing in the staff report queue with a summary of its open reports
+type ReportedListing struct {
+	PropertyID      primitive.ObjectID `json:"propertyId" bson:"_id"`
+	Reports         int                `json:"reports" bson:"reports"`
+	Reasons         map[string]int     `json:"reasons" bson:"-"`
+	ReasonList      []string           `json:"-" bson:"reasons"`
+	FirstReportedAt time.Time          `json:"firstReportedAt" bson:"firstReportedAt"`
+	LastReportedAt  time.Time          `json:"lastReportedAt" bson:"lastReportedAt"`
+	Listing         *property.Property `json:"listing,omitempty" bson:"-"`
+}
+
+// notifyUser sends an in-app notice to a user by ID, logging failures
+func notifyUser(ctx context.Context, userID primitive.ObjectID, notice services.Notice) {
+	var user models.User
+	err := database.GetUserCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err == nil {
+		err = services.Notify(ctx, user, notice)
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to send ", notice.Kind, " notification to ", userID.Hex())
+	}
+}
+
+// ReportListing records the authenticated user's report on a listing. Once enough different users
+// reported it, the listing is suspended until staff review the reports.
+func ReportListing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+		var req ReportListingRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || !models.IsValidReportReason(req.Reason) {
+			http.Error(w, "Reason must be fake, duplicate, already_sold, wrong_info, fraud or other", http.StatusBadRequest)
+			return
+		}
+		req.Details = strings.TrimSpace(req.Details)
+		if len(req.Details) > maxReportDetailsLength || (req.Reason == models.ReportOther && req.Details == "") {
+			http.Error(w, fmt.Sprintf("Details are required for other reasons and at most %d characters", maxReportDetailsLength), http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": propertyID, "status": bson.M{"$in": []property.Status{property.Published, property.Suspended}}}).Decode(&listing)
+		if err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if listing.OwnerID == userID {
+			http.Error(w, "You can't report your own listing", http.StatusBadRequest)
+			return
+		}
+
+		collection := database.GetListingReportCollection()
+		report := models.ListingReport{
+			PropertyID: propertyID,
+			ReporterID: userID,
+			Reason:     req.Reason,
+			Details:    req.Details,
+			Status:     models.ReportOpen,
+			City:       listing.City,
+			Locality:   listing.Locality,
+			CreatedAt:  time.Now(),
+		}
+		result, err := collection.InsertOne(r.Context(), report)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "You already reported this listing", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to save listing report")
+			http.Error(w, "Failed to report listing", http.StatusInternalServerError)
+			return
+		}
+		report.ID = result.InsertedID.(primitive.ObjectID)
+
+		open, err := collection.CountDocuments(r.Context(), bson.M{"propertyId": propertyID, "status": models.ReportOpen})
+		if err == nil && listing.Status == property.Published && open >= int64(config.GetCachedConfig().ListingReportThreshold) {
+			suspended, err := database.GetPropertyCollection().UpdateOne(r.Context(),
+				bson.M{"_id": propertyID, "status": property.Published},
+				bson.M{"$set": bson.M{"status": property.Suspended, "updatedAt": time.Now()}})
+			if err != nil {
+				logrus.WithError(err).Error("Failed to suspend reported listing ", propertyID.Hex())
+			} else if suspended.ModifiedCount > 0 {
+				logrus.Info("Listing ", propertyID.Hex(), " suspended after ", open, " reports")
+				services.RecordListingUpdate(r.Context(), listing, primitive.NilObjectID, models.ChangedBySystem)
+				notifyUser(r.Context(), listing.OwnerID, services.Notice{
+					Kind:  models.NotificationListingReport,
+					Title: "Your listing is on hold",
+					Body:  "\"" + listing.Title + "\" was reported by several users and is hidden until our team reviews it.",
+					Data:  map[string]interface{}{"propertyId": propertyID.Hex()},
+				})
+			}
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Thanks, our team will review this listing",
+			"report":  report,
+		})
+	}
+}
+
+// ListMyListingReports returns the authenticated user's reports with their outcomes, newest first
+func ListMyListingReports() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		skip, limit := parsePagination(r.URL.Query())
+
+		cursor, err := database.GetListingReportCollection().Find(r.Context(), bson.M{"reporterId": userID},
+			options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list user reports")
+			http.Error(w, "Failed to list reports", http.StatusInternalServerError)
+			return
+		}
+		reports := []models.ListingReport{}
+		if err = cursor.All(r.Context(), &reports); err != nil {
+			logrus.WithError(err).Error("Failed to decode user reports")
+			http.Error(w, "Failed to list reports", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reports": reports,
+		})
+	}
+}
+
+// ListReportedListings is the staff queue of listings in the caller's scope with open reports,
+// most reported first
+func ListReportedListings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+
+		match := models.ScopeFilter(staff, "city", "locality")
+		match["status"] = models.ReportOpen
+		cursor, err := database.GetListingReportCollection().Aggregate(r.Context(), mongo.Pipeline{
+			{{Key: "$match", Value: match}},
+			{{Key: "$group", Value: bson.M{
+				"_id":             "$propertyId",
+				"reports":         bson.M{"$sum": 1},
+				"reasons":         bson.M{"$push": "$reason"},
+				"firstReportedAt": bson.M{"$min": "$createdAt"},
+				"lastReportedAt":  bson.M{"$max": "$createdAt"},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "reports", Value: -1}, {Key: "firstReportedAt", Value: 1}}}},
+			{{Key: "$limit", Value: 100}},
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to group listing reports")
+			http.Error(w, "Failed to load reports", http.StatusInternalServerError)
+			return
+		}
+		queue := []ReportedListing{}
+		if err = cursor.All(r.Context(), &queue); err != nil {
+			logrus.WithError(err).Error("Failed to decode listing report queue")
+			http.Error(w, "Failed to load reports", http.StatusInternalServerError)
+			return
+		}
+
+		ids := make([]primitive.ObjectID, 0, len(queue))
+		for _, item := range queue {
+			ids = append(ids, item.PropertyID)
+		}
+		listings := map[primitive.ObjectID]*property.Property{}
+		if cursor, err = database.GetPropertyCollection().Find(r.Context(), bson.M{"_id": bson.M{"$in": ids}}); err == nil {
+			var found []property.Property
+			if err = cursor.All(r.Context(), &found); err == nil {
+				for i := range found {
+					listings[found[i].ID] = &found[i]
+				}
+			}
+		}
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to load reported listings")
+		}
+		for i := range queue {
+			queue[i].Reasons = map[string]int{}
+			for _, reason := range queue[i].ReasonList {
+				queue[i].Reasons[reason]++
+			}
+			queue[i].Listing = listings[queue[i].PropertyID]
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"listings": queue,
+		})
+	}
+}
+
+// loadScopedListing loads the listing named by the {id} path variable if it is in the staff caller's scope
+func loadScopedListing(w http.ResponseWriter, r *http.Request) (property.Property, models.User, bool) {
+	staff := r.Context().Value(middleware.StaffKey).(models.User)
+	var listing property.Property
+	listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+		return listing, staff, false
+	}
+	err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID}).Decode(&listing)
+	if err != nil || !models.InScope(staff, listing.City, listing.Locality) {
+		http.Error(w, "Listing not found", http.StatusNotFound)
+		return listing, staff, false
+	}
+	return listing, staff, true
+}
+
+// GetListingReports returns a listing in the caller's scope with all its open reports
+func GetListingReports() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listing, _, ok := loadScopedListing(w, r)
+		if !ok {
+			return
+		}
+		cursor, err := database.GetListingReportCollection().Find(r.Context(), bson.M{"propertyId": listing.ID, "status": models.ReportOpen},
+			options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load listing reports")
+			http.Error(w, "Failed to load reports", http.StatusInternalServerError)
+			return
+		}
+		reports := []models.ListingReport{}
+		if err = cursor.All(r.Context(), &reports); err != nil {
+			logrus.WithError(err).Error("Failed to decode listing reports")
+			http.Error(w, "Failed to load reports", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"listing": listing,
+			"reports": reports,
+		})
+	}
+}
+
+// ResolveListingReports closes every open report of a listing. Upholding them takes the listing
+// down; dismissing them puts a suspended listing back up. Reporters and the lister are told the outcome.
+func ResolveListingReports() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		listing, staff, ok := loadScopedListing(w, r)
+		if !ok {
+			return
+		}
+		var req ResolveListingReportsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		var status, outcome string
+		var next property.Status
+		listerNotice := services.Notice{Kind: models.NotificationListingReport, Data: map[string]interface{}{"propertyId": listing.ID.Hex()}}
+		switch req.Action {
+		case "uphold":
+			status, next = models.ReportUpheld, property.Rejected
+			outcome = "We reviewed the listing you reported and took it down. Thanks for helping keep listings genuine."
+			listerNotice.Title = "Your listing was taken down"
+			listerNotice.Body = "\"" + listing.Title + "\" was removed after a review of user reports."
+			if req.Note != "" {
+				listerNotice.Body += " " + req.Note
+			}
+		case "dismiss":
+			status, next = models.ReportDismissed, property.Published
+			outcome = "We reviewed the listing you reported and found it meets our guidelines."
+			listerNotice.Title = "Your listing is back up"
+			listerNotice.Body = "\"" + listing.Title + "\" was reviewed and is visible again."
+		default:
+			http.Error(w, "Action must be uphold or dismiss", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		changed := false
+		// Listings that were sold or taken down meanwhile only get their reports closed
+		if (next == property.Rejected || listing.Status == property.Suspended) && listing.Status != next && property.CanTransition(listing.Status, next) {
+			update := bson.M{"status": next, "reviewedBy": staff.ID, "updatedAt": now}
+			if next == property.Rejected {
+				update["rejectionReason"] = "Removed after user reports"
+			}
+			result, err := database.GetPropertyCollection().UpdateOne(r.Context(), bson.M{"_id": listing.ID, "status": listing.Status}, bson.M{"$set": update})
+			if err != nil {
+				logrus.WithError(err).Error("Failed to update reported listing")
+				http.Error(w, "Failed to resolve reports", http.StatusInternalServerError)
+				return
+			}
+			if result.ModifiedCount == 0 {
+				http.Error(w, "Listing was changed by someone else, reload and try again", http.StatusConflict)
+				return
+			}
+			changed = true
+			services.RecordListingUpdate(r.Context(), listing, staff.ID, models.ChangedByStaff)
+		}
+
+		reports, err := database.GetListingReportCollection().Distinct(r.Context(), "reporterId", bson.M{"propertyId": listing.ID, "status": models.ReportOpen})
+		if err == nil {
+			_, err = database.GetListingReportCollection().UpdateMany(r.Context(),
+				bson.M{"propertyId": listing.ID, "status": models.ReportOpen},
+				bson.M{"$set": bson.M{"status": status, "outcome": outcome, "resolvedBy": staff.ID, "resolvedAt": now}})
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to close listing reports")
+			http.Error(w, "Failed to resolve reports", http.StatusInternalServerError)
+			return
+		}
+
+		for _, value := range reports {
+			if reporterID, ok := value.(primitive.ObjectID); ok {
+				notifyUser(r.Context(), reporterID, services.Notice{
+					Kind:  models.NotificationListingReport,
+					Title: "Update on your report",
+					Body:  outcome,
+					Data:  map[string]interface{}{"propertyId": listing.ID.Hex()},
+				})
+			}
+		}
+		if changed {
+			notifyUser(r.Context(), listing.OwnerID, listerNotice)
+		}
+		services.RecordAudit(r.Context(), models.AuditLog{
+			Action:    models.AuditListingReportsClosed,
+			ActorID:   staff.ID,
+			SubjectID: listing.ID,
+			IP:        utils.ClientIP(r),
+			Details:   map[string]interface{}{"action": req.Action, "reports": len(reports), "note": req.Note},
+		})
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": fmt.Sprintf("%d reports %s", len(reports), status),
+			"status":  status,
+		})
+	}
+}
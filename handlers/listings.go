@@ -0,0 +1,714 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Statuses that count towards a user's active listing limit
+var activeListingStatuses = []property.Status{property.Draft, property.PendingReview, property.Published}
+
+// CreateListingRequest is the payload for posting a listing
+type CreateListingRequest struct {
+	Title        string                `json:"title"`
+	Description  string                `json:"description"`
+	PropertyType string                `json:"propertyType"`
+	ListingType  string                `json:"listingType"`
+	Price        float64               `json:"price"`
+	AreaSqft     float64               `json:"areaSqft"`
+	Bedrooms     int                   `json:"bedrooms"`
+	Floor        *int                  `json:"floor,omitempty"`
+	Address      string                `json:"address"`
+	City         string                `json:"city"`
+	Locality     string                `json:"locality"`
+	LocalityID   string                `json:"localityId,omitempty"` // city or locality picked in the autocomplete, instead of the names
+	Latitude     *float64              `json:"latitude,omitempty"`   // pin on the map, with longitude
+	Longitude    *float64              `json:"longitude,omitempty"`
+	Images       []string              `json:"images,omitempty"`
+	AmenityIDs   []string              `json:"amenityIds,omitempty"`
+	Rental       *property.RentalTerms `json:"rental,omitempty"`    // required for rent listings; its monthlyRent is the price
+	ProjectID    string                `json:"projectId,omitempty"` // posts a unit configuration of one of the builder's projects
+	UnitType     string                `json:"unitType,omitempty"`
+	Tower        string                `json:"tower,omitempty"`
+}
+
+// parsePagination reads page (from 1) and limit (at most 50) query parameters into skip/limit
+func parsePagination(query url.Values) (int64, int64) {
+	page, _ := strconv.ParseInt(query.Get("page"), 10, 64)
+	limit, _ := strconv.ParseInt(query.Get("limit"), 10, 64)
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+	return (page - 1) * limit, limit
+}
+
+// attachListers fills in the public lister summary, with verified badge, of each listing
+func attachListers(ctx context.Context, listings []property.Property) {
+	ownerIDs := make([]primitive.ObjectID, 0, len(listings))
+	for _, listing := range listings {
+		ownerIDs = append(ownerIDs, listing.OwnerID)
+	}
+	cursor, err := database.GetUserCollection().Find(ctx, bson.M{"_id": bson.M{"$in": ownerIDs}},
+		options.Find().SetProjection(bson.M{"name": 1, "userType": 1, "professional": 1, "verification.status": 1}))
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to load listers")
+		return
+	}
+	var owners []models.User
+	if err = cursor.All(ctx, &owners); err != nil {
+		logrus.WithError(err).Warn("Failed to decode listers")
+		return
+	}
+
+	listers := make(map[primitive.ObjectID]*property.ListerSummary, len(owners))
+	for _, owner := range owners {
+		lister := &property.ListerSummary{ID: owner.ID, Name: owner.Name, UserType: string(owner.UserType), Verified: owner.IsVerified()}
+		if owner.Professional != nil {
+			lister.AgencyName = owner.Professional.AgencyName
+		}
+		listers[owner.ID] = lister
+	}
+	for i := range listings {
+		listings[i].Lister = listers[listings[i].OwnerID]
+	}
+}
+
+// validateListing checks the fields every listing needs
+func validateListing(req CreateListingRequest) error {
+	if strings.TrimSpace(req.Title) == "" || strings.TrimSpace(req.City) == "" || strings.TrimSpace(req.PropertyType) == "" {
+		return fmt.Errorf("title, city and propertyType are required")
+	}
+	switch req.ListingType {
+	case "sale":
+		if req.Rental != nil {
+			return fmt.Errorf("rental terms only apply to rent listings")
+		}
+	case "rent":
+		if req.Rental == nil {
+			return fmt.Errorf("rent listings need rental terms")
+		}
+		if err := req.Rental.Validate(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("listingType must be sale or rent")
+	}
+	if req.Price <= 0 {
+		return fmt.Errorf("price must be positive")
+	}
+	if req.AreaSqft < 0 || req.Bedrooms < 0 {
+		return fmt.Errorf("areaSqft and bedrooms can't be negative")
+	}
+	return nil
+}
+
+// checkListingLimit reports whether the user may have one more active listing. It writes the error
+// response and returns false otherwise.
+func checkListingLimit(w http.ResponseWriter, r *http.Request, userID primitive.ObjectID) bool {
+	var user models.User
+	err := database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID, "role": models.RegularUser}).Decode(&user)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return false
+	}
+	limit := models.ActiveListingLimit(user)
+	active, err := database.GetPropertyCollection().CountDocuments(r.Context(), bson.M{"ownerId": userID, "status": bson.M{"$in": activeListingStatuses}})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to count active listings")
+		http.Error(w, "Failed to create listing", http.StatusInternalServerError)
+		return false
+	}
+	if active >= int64(limit) {
+		http.Error(w, fmt.Sprintf("Active listing limit reached (%d) for your account type", limit), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// listingRequest returns the fields of a stored listing that validateListing checks
+func listingRequest(listing property.Property) CreateListingRequest {
+	return CreateListingRequest{
+		Title:        listing.Title,
+		City:         listing.City,
+		PropertyType: listing.PropertyType,
+		ListingType:  listing.ListingType,
+		Price:        listing.Price,
+		AreaSqft:     listing.AreaSqft,
+		Bedrooms:     listing.Bedrooms,
+		Rental:       listing.Rental,
+	}
+}
+
+// changeSet splits the changes of a listing into the fields to set and those to unset to store them
+func changeSet(changes []models.FieldChange) (bson.M, bson.M) {
+	set, unset := bson.M{}, bson.M{}
+	for _, change := range changes {
+		if change.New == nil {
+			unset[change.Field] = ""
+		} else {
+			set[change.Field] = change.New
+		}
+	}
+	return set, unset
+}
+
+// pin returns the map pin of a listing request, nil when it has none
+func (req CreateListingRequest) pin() (*property.GeoPoint, error) {
+	if req.Latitude == nil && req.Longitude == nil {
+		return nil, nil
+	}
+	if req.Latitude == nil || req.Longitude == nil {
+		return nil, fmt.Errorf("latitude and longitude go together")
+	}
+	return property.NewGeoPoint(*req.Latitude, *req.Longitude)
+}
+
+// loadUnitProject returns the builder's project a unit listing is posted under, checking the unit fits it
+func loadUnitProject(ctx context.Context, userID primitive.ObjectID, req CreateListingRequest) (models.Project, error) {
+	var project models.Project
+	projectID, err := primitive.ObjectIDFromHex(req.ProjectID)
+	if err == nil {
+		err = database.GetProjectCollection().FindOne(ctx, bson.M{"_id": projectID, "builderId": userID}).Decode(&project)
+	}
+	if err != nil {
+		return project, fmt.Errorf("project not found")
+	}
+	if req.ListingType != "sale" {
+		return project, fmt.Errorf("project units are sale listings")
+	}
+	if strings.TrimSpace(req.UnitType) == "" {
+		return project, fmt.Errorf("unitType is required for project units")
+	}
+	if req.Tower != "" && len(project.Towers) > 0 && !slices.Contains(project.Towers, req.Tower) {
+		return project, fmt.Errorf("tower must be one of the project's towers")
+	}
+	return project, nil
+}
+
+// CreateListing posts a listing filled in at once for review, within the user's active listing
+// limit. Like every listing it starts as a draft, submitted straight away.
+func CreateListing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req CreateListingRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if req.Rental != nil {
+			req.Price = req.Rental.MonthlyRent
+		}
+		var projectID, cityID, localityID primitive.ObjectID
+		var projectAmenities []primitive.ObjectID
+		if req.ProjectID != "" {
+			project, err := loadUnitProject(r.Context(), userID, req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// Units are located where their project is and share its amenities
+			projectID, cityID, localityID = project.ID, project.CityID, project.LocalityID
+			projectAmenities = project.AmenityIDs
+			req.Address, req.City, req.Locality = project.Address, project.City, project.Locality
+		} else {
+			location, ok := resolveLocation(w, r, req.LocalityID, req.City, req.Locality)
+			if !ok {
+				return
+			}
+			cityID, localityID = location.IDs()
+			req.City, req.Locality = location.Names()
+		}
+		if err := validateListing(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := checkListingPhotos(userID, req.Images, nil); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		pin, err := req.pin()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		amenityIDs, ok := loadAmenityIDs(w, r, req.AmenityIDs, strings.TrimSpace(req.PropertyType))
+		if !ok {
+			return
+		}
+		for _, id := range projectAmenities {
+			if !slices.Contains(amenityIDs, id) {
+				amenityIDs = append(amenityIDs, id)
+			}
+		}
+		amenityLabels, err := services.AmenityLabels(r.Context(), amenityIDs)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to load amenity labels for the text index")
+		}
+
+		if !checkListingLimit(w, r, userID) {
+			return
+		}
+
+		now := time.Now()
+		listing := property.Property{
+			OwnerID:       userID,
+			Title:         strings.TrimSpace(req.Title),
+			Description:   strings.TrimSpace(req.Description),
+			PropertyType:  strings.TrimSpace(req.PropertyType),
+			ListingType:   req.ListingType,
+			Price:         req.Price,
+			AreaSqft:      req.AreaSqft,
+			Bedrooms:      req.Bedrooms,
+			Floor:         req.Floor,
+			Address:       strings.TrimSpace(req.Address),
+			City:          strings.TrimSpace(req.City),
+			Locality:      strings.TrimSpace(req.Locality),
+			CityID:        cityID,
+			LocalityID:    localityID,
+			Location:      pin,
+			Images:        req.Images,
+			AmenityIDs:    amenityIDs,
+			AmenityLabels: amenityLabels,
+			Rental:        req.Rental,
+			ProjectID:     projectID,
+			UnitType:      strings.TrimSpace(req.UnitType),
+			Tower:         strings.TrimSpace(req.Tower),
+			Status:        property.Draft,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		}
+		result, err := database.GetPropertyCollection().InsertOne(r.Context(), listing)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create listing")
+			http.Error(w, "Failed to create listing", http.StatusInternalServerError)
+			return
+		}
+		listing.ID = result.InsertedID.(primitive.ObjectID)
+		listing, ok = submitDraft(w, r, listing, userID)
+		if !ok {
+			return
+		}
+
+		message := "Listing submitted for review"
+		if listing.Status == property.Rejected {
+			message = listing.RejectionReason
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": message,
+			"listing": listing,
+		})
+	}
+}
+
+// screenListing runs the automated quality checks on a listing just submitted or edited and
+// returns it as it is after them, rejected when it was pending review and failed a hard check
+func screenListing(r *http.Request, listing property.Property) property.Property {
+	screened, err := services.ScreenListing(r.Context(), listing.ID)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to check the quality of listing ", listing.ID.Hex())
+		return listing
+	}
+	listing.Quality, listing.Status, listing.RejectionReason = screened.Quality, screened.Status, screened.RejectionReason
+	return listing
+}
+
+// ListMyListings returns the authenticated user's listings in every status
+func ListMyListings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		cursor, err := database.GetPropertyCollection().Find(r.Context(), bson.M{"ownerId": userID}, options.Find().SetSort(bson.D{{Key: "updatedAt", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list user listings")
+			http.Error(w, "Failed to load listings", http.StatusInternalServerError)
+			return
+		}
+		listings := []property.Property{}
+		if err = cursor.All(r.Context(), &listings); err != nil {
+			logrus.WithError(err).Error("Failed to decode user listings")
+			http.Error(w, "Failed to load listings", http.StatusInternalServerError)
+			return
+		}
+		markCompleteness(listings)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"listings": listings,
+		})
+	}
+}
+
+// GetListing returns one listing. Unpublished listings are only visible to their owner.
+func GetListing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID}).Decode(&listing)
+		if err != nil || (listing.Status != property.Published && listing.OwnerID != userID) {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+
+		if listing.PriceHistory, err = services.PriceHistory(r.Context(), listing); err != nil {
+			logrus.WithError(err).Warn("Failed to load price history")
+		}
+
+		listings := []property.Property{listing}
+		attachListers(r.Context(), listings)
+		markFavorited(r.Context(), userID, listings)
+		services.MarkPriceDrops(listings)
+		markCompleteness(listings)
+		json.NewEncoder(w).Encode(listings[0])
+	}
+}
+
+// SearchListings returns published listings matching the query filters, newest first, with facet
+// counts over all matching listings. A free-text q is split into the filters it mentions ("3bhk",
+// "under 2 cr") and words for the text index; its matches are ranked by relevance and highlighted,
+// and a query matching nothing is retried with typos corrected. With view=projects it returns the
+// builder projects having matching units instead.
+func SearchListings() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filters, err := property.ParseSearchFilters(r.URL.Query())
+		if err != nil {
+			http.Error(w, "Invalid search filters: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		var terms []string
+		if text := strings.TrimSpace(r.URL.Query().Get("q")); text != "" {
+			parsed := property.ParseTextQuery(text)
+			parsed.ApplyTo(&filters)
+			terms = parsed.Terms
+			filters.Text = strings.Join(terms, " ")
+		}
+		services.CanonicalizeSearchFilters(r.Context(), &filters)
+		skip, limit := parsePagination(r.URL.Query())
+
+		switch r.URL.Query().Get("view") {
+		case "", "units":
+		case "projects":
+			searchProjects(w, r, filters.Query(), skip, limit)
+			return
+		default:
+			http.Error(w, "view must be units or projects", http.StatusBadRequest)
+			return
+		}
+
+		page, err := runSearch(r.Context(), filters, skip, limit)
+		corrected := false
+		if err == nil && page.total() == 0 && len(terms) > 0 {
+			var correctedTerms []string
+			correctedTerms, corrected, err = services.CorrectSearchTerms(r.Context(), terms)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to correct search terms")
+				err = nil
+			}
+			if corrected {
+				terms = correctedTerms
+				filters.Text = strings.Join(terms, " ")
+				page, err = runSearch(r.Context(), filters, skip, limit)
+			}
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to search listings")
+			http.Error(w, "Failed to search listings", http.StatusInternalServerError)
+			return
+		}
+
+		listings := nonNil(page.Listings)
+		attachListers(r.Context(), listings)
+		markFavorited(r.Context(), r.Context().Value(middleware.UserIDKey).(primitive.ObjectID), listings)
+		services.MarkPriceDrops(listings)
+		if len(terms) > 0 {
+			highlightListings(listings, terms)
+		}
+
+		response := map[string]interface{}{
+			"listings": listings,
+			"total":    page.total(),
+			"facets":   page.facets(),
+			"filters":  filters, // including those read from q
+		}
+		if corrected {
+			response["correctedText"] = filters.Text
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// UpdateListingStatusRequest lets an owner mark their listing sold or let, or take it down
+type UpdateListingStatusRequest struct {
+	Status property.Status `json:"status"`
+}
+
+// UpdateListingStatus moves one of the authenticated user's listings to sold (sale listings),
+// let (rent listings) or archived. Buyers who shortlisted it see it flagged as unavailable from then on.
+func UpdateListingStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var req UpdateListingStatusRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Status != property.Sold && req.Status != property.Let && req.Status != property.Archived) {
+			http.Error(w, "Status must be sold, let or archived", http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID, "ownerId": userID}).Decode(&listing)
+		if err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if (req.Status == property.Sold && listing.ListingType != "sale") || (req.Status == property.Let && listing.ListingType != "rent") {
+			http.Error(w, "Sale listings are sold and rent listings are let", http.StatusBadRequest)
+			return
+		}
+		if !property.CanTransition(listing.Status, req.Status) {
+			http.Error(w, "Listing can't move from "+string(listing.Status)+" to "+string(req.Status), http.StatusConflict)
+			return
+		}
+
+		update := bson.M{"status": req.Status, "updatedAt": time.Now()}
+		if req.Status == property.Let {
+			update["letAt"] = time.Now()
+		}
+		result, err := database.GetPropertyCollection().UpdateOne(r.Context(),
+			bson.M{"_id": listingID, "status": listing.Status},
+			bson.M{"$set": update})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update listing status")
+			http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Listing was changed meanwhile, reload and try again", http.StatusConflict)
+			return
+		}
+		services.RecordListingUpdate(r.Context(), listing, userID, models.ChangedByOwner)
+		services.RefreshListingProject(r.Context(), listing)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Listing status updated",
+			"status":  req.Status,
+		})
+	}
+}
+
+// Statuses in which an owner can edit their listing
+var editableListingStatuses = []property.Status{property.Draft, property.PendingReview, property.Published, property.Rejected}
+
+// Fields a price change touches; editing only these keeps a published listing up
+var priceFields = map[string]bool{"price": true, "previousPrice": true, "priceDroppedAt": true, "rental.monthlyRent": true}
+
+// UpdateListingRequest is the payload for editing a listing; fields left out stay as they are
+type UpdateListingRequest struct {
+	Title       *string               `json:"title"`
+	Description *string               `json:"description"`
+	Price       *float64              `json:"price"` // sale listings; rent listings change rental.monthlyRent
+	AreaSqft    *float64              `json:"areaSqft"`
+	Bedrooms    *int                  `json:"bedrooms"`
+	Floor       *int                  `json:"floor"`
+	Latitude    *float64              `json:"latitude"` // moves the map pin, with longitude
+	Longitude   *float64              `json:"longitude"`
+	Images      *[]string             `json:"images"`
+	AmenityIDs  *[]string             `json:"amenityIds"`
+	Rental      *property.RentalTerms `json:"rental"`
+}
+
+// UpdateListing edits one of the authenticated user's listings and records the change in its history.
+// A published listing stays up when only its price changed; other edits, and edits of a rejected
+// listing, send it back for review.
+func UpdateListing() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		listingID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+		var req UpdateListingRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": listingID, "ownerId": userID}).Decode(&listing)
+		if err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if !slices.Contains(editableListingStatuses, listing.Status) {
+			http.Error(w, "A "+string(listing.Status)+" listing can't be edited", http.StatusConflict)
+			return
+		}
+		if req.Price != nil && listing.ListingType == "rent" {
+			http.Error(w, "The price of a rent listing is its rental monthlyRent", http.StatusBadRequest)
+			return
+		}
+
+		edited := listing
+		if req.Title != nil {
+			edited.Title = strings.TrimSpace(*req.Title)
+		}
+		if req.Description != nil {
+			edited.Description = strings.TrimSpace(*req.Description)
+		}
+		if req.Price != nil {
+			edited.Price = *req.Price
+		}
+		if req.AreaSqft != nil {
+			edited.AreaSqft = *req.AreaSqft
+		}
+		if req.Bedrooms != nil {
+			edited.Bedrooms = *req.Bedrooms
+		}
+		if req.Floor != nil {
+			edited.Floor = req.Floor
+		}
+		if req.Latitude != nil || req.Longitude != nil {
+			if edited.Location, err = (CreateListingRequest{Latitude: req.Latitude, Longitude: req.Longitude}).pin(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Images != nil {
+			if err = checkListingPhotos(userID, *req.Images, listing.Images); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			edited.Images = *req.Images
+		}
+		if req.Rental != nil {
+			edited.Rental = req.Rental
+			edited.Price = req.Rental.MonthlyRent
+		}
+		if err = validateListing(listingRequest(edited)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.AmenityIDs != nil {
+			amenityIDs, ok := loadAmenityIDs(w, r, *req.AmenityIDs, edited.PropertyType)
+			if !ok {
+				return
+			}
+			if !listing.ProjectID.IsZero() {
+				// Units keep the amenities of their project
+				var project models.Project
+				if err = database.GetProjectCollection().FindOne(r.Context(), bson.M{"_id": listing.ProjectID}).Decode(&project); err == nil {
+					for _, id := range project.AmenityIDs {
+						if !slices.Contains(amenityIDs, id) {
+							amenityIDs = append(amenityIDs, id)
+						}
+					}
+				}
+			}
+			edited.AmenityIDs = amenityIDs
+			if edited.AmenityLabels, err = services.AmenityLabels(r.Context(), amenityIDs); err != nil {
+				logrus.WithError(err).Warn("Failed to load amenity labels for the text index")
+			}
+		}
+
+		now := time.Now()
+		if edited.Price != listing.Price {
+			edited.PreviousPrice = listing.Price
+			if edited.Price < listing.Price {
+				edited.PriceDroppedAt = now
+			} else {
+				edited.PriceDroppedAt = time.Time{}
+			}
+		}
+		changes, err := services.ListingChanges(listing, edited)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to compare listing edits")
+			http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+			return
+		}
+		if len(changes) == 0 {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message": "Nothing to update",
+				"listing": listing,
+			})
+			return
+		}
+		for _, change := range changes {
+			if listing.Status == property.Rejected || (listing.Status == property.Published && !priceFields[change.Field]) {
+				edited.Status = property.PendingReview
+				break
+			}
+		}
+		edited.UpdatedAt = now
+
+		// Store only what changed, matching on the version we read so concurrent edits aren't lost
+		set, unset := changeSet(changes)
+		set["updatedAt"] = now
+		if edited.Status != listing.Status {
+			set["status"] = edited.Status
+		}
+		if req.AmenityIDs != nil {
+			set["amenityLabels"] = edited.AmenityLabels
+		}
+		update := bson.M{"$set": set}
+		if len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		result, err := database.GetPropertyCollection().UpdateOne(r.Context(), bson.M{"_id": listingID, "updatedAt": listing.UpdatedAt}, update)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update listing")
+			http.Error(w, "Failed to update listing", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Listing was changed meanwhile, reload and try again", http.StatusConflict)
+			return
+		}
+		if err = services.RecordListingVersion(r.Context(), listing, edited, userID, models.ChangedByOwner); err != nil {
+			logrus.WithError(err).Error("Failed to record version of listing ", listingID.Hex())
+		}
+		services.RefreshListingProject(r.Context(), listing)
+		edited = screenListing(r, edited)
+
+		message := "Listing updated"
+		if edited.Status == property.Rejected {
+			message = edited.RejectionReason
+		} else if edited.Status == property.PendingReview && listing.Status != property.PendingReview {
+			message = "Listing updated and sent for review"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": message,
+			"listing": edited,
+		})
+	}
+}
@@ -0,0 +1,631 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Largest locality CSV accepted by the import
+const maxLocalityImportBytes = 5 * 1024 * 1024
+
+// Content types browsers and spreadsheet apps send CSVs as; they are detected as plain text
+var csvContentTypes = []string{"text/csv", "application/vnd.ms-excel", "text/plain"}
+
+// resolveLocation finds the master city and locality a listing or project is posted in, from the
+// localityId picked in the autocomplete or else the free-text names. Names the master doesn't cover
+// yet are taken as they are, for the linking job to pick up once it does. It writes the error
+// response and returns false when the place is unknown.
+func resolveLocation(w http.ResponseWriter, r *http.Request, localityID, city, locality string) (services.Location, bool) {
+	var id primitive.ObjectID
+	if localityID != "" {
+		var err error
+		if id, err = primitive.ObjectIDFromHex(localityID); err != nil {
+			http.Error(w, "Invalid locality ID", http.StatusBadRequest)
+			return services.Location{}, false
+		}
+	} else if strings.TrimSpace(city) == "" {
+		http.Error(w, "A localityId or city is required", http.StatusBadRequest)
+		return services.Location{}, false
+	}
+
+	city, locality = strings.TrimSpace(city), strings.TrimSpace(locality)
+	location, err := services.ResolveLocation(r.Context(), id, city, locality)
+	if errors.Is(err, mongo.ErrNoDocuments) && id.IsZero() {
+		var uncovered bool
+		if location, uncovered, err = services.UncoveredLocation(r.Context(), city, locality); err == nil && !uncovered {
+			err = mongo.ErrNoDocuments
+		}
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		http.Error(w, "Unknown city or locality, pick one from the locality suggestions", http.StatusBadRequest)
+		return location, false
+	}
+	if err != nil {
+		logrus.WithError(err).Error("Failed to resolve location")
+		http.Error(w, "Failed to look up the locality", http.StatusInternalServerError)
+		return location, false
+	}
+	return location, true
+}
+
+// setPlaceIDs adds the city and locality IDs of a place to set and returns the ones to unset,
+// those of a place the locality master doesn't cover yet
+func setPlaceIDs(set bson.M, cityID, localityID primitive.ObjectID) bson.M {
+	unset := bson.M{}
+	for field, id := range map[string]primitive.ObjectID{"cityId": cityID, "localityId": localityID} {
+		if id.IsZero() {
+			unset[field] = ""
+		} else {
+			set[field] = id
+		}
+	}
+	return unset
+}
+
+// AutocompleteLocalities suggests cities and localities whose name or an alias starts with q,
+// those with the most listings first. cityId restricts the suggestions to one city's localities.
+func AutocompleteLocalities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prefix := models.NormalizePlaceName(r.URL.Query().Get("q"))
+		if len([]rune(prefix)) < 2 {
+			http.Error(w, "q must have at least 2 characters", http.StatusBadRequest)
+			return
+		}
+		limit, _ := strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+		if limit < 1 || limit > 20 {
+			limit = 10
+		}
+
+		filter := bson.M{
+			"level":      bson.M{"$in": bson.A{models.LevelCity, models.LevelLocality}},
+			"searchKeys": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)},
+		}
+		if v := r.URL.Query().Get("cityId"); v != "" {
+			cityID, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				http.Error(w, "Invalid city ID", http.StatusBadRequest)
+				return
+			}
+			filter["cityId"] = cityID
+		}
+
+		cursor, err := database.GetLocalityCollection().Find(r.Context(), filter,
+			options.Find().SetSort(bson.D{{Key: "listingCount", Value: -1}, {Key: "name", Value: 1}}).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to autocomplete localities")
+			http.Error(w, "Failed to load suggestions", http.StatusInternalServerError)
+			return
+		}
+		suggestions := []models.Locality{}
+		if err = cursor.All(r.Context(), &suggestions); err != nil {
+			logrus.WithError(err).Error("Failed to decode locality suggestions")
+			http.Error(w, "Failed to load suggestions", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"suggestions": suggestions,
+		})
+	}
+}
+
+// GetLocalityPrices returns the price per square foot statistics of a city or locality over the
+// last year by listing type and property type, with their monthly trend. ?listingType= and
+// ?propertyType= narrow them down.
+func GetLocalityPrices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		placeID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid locality ID", http.StatusBadRequest)
+			return
+		}
+		var place models.Locality
+		if err = database.GetLocalityCollection().FindOne(r.Context(), bson.M{"_id": placeID}).Decode(&place); err != nil {
+			http.Error(w, "Locality not found", http.StatusNotFound)
+			return
+		}
+
+		filter := bson.M{"placeId": placeID}
+		for _, param := range []string{"listingType", "propertyType"} {
+			if v := strings.TrimSpace(r.URL.Query().Get(param)); v != "" {
+				filter[param] = v
+			}
+		}
+		cursor, err := database.GetPriceStatsCollection().Find(r.Context(), filter,
+			options.Find().SetSort(bson.D{{Key: "listingType", Value: 1}, {Key: "listings", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load price statistics")
+			http.Error(w, "Failed to load prices", http.StatusInternalServerError)
+			return
+		}
+		stats := []models.PriceStats{}
+		if err = cursor.All(r.Context(), &stats); err != nil {
+			logrus.WithError(err).Error("Failed to decode price statistics")
+			http.Error(w, "Failed to load prices", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"place": place,
+			"stats": stats,
+		})
+	}
+}
+
+// ListLocalities returns places of the locality master for staff, filtered by level, parent and name prefix
+func ListLocalities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := bson.M{}
+		if level := query.Get("level"); level != "" {
+			if _, ok := models.ParentLevel(level); !ok {
+				http.Error(w, "Invalid level", http.StatusBadRequest)
+				return
+			}
+			filter["level"] = level
+		}
+		if v := query.Get("parentId"); v != "" {
+			parentID, err := primitive.ObjectIDFromHex(v)
+			if err != nil {
+				http.Error(w, "Invalid parent ID", http.StatusBadRequest)
+				return
+			}
+			filter["parentId"] = parentID
+		}
+		if prefix := models.NormalizePlaceName(query.Get("q")); prefix != "" {
+			filter["searchKeys"] = bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}
+		}
+		skip, limit := parsePagination(query)
+
+		collection := database.GetLocalityCollection()
+		total, err := collection.CountDocuments(r.Context(), filter)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to count localities")
+			http.Error(w, "Failed to load localities", http.StatusInternalServerError)
+			return
+		}
+		cursor, err := collection.Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetSkip(skip).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list localities")
+			http.Error(w, "Failed to load localities", http.StatusInternalServerError)
+			return
+		}
+		localities := []models.Locality{}
+		if err = cursor.All(r.Context(), &localities); err != nil {
+			logrus.WithError(err).Error("Failed to decode localities")
+			http.Error(w, "Failed to load localities", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"localities": localities,
+			"total":      total,
+		})
+	}
+}
+
+// LocalityRequest is the payload for adding a place to the locality master or editing one
+type LocalityRequest struct {
+	Level     string    `json:"level"`
+	Name      *string   `json:"name"`
+	ParentID  string    `json:"parentId,omitempty"` // required below country level
+	Aliases   *[]string `json:"aliases,omitempty"`
+	Latitude  *float64  `json:"latitude,omitempty"`
+	Longitude *float64  `json:"longitude,omitempty"`
+}
+
+// centroid returns the requested centroid, nil when none was given
+func (req LocalityRequest) centroid() (*property.GeoPoint, error) {
+	if req.Latitude == nil && req.Longitude == nil {
+		return nil, nil
+	}
+	if req.Latitude == nil || req.Longitude == nil {
+		return nil, fmt.Errorf("latitude and longitude go together")
+	}
+	return property.NewGeoPoint(*req.Latitude, *req.Longitude)
+}
+
+// CreateLocality adds a country, state, city or locality under its parent
+func CreateLocality() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LocalityRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		parentLevel, ok := models.ParentLevel(req.Level)
+		if !ok {
+			http.Error(w, "level must be country, state, city or locality", http.StatusBadRequest)
+			return
+		}
+		if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		centroid, err := req.centroid()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		place := models.Locality{Level: req.Level, Name: strings.TrimSpace(*req.Name), Centroid: centroid, CreatedAt: now, UpdatedAt: now}
+		if req.Aliases != nil {
+			place.Aliases = *req.Aliases
+		}
+		if parentLevel != "" {
+			var parent models.Locality
+			parentID, err := primitive.ObjectIDFromHex(req.ParentID)
+			if err == nil {
+				err = database.GetLocalityCollection().FindOne(r.Context(), bson.M{"_id": parentID, "level": parentLevel}).Decode(&parent)
+			}
+			if err != nil {
+				http.Error(w, "A "+req.Level+" needs a "+parentLevel+" as parentId", http.StatusBadRequest)
+				return
+			}
+			place.PlaceUnder(parent)
+		}
+		place.SetSearchKeys()
+
+		result, err := database.GetLocalityCollection().InsertOne(r.Context(), place)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "A "+req.Level+" with this name already exists there", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create locality")
+			http.Error(w, "Failed to create locality", http.StatusInternalServerError)
+			return
+		}
+		place.ID = result.InsertedID.(primitive.ObjectID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(place)
+	}
+}
+
+// UpdateLocality renames a place, replaces its aliases or moves its centroid. A new name is
+// carried over to the places, listings and projects in it, and to the staff scopes naming it.
+func UpdateLocality() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		placeID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid locality ID", http.StatusBadRequest)
+			return
+		}
+		var req LocalityRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		centroid, err := req.centroid()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var place models.Locality
+		if err = database.GetLocalityCollection().FindOne(r.Context(), bson.M{"_id": placeID}).Decode(&place); err != nil {
+			http.Error(w, "Locality not found", http.StatusNotFound)
+			return
+		}
+		oldName, renamed := place.Name, false
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				http.Error(w, "name can't be empty", http.StatusBadRequest)
+				return
+			}
+			renamed = name != place.Name
+			place.Name = name
+		}
+		if req.Aliases != nil {
+			place.Aliases = *req.Aliases
+		}
+		if centroid != nil {
+			place.Centroid = centroid
+		}
+		place.SetSearchKeys()
+		place.UpdatedAt = time.Now()
+
+		_, err = database.GetLocalityCollection().UpdateOne(r.Context(), bson.M{"_id": placeID}, bson.M{"$set": bson.M{
+			"name":       place.Name,
+			"aliases":    place.Aliases,
+			"centroid":   place.Centroid,
+			"nameKey":    place.NameKey,
+			"searchKeys": place.SearchKeys,
+			"updatedAt":  place.UpdatedAt,
+		}})
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "A "+place.Level+" with this name already exists there", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update locality")
+			http.Error(w, "Failed to update locality", http.StatusInternalServerError)
+			return
+		}
+		if renamed {
+			if err = services.RenamePlace(r.Context(), place, oldName); err != nil {
+				logrus.WithError(err).Error("Failed to carry over rename of locality ", placeID.Hex())
+			}
+		}
+
+		json.NewEncoder(w).Encode(place)
+	}
+}
+
+// DeleteLocality removes a place nothing is filed under: no places below it, no listings or projects in it
+func DeleteLocality() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		placeID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid locality ID", http.StatusBadRequest)
+			return
+		}
+
+		inUse := bson.M{"$or": []bson.M{{"cityId": placeID}, {"localityId": placeID}}}
+		for _, check := range []struct {
+			collection *mongo.Collection
+			filter     bson.M
+		}{
+			{database.GetLocalityCollection(), bson.M{"parentId": placeID}},
+			{database.GetPropertyCollection(), inUse},
+			{database.GetProjectCollection(), inUse},
+		} {
+			count, err := check.collection.CountDocuments(r.Context(), check.filter, options.Count().SetLimit(1))
+			if err != nil {
+				logrus.WithError(err).Error("Failed to check locality use")
+				http.Error(w, "Failed to delete locality", http.StatusInternalServerError)
+				return
+			}
+			if count > 0 {
+				http.Error(w, "Locality is still in use by places, listings or projects", http.StatusConflict)
+				return
+			}
+		}
+
+		result, err := database.GetLocalityCollection().DeleteOne(r.Context(), bson.M{"_id": placeID})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to delete locality")
+			http.Error(w, "Failed to delete locality", http.StatusInternalServerError)
+			return
+		}
+		if result.DeletedCount == 0 {
+			http.Error(w, "Locality not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Locality deleted",
+		})
+	}
+}
+
+// localityImportError is a CSV row the import skipped
+type localityImportError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// readCSVUpload reads one multipart CSV file field of at most maxBytes, sent as a CSV or detected
+// as text, and returns its UTF-8 content without a byte order mark
+func readCSVUpload(w http.ResponseWriter, r *http.Request, field string, maxBytes int64) ([]byte, error) {
+	data, declared, err := readUploadFile(w, r, field, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+	declared, _, _ = mime.ParseMediaType(declared)
+	if !strings.HasPrefix(http.DetectContentType(data), "text/plain") && !slices.Contains(csvContentTypes, declared) {
+		return nil, fmt.Errorf("unsupported file type, upload a CSV")
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("the CSV must be saved as UTF-8")
+	}
+	return data, nil
+}
+
+// ImportLocalities bulk loads the "file" CSV with columns country, state, city and optionally
+// locality, aliases (separated by |), latitude and longitude. Missing places along each row are
+// created; aliases and centroid apply to the row's last place, merging with what it has.
+func ImportLocalities() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data, err := readCSVUpload(w, r, "file", maxLocalityImportBytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+
+		header, err := reader.Read()
+		if err != nil {
+			http.Error(w, "The CSV has no header row", http.StatusBadRequest)
+			return
+		}
+		columns := map[string]int{}
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, required := range []string{"country", "state", "city"} {
+			if _, ok := columns[required]; !ok {
+				http.Error(w, "The CSV needs a "+required+" column", http.StatusBadRequest)
+				return
+			}
+		}
+
+		importer := localityImporter{places: map[string]models.Locality{}}
+		var rowErrors []localityImportError
+		for line := 2; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err == nil {
+				err = importer.importRow(r.Context(), func(column string) string {
+					if i, ok := columns[column]; ok && i < len(record) {
+						return strings.TrimSpace(record[i])
+					}
+					return ""
+				})
+			}
+			if err != nil {
+				rowErrors = append(rowErrors, localityImportError{Line: line, Error: err.Error()})
+			}
+		}
+
+		logrus.Info("Locality import: ", importer.created, " created, ", importer.updated, " updated, ", len(rowErrors), " rows skipped")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"created": importer.created,
+			"updated": importer.updated,
+			"errors":  rowErrors,
+		})
+	}
+}
+
+// localityImporter creates the places of an import, remembering those it already saw
+type localityImporter struct {
+	places           map[string]models.Locality
+	created, updated int
+}
+
+// importRow saves the places of one CSV row, reading its columns through field
+func (imp *localityImporter) importRow(ctx context.Context, field func(string) string) error {
+	var centroid *property.GeoPoint
+	if lat, lng := field("latitude"), field("longitude"); lat != "" || lng != "" {
+		latitude, err1 := strconv.ParseFloat(lat, 64)
+		longitude, err2 := strconv.ParseFloat(lng, 64)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("invalid latitude or longitude")
+		}
+		var err error
+		if centroid, err = property.NewGeoPoint(latitude, longitude); err != nil {
+			return err
+		}
+	}
+
+	var place *models.Locality
+	for _, level := range []string{models.LevelCountry, models.LevelState, models.LevelCity, models.LevelLocality} {
+		name := field(level)
+		if name == "" {
+			if level == models.LevelLocality {
+				break
+			}
+			return fmt.Errorf("%s is required", level)
+		}
+		next, err := imp.place(ctx, level, name, place)
+		if err != nil {
+			return err
+		}
+		place = &next
+	}
+
+	var aliases []string
+	for _, alias := range strings.Split(field("aliases"), "|") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	if len(aliases) == 0 && centroid == nil {
+		return nil
+	}
+	for _, alias := range aliases {
+		if models.NormalizePlaceName(alias) != place.NameKey && !containsPlaceName(place.Aliases, alias) {
+			place.Aliases = append(place.Aliases, alias)
+		}
+	}
+	if centroid != nil {
+		place.Centroid = centroid
+	}
+	place.SetSearchKeys()
+	_, err := database.GetLocalityCollection().UpdateOne(ctx, bson.M{"_id": place.ID}, bson.M{"$set": bson.M{
+		"aliases":    place.Aliases,
+		"searchKeys": place.SearchKeys,
+		"centroid":   place.Centroid,
+		"updatedAt":  time.Now(),
+	}})
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", place.Name, err)
+	}
+	imp.places[placeKey(place.Level, place.Name, place.ParentID)] = *place
+	imp.updated++
+	return nil
+}
+
+// place returns the place of the level and name under parent, creating it when it doesn't exist
+func (imp *localityImporter) place(ctx context.Context, level, name string, parent *models.Locality) (models.Locality, error) {
+	var parentID primitive.ObjectID
+	if parent != nil {
+		parentID = parent.ID
+	}
+	key := placeKey(level, name, parentID)
+	if place, ok := imp.places[key]; ok {
+		return place, nil
+	}
+
+	filter := bson.M{"level": level, "nameKey": models.NormalizePlaceName(name), "parentId": bson.M{"$exists": false}}
+	if parent != nil {
+		filter["parentId"] = parentID
+	}
+	var place models.Locality
+	err := database.GetLocalityCollection().FindOne(ctx, filter).Decode(&place)
+	if err == mongo.ErrNoDocuments {
+		now := time.Now()
+		place = models.Locality{Level: level, Name: name, CreatedAt: now, UpdatedAt: now}
+		if parent != nil {
+			place.PlaceUnder(*parent)
+		}
+		place.SetSearchKeys()
+		var result *mongo.InsertOneResult
+		result, err = database.GetLocalityCollection().InsertOne(ctx, place)
+		if err == nil {
+			place.ID = result.InsertedID.(primitive.ObjectID)
+			imp.created++
+		} else if mongo.IsDuplicateKeyError(err) {
+			// Created meanwhile by someone else
+			err = database.GetLocalityCollection().FindOne(ctx, filter).Decode(&place)
+		}
+	}
+	if err != nil {
+		return place, fmt.Errorf("failed to save %s %s: %w", level, name, err)
+	}
+	imp.places[key] = place
+	return place, nil
+}
+
+// placeKey identifies a place of the import by level, parent and normalized name
+func placeKey(level, name string, parentID primitive.ObjectID) string {
+	return level + "/" + parentID.Hex() + "/" + models.NormalizePlaceName(name)
+}
+
+// containsPlaceName reports whether names holds name, compared after normalization
+func containsPlaceName(names []string, name string) bool {
+	for _, n := range names {
+		if models.NormalizePlaceName(n) == models.NormalizePlaceName(name) {
+			return true
+		}
+	}
+	return false
+}
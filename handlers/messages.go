@@ -0,0 +1,658 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxMessageLength     = 2000
+	maxMessageImages     = 5
+	maxMessageImageBytes = 5 * 1024 * 1024
+	messagePreviewLength = 80
+	chatKeepAlive        = 25 * time.Second
+	maxPolledMessages    = 200
+)
+
+// Folder of the private upload directory holding chat image attachments, by conversation and sender
+const messageUploadFolder = "messages"
+
+// SendMessageRequest is a chat message; images are URLs returned by the attachment upload
+type SendMessageRequest struct {
+	Text   string   `json:"text"`
+	Images []string `json:"images,omitempty"`
+}
+
+// ReportConversationRequest flags a conversation, or one message in it, for staff review
+type ReportConversationRequest struct {
+	Reason    string             `json:"reason"`
+	MessageID primitive.ObjectID `json:"messageId,omitempty"`
+}
+
+// isBlocked reports whether either user blocked the other
+func isBlocked(ctx context.Context, a, b primitive.ObjectID) bool {
+	count, _ := database.GetUserBlockCollection().CountDocuments(ctx, bson.M{"$or": []bson.M{
+		{"blockerId": a, "blockedId": b},
+		{"blockerId": b, "blockedId": a},
+	}})
+	return count > 0
+}
+
+// loadConversation loads the conversation named by the {id} path variable if the authenticated user takes part in it
+func loadConversation(w http.ResponseWriter, r *http.Request) (models.Conversation, primitive.ObjectID, bool) {
+	userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+	var conversation models.Conversation
+	conversationID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return conversation, userID, false
+	}
+	err = database.GetConversationCollection().FindOne(r.Context(), bson.M{
+		"_id": conversationID,
+		"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}},
+	}).Decode(&conversation)
+	if err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return conversation, userID, false
+	}
+	return conversation, userID, true
+}
+
+// messageImageFolder returns the private folder of the images a user attached in a conversation
+func messageImageFolder(conversationID, senderID primitive.ObjectID) string {
+	return messageUploadFolder + "/" + conversationID.Hex() + "/" + senderID.Hex()
+}
+
+// messageImageURL returns the URL a message image is downloaded from by the conversation's participants
+func messageImageURL(conversationID, senderID primitive.ObjectID, name string) string {
+	return "/api/conversations/" + conversationID.Hex() + "/images/" + senderID.Hex() + "/" + name
+}
+
+// markMessagesRead sets the Read flag of messages the other side of the conversation has read
+func markMessagesRead(conversation models.Conversation, messages []models.Message) {
+	for i := range messages {
+		lastRead := conversation.LastReadBy(conversation.OtherParty(messages[i].SenderID))
+		messages[i].Read = !messages[i].CreatedAt.After(lastRead)
+	}
+}
+
+// StartConversation opens (or returns the existing) conversation of the authenticated user with the lister of a published listing
+func StartConversation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		if err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": propertyID, "status": property.Published}).Decode(&listing); err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if listing.OwnerID == userID {
+			http.Error(w, "You can't message yourself about your own listing", http.StatusBadRequest)
+			return
+		}
+		if isBlocked(r.Context(), userID, listing.OwnerID) {
+			http.Error(w, "You can't message this lister", http.StatusForbidden)
+			return
+		}
+
+		var conversation models.Conversation
+		err = database.GetConversationCollection().FindOneAndUpdate(r.Context(),
+			bson.M{"propertyId": propertyID, "buyerId": userID},
+			bson.M{"$setOnInsert": bson.M{"ownerId": listing.OwnerID, "buyerUnread": 0, "ownerUnread": 0, "createdAt": time.Now()}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&conversation)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to start conversation")
+			http.Error(w, "Failed to start conversation", http.StatusInternalServerError)
+			return
+		}
+		conversation.Unread = conversation.BuyerUnread
+		json.NewEncoder(w).Encode(conversation)
+	}
+}
+
+// ListConversations returns the authenticated user's conversations, most recent first, with unread counts
+func ListConversations() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		skip, limit := parsePagination(r.URL.Query())
+
+		filter := bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}}
+		cursor, err := database.GetConversationCollection().Find(r.Context(), filter,
+			options.Find().SetSort(bson.D{{Key: "lastMessageAt", Value: -1}}).SetSkip(skip).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list conversations")
+			http.Error(w, "Failed to list conversations", http.StatusInternalServerError)
+			return
+		}
+		conversations := []models.Conversation{}
+		if err = cursor.All(r.Context(), &conversations); err != nil {
+			logrus.WithError(err).Error("Failed to decode conversations")
+			http.Error(w, "Failed to list conversations", http.StatusInternalServerError)
+			return
+		}
+
+		blocks, _ := database.GetUserBlockCollection().Find(r.Context(), bson.M{"$or": []bson.M{{"blockerId": userID}, {"blockedId": userID}}})
+		var userBlocks []models.UserBlock
+		if blocks != nil {
+			blocks.All(r.Context(), &userBlocks)
+		}
+		blocked := map[primitive.ObjectID]bool{}
+		for _, block := range userBlocks {
+			blocked[block.BlockerID], blocked[block.BlockedID] = true, true
+		}
+		for i := range conversations {
+			if conversations[i].BuyerID == userID {
+				conversations[i].Unread = conversations[i].BuyerUnread
+			} else {
+				conversations[i].Unread = conversations[i].OwnerUnread
+			}
+			conversations[i].Blocked = blocked[conversations[i].OtherParty(userID)]
+		}
+
+		unread, err := totalUnread(r.Context(), userID)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to count unread messages")
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"conversations": conversations,
+			"unread":        unread,
+		})
+	}
+}
+
+// totalUnread counts the user's unread messages over all their conversations
+func totalUnread(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	cursor, err := database.GetConversationCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}}}},
+		{{Key: "$group", Value: bson.M{"_id": nil, "unread": bson.M{"$sum": bson.M{
+			"$cond": bson.A{bson.M{"$eq": bson.A{"$buyerId", userID}}, "$buyerUnread", "$ownerUnread"},
+		}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var results []struct {
+		Unread int `bson:"unread"`
+	}
+	if err = cursor.All(ctx, &results); err != nil || len(results) == 0 {
+		return 0, err
+	}
+	return results[0].Unread, nil
+}
+
+// ListMessages returns a conversation's messages, newest first. ?before= (RFC 3339) pages back
+// through history and ?after= returns only messages newer than a time, for polling.
+func ListMessages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, _, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		query := r.URL.Query()
+		_, limit := parsePagination(query)
+
+		filter := bson.M{"conversationId": conversation.ID}
+		createdAt := bson.M{}
+		for param, operator := range map[string]string{"before": "$lt", "after": "$gt"} {
+			if value := query.Get(param); value != "" {
+				t, err := time.Parse(time.RFC3339Nano, value)
+				if err != nil {
+					http.Error(w, param+" must be an RFC 3339 time", http.StatusBadRequest)
+					return
+				}
+				createdAt[operator] = t
+			}
+		}
+		if len(createdAt) > 0 {
+			filter["createdAt"] = createdAt
+		}
+
+		cursor, err := database.GetMessageCollection().Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list messages")
+			http.Error(w, "Failed to list messages", http.StatusInternalServerError)
+			return
+		}
+		messages := []models.Message{}
+		if err = cursor.All(r.Context(), &messages); err != nil {
+			logrus.WithError(err).Error("Failed to decode messages")
+			http.Error(w, "Failed to list messages", http.StatusInternalServerError)
+			return
+		}
+		markMessagesRead(conversation, messages)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"messages": messages,
+		})
+	}
+}
+
+// UploadMessageImage privately stores an "image" attachment for a message in the conversation and
+// returns the URL the participants download it from
+func UploadMessageImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, userID, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		data, ext, err := readUpload(w, r, "image", maxMessageImageBytes, imageExtensions)
+		if err != nil {
+			http.Error(w, "Invalid image: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		folder := messageImageFolder(conversation.ID, userID)
+		path, err := services.SavePrivateFile(folder, data, ext)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to store message image")
+			http.Error(w, "Failed to store image", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"url": messageImageURL(conversation.ID, userID, strings.TrimPrefix(path, folder+"/"))})
+	}
+}
+
+// DownloadMessageImage serves an image attached in a conversation to its participants
+func DownloadMessageImage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, _, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		vars := mux.Vars(r)
+		senderID, err := primitive.ObjectIDFromHex(vars["sender"])
+		if err != nil || (senderID != conversation.BuyerID && senderID != conversation.OwnerID) || !isStoredFileName(vars["name"]) {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		path := messageImageFolder(conversation.ID, senderID) + "/" + vars["name"]
+		if !services.PrivateFileExists(path) {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "private, no-store")
+		http.ServeFile(w, r, services.PrivateFilePath(path))
+	}
+}
+
+// SendMessage posts a message to a conversation and pushes it to the recipient's open streams.
+// The recipient is notified when it is the first message they haven't read.
+func SendMessage() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, userID, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		var req SendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		req.Text = strings.TrimSpace(req.Text)
+		if (req.Text == "" && len(req.Images) == 0) || len(req.Text) > maxMessageLength {
+			http.Error(w, fmt.Sprintf("A message needs text of at most %d characters or an image", maxMessageLength), http.StatusBadRequest)
+			return
+		}
+		if len(req.Images) > maxMessageImages {
+			http.Error(w, fmt.Sprintf("At most %d images per message", maxMessageImages), http.StatusBadRequest)
+			return
+		}
+		// Only images the sender attached in this conversation can be sent
+		ownImages := messageImageURL(conversation.ID, userID, "")
+		for _, image := range req.Images {
+			name := strings.TrimPrefix(image, ownImages)
+			if !strings.HasPrefix(image, ownImages) || !isStoredFileName(name) ||
+				!services.PrivateFileExists(messageImageFolder(conversation.ID, userID)+"/"+name) {
+				http.Error(w, "Images must be uploaded as message attachments first", http.StatusBadRequest)
+				return
+			}
+		}
+		recipientID := conversation.OtherParty(userID)
+		if isBlocked(r.Context(), userID, recipientID) {
+			http.Error(w, "You can't message this user", http.StatusForbidden)
+			return
+		}
+
+		message := models.Message{
+			ConversationID: conversation.ID,
+			SenderID:       userID,
+			Text:           req.Text,
+			Images:         req.Images,
+			CreatedAt:      time.Now(),
+		}
+		result, err := database.GetMessageCollection().InsertOne(r.Context(), message)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to send message")
+			http.Error(w, "Failed to send message", http.StatusInternalServerError)
+			return
+		}
+		message.ID = result.InsertedID.(primitive.ObjectID)
+
+		preview := req.Text
+		if preview == "" {
+			preview = "Photo"
+		} else if len([]rune(preview)) > messagePreviewLength {
+			preview = string([]rune(preview)[:messagePreviewLength]) + "..."
+		}
+		var before models.Conversation
+		err = database.GetConversationCollection().FindOneAndUpdate(r.Context(),
+			bson.M{"_id": conversation.ID},
+			bson.M{
+				"$set": bson.M{"lastMessageAt": message.CreatedAt, "lastMessagePreview": preview, conversation.LastReadField(userID): message.CreatedAt},
+				"$inc": bson.M{conversation.UnreadField(recipientID): 1},
+			}).Decode(&before)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to update conversation ", conversation.ID.Hex())
+		}
+
+		services.PublishChat(recipientID, services.ChatEvent{Type: services.ChatEventMessage, Data: message})
+		var recipient models.User
+		if err == nil && (before.BuyerID == recipientID && before.BuyerUnread == 0 || before.OwnerID == recipientID && before.OwnerUnread == 0) {
+			if err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": recipientID}).Decode(&recipient); err == nil {
+				err = services.Notify(r.Context(), recipient, services.Notice{
+					Kind:  models.NotificationNewMessage,
+					Title: "New message",
+					Body:  preview,
+					Data:  map[string]interface{}{"conversationId": conversation.ID.Hex()},
+				})
+			}
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to notify about message in ", conversation.ID.Hex())
+			}
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(message)
+	}
+}
+
+// MarkConversationRead clears the authenticated user's unread count and sends the other side a read receipt
+func MarkConversationRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, userID, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		readAt := time.Now()
+		_, err := database.GetConversationCollection().UpdateOne(r.Context(), bson.M{"_id": conversation.ID},
+			bson.M{"$set": bson.M{conversation.UnreadField(userID): 0, conversation.LastReadField(userID): readAt}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to mark conversation read")
+			http.Error(w, "Failed to mark conversation read", http.StatusInternalServerError)
+			return
+		}
+		services.PublishChat(conversation.OtherParty(userID), services.ChatEvent{Type: services.ChatEventRead, Data: map[string]interface{}{
+			"conversationId": conversation.ID,
+			"readBy":         userID,
+			"readAt":         readAt,
+		}})
+		json.NewEncoder(w).Encode(map[string]interface{}{"message": "Conversation marked read", "readAt": readAt})
+	}
+}
+
+// BlockConversationUser blocks the other side of a conversation from messaging the authenticated user
+func BlockConversationUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, userID, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		block := models.UserBlock{BlockerID: userID, BlockedID: conversation.OtherParty(userID), CreatedAt: time.Now()}
+		_, err := database.GetUserBlockCollection().UpdateOne(r.Context(),
+			bson.M{"blockerId": block.BlockerID, "blockedId": block.BlockedID},
+			bson.M{"$setOnInsert": block}, options.Update().SetUpsert(true))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to block user")
+			http.Error(w, "Failed to block user", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "User blocked"})
+	}
+}
+
+// UnblockConversationUser lifts the authenticated user's block on the other side of a conversation
+func UnblockConversationUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, userID, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		_, err := database.GetUserBlockCollection().DeleteOne(r.Context(), bson.M{"blockerId": userID, "blockedId": conversation.OtherParty(userID)})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to unblock user")
+			http.Error(w, "Failed to unblock user", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "User unblocked"})
+	}
+}
+
+// ReportConversation flags the other side of a conversation for staff review
+func ReportConversation() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conversation, userID, ok := loadConversation(w, r)
+		if !ok {
+			return
+		}
+		var req ReportConversationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Reason) == "" {
+			http.Error(w, "A reason is required", http.StatusBadRequest)
+			return
+		}
+		if !req.MessageID.IsZero() {
+			count, _ := database.GetMessageCollection().CountDocuments(r.Context(), bson.M{"_id": req.MessageID, "conversationId": conversation.ID})
+			if count == 0 {
+				http.Error(w, "Message not found", http.StatusNotFound)
+				return
+			}
+		}
+
+		var listing property.Property
+		err := database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": conversation.PropertyID},
+			options.FindOne().SetProjection(bson.M{"city": 1, "locality": 1})).Decode(&listing)
+		if err != nil && err != mongo.ErrNoDocuments {
+			logrus.WithError(err).Error("Failed to load listing of reported conversation")
+			http.Error(w, "Failed to report conversation", http.StatusInternalServerError)
+			return
+		}
+
+		report := models.MessageReport{
+			ConversationID: conversation.ID,
+			MessageID:      req.MessageID,
+			ReporterID:     userID,
+			ReportedUserID: conversation.OtherParty(userID),
+			Reason:         strings.TrimSpace(req.Reason),
+			Status:         models.ReportOpen,
+			City:           listing.City,
+			Locality:       listing.Locality,
+			CreatedAt:      time.Now(),
+		}
+		if _, err = database.GetMessageReportCollection().InsertOne(r.Context(), report); err != nil {
+			logrus.WithError(err).Error("Failed to report conversation")
+			http.Error(w, "Failed to report conversation", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"message": "Report sent to our team"})
+	}
+}
+
+// PollMessages is the fallback for clients without a real-time stream: it returns the messages of all
+// the user's conversations sent after ?since= (RFC 3339), oldest first, and the time to poll from next
+func PollMessages() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		since, err := time.Parse(time.RFC3339Nano, r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+
+		conversationIDs, err := database.GetConversationCollection().Distinct(r.Context(), "_id", bson.M{
+			"$or":           []bson.M{{"buyerId": userID}, {"ownerId": userID}},
+			"lastMessageAt": bson.M{"$gt": since},
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to poll conversations")
+			http.Error(w, "Failed to poll messages", http.StatusInternalServerError)
+			return
+		}
+		messages := []models.Message{}
+		if len(conversationIDs) > 0 {
+			cursor, err := database.GetMessageCollection().Find(r.Context(),
+				bson.M{"conversationId": bson.M{"$in": conversationIDs}, "createdAt": bson.M{"$gt": since, "$lte": now}},
+				options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(maxPolledMessages))
+			if err == nil {
+				err = cursor.All(r.Context(), &messages)
+			}
+			if err != nil {
+				logrus.WithError(err).Error("Failed to poll messages")
+				http.Error(w, "Failed to poll messages", http.StatusInternalServerError)
+				return
+			}
+		}
+		// With a full page the client continues from the last message instead of skipping the rest
+		next := now
+		if len(messages) == maxPolledMessages {
+			next = messages[len(messages)-1].CreatedAt
+		}
+
+		unread, _ := totalUnread(r.Context(), userID)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"messages": messages,
+			"unread":   unread,
+			"next":     next,
+		})
+	}
+}
+
+// ChatStream pushes the authenticated user's chat events as Server-Sent Events until the client disconnects
+func ChatStream() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported, poll instead", http.StatusNotImplemented)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		events, unsubscribe := services.SubscribeChat(userID)
+		defer unsubscribe()
+		fmt.Fprint(w, "retry: 5000\n\n")
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(chatKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case event := <-events:
+				data, err := json.Marshal(event.Data)
+				if err != nil {
+					logrus.WithError(err).Warn("Failed to encode chat event")
+					continue
+				}
+				fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// ListMessageReports returns the conversation reports about listings in the caller's scope, open
+// ones unless ?status= says otherwise, oldest first
+func ListMessageReports() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+		status := r.URL.Query().Get("status")
+		if status == "" {
+			status = models.ReportOpen
+		}
+		filter := models.ScopeFilter(staff, "city", "locality")
+		filter["status"] = status
+		cursor, err := database.GetMessageReportCollection().Find(r.Context(), filter,
+			options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetLimit(100))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list message reports")
+			http.Error(w, "Failed to list reports", http.StatusInternalServerError)
+			return
+		}
+		reports := []models.MessageReport{}
+		if err = cursor.All(r.Context(), &reports); err != nil {
+			logrus.WithError(err).Error("Failed to decode message reports")
+			http.Error(w, "Failed to list reports", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reports": reports,
+		})
+	}
+}
+
+// ResolveMessageReportRequest closes a conversation report
+type ResolveMessageReportRequest struct {
+	Status string `json:"status"` // resolved or dismissed
+}
+
+// ResolveMessageReport marks a conversation report in the caller's scope resolved or dismissed
+func ResolveMessageReport() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+		reportID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid report ID", http.StatusBadRequest)
+			return
+		}
+		var req ResolveMessageReportRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Status != models.ReportResolved && req.Status != models.ReportDismissed) {
+			http.Error(w, "Status must be resolved or dismissed", http.StatusBadRequest)
+			return
+		}
+		var report models.MessageReport
+		err = database.GetMessageReportCollection().FindOne(r.Context(), bson.M{"_id": reportID}).Decode(&report)
+		if err != nil || !models.InScope(staff, report.City, report.Locality) {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		result, err := database.GetMessageReportCollection().UpdateOne(r.Context(), bson.M{"_id": reportID}, bson.M{"$set": bson.M{"status": req.Status}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to resolve message report")
+			http.Error(w, "Failed to update report", http.StatusInternalServerError)
+			return
+		}
+		if result.MatchedCount == 0 {
+			http.Error(w, "Report not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "Report " + req.Status})
+	}
+}
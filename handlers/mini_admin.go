@@ -1 +1,196 @@
-package handlers
\ No newline at end of file
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StaffAccessRequest is the payload for changing a mini-admin's permissions and scope.
+// Fields left out are not changed; an empty permission list takes every permission away.
+type StaffAccessRequest struct {
+	Permissions *[]models.Permission `json:"permissions,omitempty"`
+	Scope       *models.StaffScope   `json:"scope,omitempty"`
+	Unscoped    bool                 `json:"unscoped,omitempty"` // lifts the scope, for unscoped staff only
+}
+
+// checkGrantablePermissions makes sure every permission is known and held by the granting staff member,
+// so nobody can hand out more than they have
+func checkGrantablePermissions(granter models.User, permissions []models.Permission) (string, bool) {
+	for _, p := range permissions {
+		if !models.IsValidPermission(p) {
+			return "Unknown permission: " + string(p), false
+		}
+		if !models.HasPermission(granter, p) {
+			return "Cannot grant a permission you don't hold: " + string(p), false
+		}
+	}
+	return "", true
+}
+
+// checkGrantableScope makes sure a scope lies inside the granting staff member's own: its cities
+// among theirs, and its localities among theirs or in one of their cities. Unscoped staff may
+// grant any scope.
+func checkGrantableScope(ctx context.Context, granter models.User, scope *models.StaffScope) (string, bool) {
+	if models.Unscoped(granter) {
+		return "", true
+	}
+	if scope == nil {
+		return "Cannot grant access beyond your own scope", false
+	}
+	for _, city := range scope.Cities {
+		if !slices.Contains(granter.Scope.Cities, city) {
+			return "Cannot grant a city outside your scope: " + city, false
+		}
+	}
+	for _, locality := range scope.Localities {
+		if slices.Contains(granter.Scope.Localities, locality) {
+			continue
+		}
+		inCity, err := database.GetLocalityCollection().CountDocuments(ctx, bson.M{
+			"level":   models.LevelLocality,
+			"nameKey": models.NormalizePlaceName(locality),
+			"city":    bson.M{"$in": granter.Scope.Cities},
+		})
+		if err != nil || inCity == 0 {
+			return "Cannot grant a locality outside your scope: " + locality, false
+		}
+	}
+	return "", true
+}
+
+// ListMiniAdmins returns the mini-admins working inside the caller's scope with their effective
+// permissions and scope
+func ListMiniAdmins() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+		cursor, err := database.GetUserCollection().Find(r.Context(), bson.M{"role": models.MiniAdmin}, options.Find().SetProjection(bson.M{"password": 0}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list mini-admins")
+			http.Error(w, "Failed to list mini-admins", http.StatusInternalServerError)
+			return
+		}
+		var all []models.User
+		if err = cursor.All(r.Context(), &all); err != nil {
+			logrus.WithError(err).Error("Failed to decode mini-admins")
+			http.Error(w, "Failed to list mini-admins", http.StatusInternalServerError)
+			return
+		}
+		miniAdmins := []models.User{}
+		for _, miniAdmin := range all {
+			// Scoped staff only see mini-admins they could manage
+			if _, ok := checkGrantableScope(r.Context(), staff, miniAdmin.Scope); !ok {
+				continue
+			}
+			miniAdmin.Permissions = models.PermissionsFor(miniAdmin)
+			miniAdmins = append(miniAdmins, miniAdmin)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"miniAdmins": miniAdmins,
+		})
+	}
+}
+
+// UpdateMiniAdminAccess replaces a mini-admin's permissions and/or scope
+func UpdateMiniAdminAccess() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		granter := r.Context().Value(middleware.StaffKey).(models.User)
+		miniAdminID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid mini-admin ID", http.StatusBadRequest)
+			return
+		}
+
+		if miniAdminID == granter.ID {
+			http.Error(w, "You can't change your own access", http.StatusForbidden)
+			return
+		}
+
+		var req StaffAccessRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Permissions == nil && req.Scope == nil && !req.Unscoped) {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if req.Unscoped && req.Scope != nil {
+			http.Error(w, "Send either a scope or unscoped", http.StatusBadRequest)
+			return
+		}
+
+		var current models.User
+		err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": miniAdminID, "role": models.MiniAdmin}).Decode(&current)
+		if err == nil && !models.Unscoped(granter) {
+			// Scoped staff only manage mini-admins working inside their own scope
+			if _, ok := checkGrantableScope(r.Context(), granter, current.Scope); !ok {
+				err = mongo.ErrNoDocuments
+			}
+		}
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Mini-Admin not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load mini-admin")
+			http.Error(w, "Failed to update mini-admin", http.StatusInternalServerError)
+			return
+		}
+
+		set, update := bson.M{}, bson.M{}
+		if req.Permissions != nil {
+			if msg, ok := checkGrantablePermissions(granter, *req.Permissions); !ok {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+			set["permissions"] = *req.Permissions
+		}
+		if req.Scope != nil || req.Unscoped {
+			if msg, ok := checkGrantableScope(r.Context(), granter, req.Scope); !ok {
+				http.Error(w, msg, http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Scope != nil {
+			set["scope"] = req.Scope
+		}
+		if req.Unscoped {
+			update["$unset"] = bson.M{"scope": ""}
+		}
+		if len(set) > 0 {
+			update["$set"] = set
+		}
+
+		var miniAdmin models.User
+		err = database.GetUserCollection().FindOneAndUpdate(r.Context(),
+			bson.M{"_id": miniAdminID, "role": models.MiniAdmin},
+			update,
+			options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"password": 0}),
+		).Decode(&miniAdmin)
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Mini-Admin not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update mini-admin access")
+			http.Error(w, "Failed to update mini-admin", http.StatusInternalServerError)
+			return
+		}
+		miniAdmin.Permissions = models.PermissionsFor(miniAdmin)
+
+		logrus.Info("Mini-admin access updated: ", miniAdminID.Hex(), " by ", granter.ID.Hex())
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":   "Mini-Admin access updated",
+			"miniAdmin": miniAdmin,
+		})
+	}
+}
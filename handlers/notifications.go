@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"encoding/json"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MarkNotificationsReadRequest names the notifications to mark read; none means all of them
+type MarkNotificationsReadRequest struct {
+	IDs []primitive.ObjectID `json:"ids,omitempty"`
+}
+
+// ListNotifications returns the authenticated user's in-app notifications, newest first, with the unread count
+func ListNotifications() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		skip, limit := parsePagination(r.URL.Query())
+		collection := database.GetNotificationCollection()
+
+		cursor, err := collection.Find(r.Context(), bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}).SetSkip(skip).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list notifications")
+			http.Error(w, "Failed to list notifications", http.StatusInternalServerError)
+			return
+		}
+		notifications := []models.Notification{}
+		if err = cursor.All(r.Context(), &notifications); err != nil {
+			logrus.WithError(err).Error("Failed to decode notifications")
+			http.Error(w, "Failed to list notifications", http.StatusInternalServerError)
+			return
+		}
+		unread, _ := collection.CountDocuments(r.Context(), bson.M{"userId": userID, "read": false})
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"notifications": notifications,
+			"unread":        unread,
+		})
+	}
+}
+
+// MarkNotificationsRead marks some or all of the authenticated user's notifications read
+func MarkNotificationsRead() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req MarkNotificationsReadRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		filter := bson.M{"userId": userID, "read": false}
+		if len(req.IDs) > 0 {
+			filter["_id"] = bson.M{"$in": req.IDs}
+		}
+		if _, err := database.GetNotificationCollection().UpdateMany(r.Context(), filter, bson.M{"$set": bson.M{"read": true}}); err != nil {
+			logrus.WithError(err).Error("Failed to mark notifications read")
+			http.Error(w, "Failed to mark notifications read", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "Notifications marked read"})
+	}
+}
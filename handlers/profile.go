@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxAvatarBytes = 5 * 1024 * 1024
+
+// UpdateProfileRequest holds the profile fields a user may change; fields left out are not changed
+type UpdateProfileRequest struct {
+	Name              *string          `json:"name,omitempty"`
+	Email             *string          `json:"email,omitempty"`
+	PreferredLanguage *string          `json:"preferredLanguage,omitempty"`
+	City              *string          `json:"city,omitempty"`
+	UserType          *models.UserType `json:"userType,omitempty"`
+}
+
+// ChangePhoneRequest starts (without OTP) or completes (with OTP) a phone number change
+type ChangePhoneRequest struct {
+	PhoneNumber string `json:"phoneNumber"`
+	OTP         string `json:"otp,omitempty"`
+}
+
+// changePhoneOTPFilter matches the change-phone OTP a user requested for a new number
+func changePhoneOTPFilter(userID primitive.ObjectID, phoneNumber string) bson.M {
+	return bson.M{"phoneNumber": phoneNumber, "purpose": models.OTPPurposeChangePhone, "userId": userID}
+}
+
+// GetProfile returns the authenticated user's profile
+func GetProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var user models.User
+		err := database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID}, options.FindOne().SetProjection(bson.M{"password": 0})).Decode(&user)
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+// UpdateProfile changes the authenticated user's profile fields
+func UpdateProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req UpdateProfileRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		update := bson.M{"updatedAt": time.Now()}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			if name == "" {
+				http.Error(w, "Name can't be empty", http.StatusBadRequest)
+				return
+			}
+			update["name"] = name
+		}
+		if req.Email != nil {
+			email := strings.ToLower(strings.TrimSpace(*req.Email))
+			if _, err := mail.ParseAddress(email); email != "" && err != nil {
+				http.Error(w, "Invalid email address", http.StatusBadRequest)
+				return
+			}
+			update["email"] = email
+		}
+		if req.PreferredLanguage != nil {
+			update["preferredLanguage"] = strings.TrimSpace(*req.PreferredLanguage)
+		}
+		if req.City != nil {
+			update["city"] = strings.TrimSpace(*req.City)
+		}
+		if req.UserType != nil {
+			if !models.IsValidUserType(*req.UserType) {
+				http.Error(w, "User type must be buyer, tenant, owner, agent or builder", http.StatusBadRequest)
+				return
+			}
+			update["userType"] = *req.UserType
+		}
+
+		var user models.User
+		err := database.GetUserCollection().FindOneAndUpdate(r.Context(),
+			bson.M{"_id": userID},
+			bson.M{"$set": update},
+			options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"password": 0}),
+		).Decode(&user)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update profile")
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Profile updated",
+			"user":    user,
+		})
+	}
+}
+
+// UploadAvatar replaces the authenticated user's avatar with the "avatar" image of a multipart form
+func UploadAvatar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		data, ext, err := readUpload(w, r, "avatar", maxAvatarBytes, imageExtensions)
+		if err != nil {
+			http.Error(w, "Invalid avatar: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		avatarURL, err := services.SaveUpload("avatars", data, ext)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to store avatar")
+			http.Error(w, "Failed to store avatar", http.StatusInternalServerError)
+			return
+		}
+
+		var previous models.User
+		err = database.GetUserCollection().FindOneAndUpdate(r.Context(),
+			bson.M{"_id": userID},
+			bson.M{"$set": bson.M{"avatarUrl": avatarURL, "updatedAt": time.Now()}},
+		).Decode(&previous)
+		if err != nil {
+			services.DeleteUpload(avatarURL)
+			logrus.WithError(err).Error("Failed to save avatar")
+			http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+			return
+		}
+		if previous.AvatarURL != "" {
+			if err = services.DeleteUpload(previous.AvatarURL); err != nil {
+				logrus.WithError(err).Warn("Failed to delete previous avatar")
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message":   "Avatar updated",
+			"avatarUrl": avatarURL,
+		})
+	}
+}
+
+// RequestPhoneChange sends an OTP to the new phone number the user wants to switch to
+func RequestPhoneChange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req ChangePhoneRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PhoneNumber == "" {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		taken, _ := database.GetUserCollection().CountDocuments(r.Context(), bson.M{"phoneNumber": req.PhoneNumber})
+		if taken > 0 {
+			http.Error(w, "Phone number is already registered", http.StatusConflict)
+			return
+		}
+
+		otp, err := utils.GenerateOtp(req.PhoneNumber)
+		if err != nil {
+			logrus.Error("Failed to generate OTP:", err)
+			http.Error(w, "Failed to generate OTP", http.StatusInternalServerError)
+			return
+		}
+		cfg := config.GetCachedConfig()
+		otpRecord := models.OTPRecord{
+			PhoneNumber: req.PhoneNumber,
+			OTP:         otp,
+			Purpose:     models.OTPPurposeChangePhone,
+			UserID:      userID,
+			ExpiresAt:   time.Now().Add(time.Duration(cfg.OTPLifetimeMinutes) * time.Minute),
+			CreatedAt:   time.Now(),
+		}
+		_, err = database.GetOTPCollection().UpdateOne(r.Context(), changePhoneOTPFilter(userID, req.PhoneNumber), bson.M{"$set": otpRecord}, options.Update().SetUpsert(true))
+		if err != nil {
+			logrus.Error("Failed to store OTP in database:", err)
+			http.Error(w, "Failed to store OTP", http.StatusInternalServerError)
+			return
+		}
+
+		messageBody := fmt.Sprintf("Your OTP to change your phone number is: %s. Valid for %d minutes.", otp, cfg.OTPLifetimeMinutes)
+		if err = services.SendSMS(req.PhoneNumber, messageBody); err != nil {
+			logrus.Error("Failed to send OTP via SMS:", err)
+			http.Error(w, "Failed to send OTP via SMS", http.StatusInternalServerError)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "OTP sent to the new phone number",
+		})
+	}
+}
+
+// VerifyPhoneChange checks the OTP sent to the new number and swaps it onto the account
+func VerifyPhoneChange() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req ChangePhoneRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.PhoneNumber == "" || req.OTP == "" {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		otpCollection := database.GetOTPCollection()
+		filter := changePhoneOTPFilter(userID, req.PhoneNumber)
+		var storedOTP models.OTPRecord
+		err := otpCollection.FindOne(r.Context(), filter).Decode(&storedOTP)
+		if err != nil || time.Now().After(storedOTP.ExpiresAt) {
+			otpCollection.DeleteOne(r.Context(), filter)
+			http.Error(w, "OTP expired or invalid", http.StatusUnauthorized)
+			return
+		}
+		if storedOTP.OTP != req.OTP {
+			logrus.Warn("Invalid OTP provided for phone change")
+			http.Error(w, "Invalid OTP", http.StatusUnauthorized)
+			return
+		}
+		otpCollection.DeleteOne(r.Context(), filter)
+
+		var user models.User
+		err = database.GetUserCollection().FindOneAndUpdate(r.Context(),
+			bson.M{"_id": userID},
+			bson.M{"$set": bson.M{"phoneNumber": req.PhoneNumber, "updatedAt": time.Now()}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"password": 0}),
+		).Decode(&user)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "Phone number is already registered", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to change phone number")
+			http.Error(w, "Failed to change phone number", http.StatusInternalServerError)
+			return
+		}
+
+		logrus.Info("Phone number changed for user: ", userID.Hex())
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Phone number changed",
+			"user":    user,
+		})
+	}
+}
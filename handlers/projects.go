@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ProjectRequest is the payload for creating or editing a builder project
+type ProjectRequest struct {
+	Name               string   `json:"name"`
+	Description        string   `json:"description"`
+	RERAID             string   `json:"reraId"`
+	PossessionDate     string   `json:"possessionDate"` // YYYY-MM-DD
+	ConstructionStatus string   `json:"constructionStatus"`
+	Towers             []string `json:"towers,omitempty"`
+	TotalUnits         int      `json:"totalUnits"`
+	AmenityIDs         []string `json:"amenityIds,omitempty"`
+	Approvals          []string `json:"approvals,omitempty"`
+	Address            string   `json:"address"`
+	City               string   `json:"city"`
+	Locality           string   `json:"locality"`
+	LocalityID         string   `json:"localityId,omitempty"` // picked in the locality autocomplete, instead of the names
+	Images             []string `json:"images,omitempty"`
+}
+
+// ProjectSearchResult is a project matched by a search, with the range of its units that matched
+type ProjectSearchResult struct {
+	models.Project
+	MatchingUnits    int     `json:"matchingUnits"`
+	MatchingMinPrice float64 `json:"matchingMinPrice"`
+	MatchingMaxPrice float64 `json:"matchingMaxPrice"`
+}
+
+// project turns the request into a project, reporting invalid fields
+func (req ProjectRequest) project() (models.Project, error) {
+	project := models.Project{
+		Name:               strings.TrimSpace(req.Name),
+		Description:        strings.TrimSpace(req.Description),
+		RERAID:             strings.TrimSpace(req.RERAID),
+		ConstructionStatus: req.ConstructionStatus,
+		Towers:             req.Towers,
+		TotalUnits:         req.TotalUnits,
+		Approvals:          req.Approvals,
+		Address:            strings.TrimSpace(req.Address),
+		City:               strings.TrimSpace(req.City),
+		Locality:           strings.TrimSpace(req.Locality),
+		Images:             req.Images,
+	}
+	if req.PossessionDate != "" {
+		date, err := time.Parse("2006-01-02", req.PossessionDate)
+		if err != nil {
+			return project, fmt.Errorf("possessionDate must be a YYYY-MM-DD date")
+		}
+		project.PossessionDate = date
+	}
+	return project, project.Validate()
+}
+
+// CreateProject lets a verified builder list a new-construction project. Its units are then
+// posted as listings with its projectId.
+func CreateProject() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req ProjectRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		location, ok := resolveLocation(w, r, req.LocalityID, req.City, req.Locality)
+		if !ok {
+			return
+		}
+		req.City, req.Locality = location.Names()
+		project, err := req.project()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		project.CityID, project.LocalityID = location.IDs()
+		if project.AmenityIDs, ok = loadAmenityIDs(w, r, req.AmenityIDs, ""); !ok {
+			return
+		}
+
+		var user models.User
+		err = database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID, "role": models.RegularUser}).Decode(&user)
+		if err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		if user.UserType != models.Builder || !user.IsVerified() {
+			http.Error(w, "Only verified builders can list projects", http.StatusForbidden)
+			return
+		}
+
+		now := time.Now()
+		project.BuilderID = userID
+		project.CreatedAt = now
+		project.UpdatedAt = now
+		result, err := database.GetProjectCollection().InsertOne(r.Context(), project)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "A project with this RERA ID is already listed", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to create project")
+			http.Error(w, "Failed to create project", http.StatusInternalServerError)
+			return
+		}
+		project.ID = result.InsertedID.(primitive.ObjectID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(project)
+	}
+}
+
+// UpdateProject replaces the editable fields of one of the builder's projects. Location
+// changes are carried over to all of its units.
+func UpdateProject() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		projectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var req ProjectRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		location, ok := resolveLocation(w, r, req.LocalityID, req.City, req.Locality)
+		if !ok {
+			return
+		}
+		req.City, req.Locality = location.Names()
+		project, err := req.project()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		project.CityID, project.LocalityID = location.IDs()
+		if project.AmenityIDs, ok = loadAmenityIDs(w, r, req.AmenityIDs, ""); !ok {
+			return
+		}
+
+		set := bson.M{
+			"name":               project.Name,
+			"description":        project.Description,
+			"reraId":             project.RERAID,
+			"possessionDate":     project.PossessionDate,
+			"constructionStatus": project.ConstructionStatus,
+			"towers":             project.Towers,
+			"totalUnits":         project.TotalUnits,
+			"amenityIds":         project.AmenityIDs,
+			"approvals":          project.Approvals,
+			"address":            project.Address,
+			"city":               project.City,
+			"locality":           project.Locality,
+			"images":             project.Images,
+			"updatedAt":          time.Now(),
+		}
+		update := bson.M{"$set": set}
+		if unset := setPlaceIDs(set, project.CityID, project.LocalityID); len(unset) > 0 {
+			update["$unset"] = unset
+		}
+		result, err := database.GetProjectCollection().UpdateOne(r.Context(), bson.M{"_id": projectID, "builderId": userID}, update)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "A project with this RERA ID is already listed", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update project")
+			http.Error(w, "Failed to update project", http.StatusInternalServerError)
+			return
+		}
+		if result.MatchedCount == 0 {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+
+		// The units follow their project's location and gain its new amenities
+		unitSet := bson.M{"address": project.Address, "city": project.City, "locality": project.Locality}
+		unitUpdate := bson.M{"$set": unitSet}
+		if unset := setPlaceIDs(unitSet, project.CityID, project.LocalityID); len(unset) > 0 {
+			unitUpdate["$unset"] = unset
+		}
+		if len(project.AmenityIDs) > 0 {
+			labels, err := services.AmenityLabels(r.Context(), project.AmenityIDs)
+			if err != nil {
+				logrus.WithError(err).Warn("Failed to load amenity labels for the text index")
+			}
+			unitUpdate["$addToSet"] = bson.M{"amenityIds": bson.M{"$each": project.AmenityIDs}, "amenityLabels": bson.M{"$each": labels}}
+		}
+		_, err = database.GetPropertyCollection().UpdateMany(r.Context(), bson.M{"projectId": projectID}, unitUpdate)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to move units of project ", projectID.Hex())
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Project updated",
+		})
+	}
+}
+
+// ListMyProjects returns the authenticated builder's projects
+func ListMyProjects() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		cursor, err := database.GetProjectCollection().Find(r.Context(), bson.M{"builderId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list projects")
+			http.Error(w, "Failed to load projects", http.StatusInternalServerError)
+			return
+		}
+		projects := []models.Project{}
+		if err = cursor.All(r.Context(), &projects); err != nil {
+			logrus.WithError(err).Error("Failed to decode projects")
+			http.Error(w, "Failed to load projects", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"projects": projects,
+		})
+	}
+}
+
+// GetProject returns a project with its unit listings. Others only see projects with published
+// units, and only those units.
+func GetProject() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		projectID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid project ID", http.StatusBadRequest)
+			return
+		}
+
+		var project models.Project
+		err = database.GetProjectCollection().FindOne(r.Context(), bson.M{"_id": projectID}).Decode(&project)
+		isBuilder := err == nil && project.BuilderID == userID
+		if err != nil || (!isBuilder && project.PublishedUnits == 0) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+
+		filter := bson.M{"projectId": projectID}
+		if !isBuilder {
+			filter["status"] = property.Published
+		}
+		cursor, err := database.GetPropertyCollection().Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "price", Value: 1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load project units")
+			http.Error(w, "Failed to load project", http.StatusInternalServerError)
+			return
+		}
+		units := []property.Property{}
+		if err = cursor.All(r.Context(), &units); err != nil {
+			logrus.WithError(err).Error("Failed to decode project units")
+			http.Error(w, "Failed to load project", http.StatusInternalServerError)
+			return
+		}
+		attachListers(r.Context(), units)
+		markFavorited(r.Context(), userID, units)
+		services.MarkPriceDrops(units)
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"project": project,
+			"units":   units,
+		})
+	}
+}
+
+// searchProjects answers a listing search with the projects owning matching units, most
+// recently active first, instead of the units themselves
+func searchProjects(w http.ResponseWriter, r *http.Request, query bson.M, skip, limit int64) {
+	query["projectId"] = bson.M{"$exists": true}
+	cursor, err := database.GetPropertyCollection().Aggregate(r.Context(), []bson.M{
+		{"$match": query},
+		{"$group": bson.M{
+			"_id":      "$projectId",
+			"units":    bson.M{"$sum": 1},
+			"minPrice": bson.M{"$min": "$price"},
+			"maxPrice": bson.M{"$max": "$price"},
+			"latest":   bson.M{"$max": "$bumpedAt"},
+		}},
+		{"$sort": bson.D{{Key: "latest", Value: -1}, {Key: "_id", Value: 1}}},
+		{"$facet": bson.M{
+			"total": []bson.M{{"$count": "count"}},
+			"page":  []bson.M{{"$skip": skip}, {"$limit": limit}},
+		}},
+	})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to search projects")
+		http.Error(w, "Failed to search listings", http.StatusInternalServerError)
+		return
+	}
+	var facets []struct {
+		Total []struct {
+			Count int64 `bson:"count"`
+		} `bson:"total"`
+		Page []struct {
+			ID       primitive.ObjectID `bson:"_id"`
+			Units    int                `bson:"units"`
+			MinPrice float64            `bson:"minPrice"`
+			MaxPrice float64            `bson:"maxPrice"`
+		} `bson:"page"`
+	}
+	if err = cursor.All(r.Context(), &facets); err != nil || len(facets) == 0 {
+		logrus.WithError(err).Error("Failed to decode project search")
+		http.Error(w, "Failed to search listings", http.StatusInternalServerError)
+		return
+	}
+
+	var total int64
+	if len(facets[0].Total) > 0 {
+		total = facets[0].Total[0].Count
+	}
+	ids := make([]primitive.ObjectID, 0, len(facets[0].Page))
+	for _, match := range facets[0].Page {
+		ids = append(ids, match.ID)
+	}
+	cursor, err = database.GetProjectCollection().Find(r.Context(), bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load matched projects")
+		http.Error(w, "Failed to search listings", http.StatusInternalServerError)
+		return
+	}
+	var projects []models.Project
+	if err = cursor.All(r.Context(), &projects); err != nil {
+		logrus.WithError(err).Error("Failed to decode matched projects")
+		http.Error(w, "Failed to search listings", http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[primitive.ObjectID]models.Project, len(projects))
+	for _, project := range projects {
+		byID[project.ID] = project
+	}
+
+	results := make([]ProjectSearchResult, 0, len(ids))
+	for _, match := range facets[0].Page {
+		project, ok := byID[match.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, ProjectSearchResult{
+			Project:          project,
+			MatchingUnits:    match.Units,
+			MatchingMinPrice: match.MinPrice,
+			MatchingMaxPrice: match.MaxPrice,
+		})
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"projects": results,
+		"total":    total,
+	})
+}
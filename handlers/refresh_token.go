@@ -62,9 +62,10 @@ func RefreshAccessToken() http.HandlerFunc {
             http.Error(w, "User not found", http.StatusNotFound)
             return
         }
+        user.Password = nil
 
         // Generate New Access & Refresh Token
-        newAccessToken, _ := utils.GenerateAccessToken(user.ID, cfg.JWTSecret)
+        newAccessToken, _ := utils.GenerateAccessToken(user.ID, string(user.Role), cfg.JWTSecret)
         newRefreshToken, _ := utils.GenerateRefreshToken(user.ID, cfg.RefreshTokenSecret, cfg.RefreshTokenLifetimeHours)
 
         // Store New Refresh Token
@@ -82,9 +83,10 @@ func RefreshAccessToken() http.HandlerFunc {
 
         w.WriteHeader(http.StatusOK)
         json.NewEncoder(w).Encode(models.RefreshResponse{
-            Message:     "Tokens refreshed successfully",
-            AccessToken: newAccessToken,
-            User:        user,
+            Message:      "Tokens refreshed successfully",
+            AccessToken:  newAccessToken,
+            RefreshToken: newRefreshToken,
+            User:         user,
         })
     }
 }
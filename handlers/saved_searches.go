@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxSavedSearches = 20
+
+// SavedSearchRequest creates or edits a saved search; fields left out of an edit are not changed
+type SavedSearchRequest struct {
+	Name          *string                 `json:"name,omitempty"`
+	Filters       *property.SearchFilters `json:"filters,omitempty"`
+	Frequency     *string                 `json:"frequency,omitempty"`
+	AlertsEnabled *bool                   `json:"alertsEnabled,omitempty"`
+}
+
+// AlertSubscriptionRequest turns every saved-search alert of the user off or back on
+type AlertSubscriptionRequest struct {
+	Subscribed bool `json:"subscribed"`
+}
+
+// CreateSavedSearch saves a listing search for the authenticated user, alerting on new matches from now on
+func CreateSavedSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req SavedSearchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == nil || strings.TrimSpace(*req.Name) == "" || req.Filters == nil {
+			http.Error(w, "Name and filters are required", http.StatusBadRequest)
+			return
+		}
+		frequency := models.AlertDaily
+		if req.Frequency != nil {
+			frequency = *req.Frequency
+		}
+		if _, ok := models.AlertInterval(frequency); !ok {
+			http.Error(w, "Frequency must be instant, daily or weekly", http.StatusBadRequest)
+			return
+		}
+
+		collection := database.GetSavedSearchCollection()
+		count, _ := collection.CountDocuments(r.Context(), bson.M{"userId": userID})
+		if count >= maxSavedSearches {
+			http.Error(w, fmt.Sprintf("At most %d searches can be saved", maxSavedSearches), http.StatusConflict)
+			return
+		}
+
+		services.CanonicalizeSearchFilters(r.Context(), req.Filters)
+		now := time.Now()
+		search := models.SavedSearch{
+			UserID:        userID,
+			Name:          strings.TrimSpace(*req.Name),
+			Filters:       *req.Filters,
+			Frequency:     frequency,
+			AlertsEnabled: req.AlertsEnabled == nil || *req.AlertsEnabled,
+			LastCheckedAt: now,
+			LastDigestAt:  now,
+			CreatedAt:     now,
+		}
+		result, err := collection.InsertOne(r.Context(), search)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to save search")
+			http.Error(w, "Failed to save search", http.StatusInternalServerError)
+			return
+		}
+		search.ID = result.InsertedID.(primitive.ObjectID)
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":     "Search saved",
+			"savedSearch": search,
+		})
+	}
+}
+
+// ListSavedSearches returns the authenticated user's saved searches
+func ListSavedSearches() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		cursor, err := database.GetSavedSearchCollection().Find(r.Context(), bson.M{"userId": userID}, options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list saved searches")
+			http.Error(w, "Failed to list saved searches", http.StatusInternalServerError)
+			return
+		}
+		searches := []models.SavedSearch{}
+		if err = cursor.All(r.Context(), &searches); err != nil {
+			logrus.WithError(err).Error("Failed to decode saved searches")
+			http.Error(w, "Failed to list saved searches", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"savedSearches": searches,
+		})
+	}
+}
+
+// UpdateSavedSearch renames a saved search or changes its filters, frequency or alerts
+func UpdateSavedSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		searchID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid saved search ID", http.StatusBadRequest)
+			return
+		}
+
+		var req SavedSearchRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		update := bson.M{}
+		if req.Name != nil {
+			if strings.TrimSpace(*req.Name) == "" {
+				http.Error(w, "Name can't be empty", http.StatusBadRequest)
+				return
+			}
+			update["name"] = strings.TrimSpace(*req.Name)
+		}
+		if req.Filters != nil {
+			// Only listings published after the change count as new for the new filters
+			services.CanonicalizeSearchFilters(r.Context(), req.Filters)
+			update["filters"] = *req.Filters
+			update["lastCheckedAt"] = time.Now()
+		}
+		if req.Frequency != nil {
+			if _, ok := models.AlertInterval(*req.Frequency); !ok {
+				http.Error(w, "Frequency must be instant, daily or weekly", http.StatusBadRequest)
+				return
+			}
+			update["frequency"] = *req.Frequency
+		}
+		if req.AlertsEnabled != nil {
+			update["alertsEnabled"] = *req.AlertsEnabled
+		}
+		if len(update) == 0 {
+			http.Error(w, "Nothing to update", http.StatusBadRequest)
+			return
+		}
+
+		var search models.SavedSearch
+		err = database.GetSavedSearchCollection().FindOneAndUpdate(r.Context(),
+			bson.M{"_id": searchID, "userId": userID},
+			bson.M{"$set": update},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&search)
+		if err != nil {
+			http.Error(w, "Saved search not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":     "Saved search updated",
+			"savedSearch": search,
+		})
+	}
+}
+
+// DeleteSavedSearch removes one of the authenticated user's saved searches
+func DeleteSavedSearch() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		searchID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid saved search ID", http.StatusBadRequest)
+			return
+		}
+		result, err := database.GetSavedSearchCollection().DeleteOne(r.Context(), bson.M{"_id": searchID, "userId": userID})
+		if err != nil || result.DeletedCount == 0 {
+			http.Error(w, "Saved search not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "Saved search deleted"})
+	}
+}
+
+// SetAlertSubscription turns all of the authenticated user's saved-search alerts off or back on
+func SetAlertSubscription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+
+		var req AlertSubscriptionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		_, err := database.GetUserCollection().UpdateOne(r.Context(), bson.M{"_id": userID}, bson.M{"$set": bson.M{"alertsUnsubscribed": !req.Subscribed}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update alert subscription")
+			http.Error(w, "Failed to update alert subscription", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":    "Alert subscription updated",
+			"subscribed": req.Subscribed,
+		})
+	}
+}
+
+// UnsubscribeAlertsByLink turns off all saved-search alerts of the user named in a signed
+// link from an alert, without requiring a login
+func UnsubscribeAlertsByLink() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userHex := r.URL.Query().Get("user")
+		token := r.URL.Query().Get("token")
+		userID, err := primitive.ObjectIDFromHex(userHex)
+		if err != nil || !utils.VerifySignedValue(services.UnsubscribePurpose, userHex, token, config.GetCachedConfig().JWTSecret) {
+			http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+			return
+		}
+		_, err = database.GetUserCollection().UpdateOne(r.Context(), bson.M{"_id": userID}, bson.M{"$set": bson.M{"alertsUnsubscribed": true}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to unsubscribe alerts")
+			http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"message": "You won't receive saved search alerts anymore"})
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/utils"
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Most amenities counted in the amenity facet
+const maxAmenityFacets = 50
+
+// Bucket id of prices above the last price bucket bound
+const openPriceBucket = "above"
+
+// SearchFacets counts the listings matching a search by amenity, property type, bedrooms and price
+type SearchFacets struct {
+	Amenities     []AmenityFacet           `json:"amenities"`
+	PropertyTypes []FacetCount             `json:"propertyTypes"`
+	Bedrooms      []FacetCount             `json:"bedrooms"`
+	Price         map[string][]PriceBucket `json:"price"` // by listing type
+}
+
+// FacetCount is the number of matching listings having a value
+type FacetCount struct {
+	Value interface{} `json:"value" bson:"_id"`
+	Count int         `json:"count" bson:"count"`
+}
+
+// AmenityFacet is the number of matching listings offering an amenity
+type AmenityFacet struct {
+	AmenityID primitive.ObjectID `json:"amenityId" bson:"_id"`
+	Label     string             `json:"label" bson:"label"`
+	IconKey   string             `json:"iconKey" bson:"iconKey"`
+	Category  string             `json:"category" bson:"category"`
+	Count     int                `json:"count" bson:"count"`
+}
+
+// PriceBucket is the number of matching listings priced from Min up to, not including, Max
+type PriceBucket struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"` // none for the open-ended last bucket
+	Count int      `json:"count"`
+}
+
+// searchPage is the result of the search aggregation
+type searchPage struct {
+	Listings []property.Property `bson:"listings"`
+	Total    []struct {
+		Count int64 `bson:"count"`
+	} `bson:"total"`
+	Amenities     []AmenityFacet `bson:"amenities"`
+	PropertyTypes []FacetCount   `bson:"propertyTypes"`
+	Bedrooms      []FacetCount   `bson:"bedrooms"`
+	SalePrice     []FacetCount   `bson:"salePrice"`
+	RentPrice     []FacetCount   `bson:"rentPrice"`
+}
+
+// relevanceStage ranks listings by how recently they were published or refreshed (halving after a
+// month) and their quality score (middling until scored), and text search matches first by how
+// well they match
+func relevanceStage(textSearch bool) bson.M {
+	ageDays := bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{"$$NOW", "$bumpedAt"}}, 24 * 60 * 60 * 1000}}
+	recency := bson.M{"$divide": bson.A{1, bson.M{"$add": bson.A{1, bson.M{"$divide": bson.A{ageDays, 30}}}}}}
+	quality := bson.M{"$divide": bson.A{bson.M{"$ifNull": bson.A{"$quality.score", 50}}, 100}}
+	terms := bson.A{recency, quality}
+	if textSearch {
+		terms = append(terms, bson.M{"$meta": "textScore"})
+	}
+	return bson.M{"$addFields": bson.M{"relevance": bson.M{"$add": terms}}}
+}
+
+// searchPipeline returns one aggregation producing a page of the listings matching query by
+// relevance, their total and the facet counts over all of them. textSearch ranks text matches by
+// how well they match.
+func searchPipeline(query bson.M, textSearch bool, skip, limit int64) []bson.M {
+	priceFacet := func(listingType string) []bson.M {
+		return []bson.M{
+			{"$match": bson.M{"listingType": listingType}},
+			{"$bucket": bson.M{
+				"groupBy":    "$price",
+				"boundaries": property.PriceBuckets[listingType],
+				"default":    openPriceBucket,
+				"output":     bson.M{"count": bson.M{"$sum": 1}},
+			}},
+		}
+	}
+	pipeline := []bson.M{{"$match": query}, relevanceStage(textSearch)}
+	sort := bson.D{{Key: "relevance", Value: -1}, {Key: "bumpedAt", Value: -1}, {Key: "_id", Value: -1}}
+	return append(pipeline,
+		bson.M{"$facet": bson.M{
+			"listings": []bson.M{{"$sort": sort}, {"$skip": skip}, {"$limit": limit}},
+			"total":    []bson.M{{"$count": "count"}},
+			"amenities": []bson.M{
+				{"$unwind": "$amenityIds"},
+				{"$group": bson.M{"_id": "$amenityIds", "count": bson.M{"$sum": 1}}},
+				{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+				{"$limit": maxAmenityFacets},
+				{"$lookup": bson.M{"from": "amenities", "localField": "_id", "foreignField": "_id", "as": "amenity"}},
+				{"$unwind": "$amenity"},
+				{"$project": bson.M{"count": 1, "label": "$amenity.label", "iconKey": "$amenity.iconKey", "category": "$amenity.category"}},
+			},
+			"propertyTypes": []bson.M{
+				{"$group": bson.M{"_id": "$propertyType", "count": bson.M{"$sum": 1}}},
+				{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			},
+			"bedrooms": []bson.M{
+				{"$group": bson.M{"_id": "$bedrooms", "count": bson.M{"$sum": 1}}},
+				{"$sort": bson.D{{Key: "_id", Value: 1}}},
+			},
+			"salePrice": priceFacet("sale"),
+			"rentPrice": priceFacet("rent"),
+		}},
+	)
+}
+
+// runSearch runs the search aggregation for the filters
+func runSearch(ctx context.Context, filters property.SearchFilters, skip, limit int64) (searchPage, error) {
+	// Facet counts need the whole result set, which may not fit the in-memory sort limit
+	cursor, err := database.GetPropertyCollection().Aggregate(ctx, searchPipeline(filters.Query(), filters.Text != "", skip, limit),
+		options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return searchPage{}, err
+	}
+	var pages []searchPage
+	if err = cursor.All(ctx, &pages); err != nil {
+		return searchPage{}, err
+	}
+	if len(pages) == 0 {
+		return searchPage{}, nil
+	}
+	return pages[0], nil
+}
+
+// total returns the number of listings matching the search
+func (p searchPage) total() int64 {
+	if len(p.Total) == 0 {
+		return 0
+	}
+	return p.Total[0].Count
+}
+
+// highlightListings sets the title and description snippets showing where the listings match terms
+func highlightListings(listings []property.Property, terms []string) {
+	for i := range listings {
+		highlights := map[string]string{}
+		if snippet := utils.Highlight(listings[i].Title, terms, 120); snippet != "" {
+			highlights["title"] = snippet
+		}
+		if snippet := utils.Highlight(listings[i].Description, terms, 160); snippet != "" {
+			highlights["description"] = snippet
+		}
+		if snippet := utils.Highlight(listings[i].Locality, terms, 60); snippet != "" {
+			highlights["locality"] = snippet
+		}
+		if len(highlights) > 0 {
+			listings[i].Highlights = highlights
+		}
+	}
+}
+
+// facets returns the facet counts of the page
+func (p searchPage) facets() SearchFacets {
+	return SearchFacets{
+		Amenities:     nonNil(p.Amenities),
+		PropertyTypes: nonNil(p.PropertyTypes),
+		Bedrooms:      nonNil(p.Bedrooms),
+		Price: map[string][]PriceBucket{
+			"sale": priceBuckets(property.PriceBuckets["sale"], p.SalePrice),
+			"rent": priceBuckets(property.PriceBuckets["rent"], p.RentPrice),
+		},
+	}
+}
+
+// priceBuckets turns $bucket counts into price ranges, leaving out empty buckets
+func priceBuckets(bounds []float64, counts []FacetCount) []PriceBucket {
+	buckets := []PriceBucket{}
+	for _, count := range counts {
+		var min float64
+		switch v := count.Value.(type) {
+		case float64:
+			min = v
+		case string: // openPriceBucket
+			min = bounds[len(bounds)-1]
+		default:
+			continue
+		}
+		bucket := PriceBucket{Min: min, Count: count.Count}
+		for i, bound := range bounds {
+			if bound == min && i+1 < len(bounds) {
+				max := bounds[i+1]
+				bucket.Max = &max
+			}
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// nonNil returns values, or an empty slice so that it is encoded as [] rather than null
+func nonNil[T any](values []T) []T {
+	if values == nil {
+		return []T{}
+	}
+	return values
+}
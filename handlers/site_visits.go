@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	minVisitNotice    = time.Hour           // how soon a visit may start after booking it
+	maxVisitLookahead = 60 * 24 * time.Hour // how far ahead a visit may be booked
+	maxSlotQueryDays  = 30
+	visitFeedPastDays = 30
+)
+
+// VisitAvailabilityRequest sets when a listing can be visited
+type VisitAvailabilityRequest struct {
+	Windows       []models.AvailabilityWindow `json:"windows"`
+	SlotMinutes   int                         `json:"slotMinutes"`
+	BlackoutDates []string                    `json:"blackoutDates,omitempty"`
+	TimeZone      string                      `json:"timeZone,omitempty"` // defaults to the app's time zone
+}
+
+// VisitTimeRequest books or reschedules a visit to the slot starting at Start
+type VisitTimeRequest struct {
+	Start time.Time `json:"start"`
+}
+
+// CancelVisitRequest cancels a visit
+type CancelVisitRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// checkVisitTime verifies a visit may start at start under the listing's availability
+func checkVisitTime(availability models.VisitAvailability, start time.Time) error {
+	now := time.Now()
+	if start.Before(now.Add(minVisitNotice)) {
+		return fmt.Errorf("visits must be booked at least %d minutes ahead", int(minVisitNotice.Minutes()))
+	}
+	if start.After(now.Add(maxVisitLookahead)) {
+		return fmt.Errorf("visits can be booked at most %d days ahead", int(maxVisitLookahead.Hours()/24))
+	}
+	if !availability.IsSlot(start) {
+		return fmt.Errorf("the lister isn't available at that time")
+	}
+	return nil
+}
+
+// loadVisit loads the visit named by the {id} path variable if the authenticated user is its buyer or lister
+func loadVisit(w http.ResponseWriter, r *http.Request) (models.SiteVisit, primitive.ObjectID, bool) {
+	userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+	var visit models.SiteVisit
+	visitID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid visit ID", http.StatusBadRequest)
+		return visit, userID, false
+	}
+	err = database.GetSiteVisitCollection().FindOne(r.Context(), bson.M{
+		"_id": visitID,
+		"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}},
+	}).Decode(&visit)
+	if err != nil {
+		http.Error(w, "Visit not found", http.StatusNotFound)
+		return visit, userID, false
+	}
+	return visit, userID, true
+}
+
+// otherVisitParty returns the side of the visit that isn't userID
+func otherVisitParty(visit models.SiteVisit, userID primitive.ObjectID) primitive.ObjectID {
+	if visit.BuyerID == userID {
+		return visit.OwnerID
+	}
+	return visit.BuyerID
+}
+
+// SetVisitAvailability publishes the weekly visit windows and blackout dates of one of the user's listings
+func SetVisitAvailability() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+
+		var req VisitAvailabilityRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		availability := models.VisitAvailability{
+			PropertyID:    propertyID,
+			OwnerID:       userID,
+			Windows:       req.Windows,
+			SlotMinutes:   req.SlotMinutes,
+			BlackoutDates: req.BlackoutDates,
+			TimeZone:      req.TimeZone,
+			UpdatedAt:     time.Now(),
+		}
+		if availability.TimeZone == "" {
+			availability.TimeZone = config.GetCachedConfig().DefaultTimeZone
+		}
+		if err = availability.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		count, _ := database.GetPropertyCollection().CountDocuments(r.Context(), bson.M{"_id": propertyID, "ownerId": userID, "status": bson.M{"$in": activeListingStatuses}})
+		if count == 0 {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+
+		_, err = database.GetVisitAvailabilityCollection().ReplaceOne(r.Context(), bson.M{"propertyId": propertyID}, availability, options.Replace().SetUpsert(true))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to save visit availability")
+			http.Error(w, "Failed to save availability", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":      "Availability saved",
+			"availability": availability,
+		})
+	}
+}
+
+// parseSlotRange reads ?from= (YYYY-MM-DD, default today) and ?days= (default 7) in loc
+func parseSlotRange(query url.Values, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			return from, from, fmt.Errorf("from must be a YYYY-MM-DD date")
+		}
+		from = parsed
+	}
+	days := 7
+	if value := query.Get("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxSlotQueryDays {
+			return from, from, fmt.Errorf("days must be between 1 and %d", maxSlotQueryDays)
+		}
+		days = parsed
+	}
+	return from, from.AddDate(0, 0, days), nil
+}
+
+// GetVisitSlots returns a published listing's visit availability and its open slots in the requested range
+func GetVisitSlots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+		var availability models.VisitAvailability
+		if err = database.GetVisitAvailabilityCollection().FindOne(r.Context(), bson.M{"propertyId": propertyID}).Decode(&availability); err != nil {
+			http.Error(w, "The lister hasn't published visit availability", http.StatusNotFound)
+			return
+		}
+		loc, err := time.LoadLocation(availability.TimeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+		from, to, err := parseSlotRange(r.URL.Query(), loc)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if earliest := time.Now().Add(minVisitNotice); from.Before(earliest) {
+			from = earliest
+		}
+
+		booked, err := database.GetSiteVisitCollection().Distinct(r.Context(), "start", bson.M{
+			"propertyId": propertyID,
+			"slotKey":    bson.M{"$exists": true},
+			"start":      bson.M{"$gte": from, "$lt": to},
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load booked visit slots")
+			http.Error(w, "Failed to load slots", http.StatusInternalServerError)
+			return
+		}
+		taken := make(map[int64]bool, len(booked))
+		for _, value := range booked {
+			if start, ok := value.(primitive.DateTime); ok {
+				taken[start.Time().Unix()] = true
+			}
+		}
+		slots := []time.Time{}
+		for _, slot := range availability.Slots(from, to) {
+			if !taken[slot.Unix()] {
+				slots = append(slots, slot)
+			}
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"availability": availability,
+			"slots":        slots,
+		})
+	}
+}
+
+// BookSiteVisit books a visit slot of a published listing for the authenticated user; the lister then confirms it
+func BookSiteVisit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		propertyID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+		if err != nil {
+			http.Error(w, "Invalid listing ID", http.StatusBadRequest)
+			return
+		}
+		var req VisitTimeRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.Start.IsZero() {
+			http.Error(w, "Start time is required", http.StatusBadRequest)
+			return
+		}
+
+		var listing property.Property
+		if err = database.GetPropertyCollection().FindOne(r.Context(), bson.M{"_id": propertyID, "status": property.Published}).Decode(&listing); err != nil {
+			http.Error(w, "Listing not found", http.StatusNotFound)
+			return
+		}
+		if listing.OwnerID == userID {
+			http.Error(w, "You can't book a visit to your own listing", http.StatusBadRequest)
+			return
+		}
+		var availability models.VisitAvailability
+		if err = database.GetVisitAvailabilityCollection().FindOne(r.Context(), bson.M{"propertyId": propertyID}).Decode(&availability); err != nil {
+			http.Error(w, "The lister hasn't published visit availability", http.StatusConflict)
+			return
+		}
+		if err = checkVisitTime(availability, req.Start); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		collection := database.GetSiteVisitCollection()
+		upcoming, _ := collection.CountDocuments(r.Context(), bson.M{"propertyId": propertyID, "buyerId": userID, "slotKey": bson.M{"$exists": true}, "start": bson.M{"$gt": time.Now()}})
+		if upcoming > 0 {
+			http.Error(w, "You already have a visit booked for this listing, reschedule it instead", http.StatusConflict)
+			return
+		}
+
+		now := time.Now()
+		visit := models.SiteVisit{
+			PropertyID:  propertyID,
+			OwnerID:     listing.OwnerID,
+			BuyerID:     userID,
+			Start:       req.Start,
+			End:         req.Start.Add(time.Duration(availability.SlotMinutes) * time.Minute),
+			Status:      models.VisitRequested,
+			RequestedBy: userID,
+			SlotKey:     models.VisitSlotKey(propertyID, req.Start),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		result, err := collection.InsertOne(r.Context(), visit)
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "That slot was just booked, pick another one", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to book site visit")
+			http.Error(w, "Failed to book visit", http.StatusInternalServerError)
+			return
+		}
+		visit.ID = result.InsertedID.(primitive.ObjectID)
+
+		database.GetLeadCollection().UpdateOne(r.Context(),
+			bson.M{"propertyId": propertyID, "buyerId": userID, "status": bson.M{"$in": []models.LeadStatus{models.LeadNew, models.LeadContacted}}},
+			bson.M{"$set": bson.M{"status": models.LeadSiteVisitScheduled, "updatedAt": now}})
+		services.NotifyVisitParty(r.Context(), listing.OwnerID, visit, "Site visit requested for "+listing.Title,
+			"A buyer wants to visit on "+services.FormatVisitTime(visit.Start)+". Confirm or reschedule it.")
+
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Visit requested, waiting for the lister to confirm",
+			"visit":   visit,
+		})
+	}
+}
+
+// ListSiteVisits returns the authenticated user's visits, as buyer and lister, soonest first.
+// ?as=buyer or ?as=lister narrows them to one side and ?upcoming=true leaves out past and cancelled visits.
+func ListSiteVisits() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		query := r.URL.Query()
+		skip, limit := parsePagination(query)
+
+		var filter bson.M
+		switch query.Get("as") {
+		case "buyer":
+			filter = bson.M{"buyerId": userID}
+		case "lister":
+			filter = bson.M{"ownerId": userID}
+		case "":
+			filter = bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}}
+		default:
+			http.Error(w, "as must be buyer or lister", http.StatusBadRequest)
+			return
+		}
+		if query.Get("upcoming") == "true" {
+			filter["start"] = bson.M{"$gt": time.Now()}
+			filter["status"] = bson.M{"$ne": models.VisitCancelled}
+		}
+
+		cursor, err := database.GetSiteVisitCollection().Find(r.Context(), filter, options.Find().SetSort(bson.D{{Key: "start", Value: 1}}).SetSkip(skip).SetLimit(limit))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list site visits")
+			http.Error(w, "Failed to list visits", http.StatusInternalServerError)
+			return
+		}
+		visits := []models.SiteVisit{}
+		if err = cursor.All(r.Context(), &visits); err != nil {
+			logrus.WithError(err).Error("Failed to decode site visits")
+			http.Error(w, "Failed to list visits", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"visits": visits,
+		})
+	}
+}
+
+// ConfirmSiteVisit confirms a requested visit; only the side that didn't pick the time may confirm it
+func ConfirmSiteVisit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		visit, userID, ok := loadVisit(w, r)
+		if !ok {
+			return
+		}
+		if visit.Status != models.VisitRequested || !visit.Start.After(time.Now()) {
+			http.Error(w, "Only upcoming requested visits can be confirmed", http.StatusConflict)
+			return
+		}
+		if visit.RequestedBy == userID {
+			http.Error(w, "The other side has to confirm the time you picked", http.StatusForbidden)
+			return
+		}
+
+		result, err := database.GetSiteVisitCollection().UpdateOne(r.Context(),
+			bson.M{"_id": visit.ID, "status": models.VisitRequested, "sequence": visit.Sequence},
+			bson.M{"$set": bson.M{"status": models.VisitConfirmed, "updatedAt": time.Now()}, "$inc": bson.M{"sequence": 1}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to confirm site visit")
+			http.Error(w, "Failed to confirm visit", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Visit was changed meanwhile, reload and try again", http.StatusConflict)
+			return
+		}
+
+		services.NotifyVisitParty(r.Context(), otherVisitParty(visit, userID), visit, "Site visit confirmed",
+			"Your site visit on "+services.FormatVisitTime(visit.Start)+" is confirmed.")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Visit confirmed",
+			"status":  models.VisitConfirmed,
+		})
+	}
+}
+
+// RescheduleSiteVisit moves a visit to another free slot; the other side then has to confirm the new time
+func RescheduleSiteVisit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		visit, userID, ok := loadVisit(w, r)
+		if !ok {
+			return
+		}
+		var req VisitTimeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Start.IsZero() {
+			http.Error(w, "Start time is required", http.StatusBadRequest)
+			return
+		}
+		if visit.Status == models.VisitCancelled || !visit.Start.After(time.Now()) {
+			http.Error(w, "Only upcoming visits can be rescheduled", http.StatusConflict)
+			return
+		}
+		var availability models.VisitAvailability
+		if err := database.GetVisitAvailabilityCollection().FindOne(r.Context(), bson.M{"propertyId": visit.PropertyID}).Decode(&availability); err != nil {
+			http.Error(w, "The lister hasn't published visit availability", http.StatusConflict)
+			return
+		}
+		if err := checkVisitTime(availability, req.Start); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := database.GetSiteVisitCollection().UpdateOne(r.Context(),
+			bson.M{"_id": visit.ID, "sequence": visit.Sequence},
+			bson.M{
+				"$set": bson.M{
+					"start":       req.Start,
+					"end":         req.Start.Add(time.Duration(availability.SlotMinutes) * time.Minute),
+					"status":      models.VisitRequested,
+					"requestedBy": userID,
+					"slotKey":     models.VisitSlotKey(visit.PropertyID, req.Start),
+					"updatedAt":   time.Now(),
+				},
+				"$unset": bson.M{"reminderSentAt": ""},
+				"$inc":   bson.M{"sequence": 1},
+			})
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "That slot is already booked, pick another one", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to reschedule site visit")
+			http.Error(w, "Failed to reschedule visit", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Visit was changed meanwhile, reload and try again", http.StatusConflict)
+			return
+		}
+
+		services.NotifyVisitParty(r.Context(), otherVisitParty(visit, userID), visit, "Site visit rescheduled",
+			"Your site visit was moved to "+services.FormatVisitTime(req.Start)+". Confirm the new time or pick another one.")
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Visit rescheduled, waiting for the other side to confirm",
+			"status":  models.VisitRequested,
+		})
+	}
+}
+
+// CancelSiteVisit cancels a visit and frees its slot
+func CancelSiteVisit() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		visit, userID, ok := loadVisit(w, r)
+		if !ok {
+			return
+		}
+		var req CancelVisitRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if visit.Status == models.VisitCancelled {
+			http.Error(w, "Visit is already cancelled", http.StatusConflict)
+			return
+		}
+
+		result, err := database.GetSiteVisitCollection().UpdateOne(r.Context(),
+			bson.M{"_id": visit.ID, "status": bson.M{"$ne": models.VisitCancelled}},
+			bson.M{
+				"$set":   bson.M{"status": models.VisitCancelled, "cancelledBy": userID, "cancelReason": req.Reason, "updatedAt": time.Now()},
+				"$unset": bson.M{"slotKey": ""},
+				"$inc":   bson.M{"sequence": 1},
+			})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to cancel site visit")
+			http.Error(w, "Failed to cancel visit", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Visit is already cancelled", http.StatusConflict)
+			return
+		}
+
+		body := "The site visit on " + services.FormatVisitTime(visit.Start) + " was cancelled."
+		if req.Reason != "" {
+			body += " Reason: " + req.Reason
+		}
+		services.NotifyVisitParty(r.Context(), otherVisitParty(visit, userID), visit, "Site visit cancelled", body)
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Visit cancelled",
+			"status":  models.VisitCancelled,
+		})
+	}
+}
+
+// visitCalendar renders visits as an iCalendar file, looking up their listings
+func visitCalendar(ctx context.Context, name string, visits []models.SiteVisit) ([]byte, error) {
+	ids := make([]primitive.ObjectID, 0, len(visits))
+	for _, visit := range visits {
+		ids = append(ids, visit.PropertyID)
+	}
+	cursor, err := database.GetPropertyCollection().Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var listings []property.Property
+	if err = cursor.All(ctx, &listings); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]property.Property, len(listings))
+	for _, listing := range listings {
+		byID[listing.ID] = listing
+	}
+
+	events := make([]utils.CalendarEvent, 0, len(visits))
+	for _, visit := range visits {
+		events = append(events, services.VisitCalendarEvent(visit, byID[visit.PropertyID]))
+	}
+	return utils.WriteCalendar(name, events), nil
+}
+
+// writeCalendar sends an iCalendar file, as a download when filename is set
+func writeCalendar(w http.ResponseWriter, data []byte, filename string) {
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	if filename != "" {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+	}
+	w.Write(data)
+}
+
+// DownloadVisitCalendar returns one visit as an .ics file to add to a calendar app
+func DownloadVisitCalendar() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		visit, _, ok := loadVisit(w, r)
+		if !ok {
+			return
+		}
+		data, err := visitCalendar(r.Context(), "Site visit", []models.SiteVisit{visit})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to build visit calendar")
+			http.Error(w, "Failed to build calendar", http.StatusInternalServerError)
+			return
+		}
+		writeCalendar(w, data, "site-visit-"+visit.ID.Hex()+".ics")
+	}
+}
+
+// GetVisitCalendarFeed returns the authenticated user's private calendar feed link, for subscribing from a calendar app
+func GetVisitCalendarFeed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+		token := utils.SignValue(services.VisitCalendarPurpose, userID.Hex(), config.GetCachedConfig().JWTSecret)
+		json.NewEncoder(w).Encode(map[string]string{
+			"url": "/calendar/visits.ics?user=" + userID.Hex() + "&token=" + token,
+		})
+	}
+}
+
+// VisitCalendarFeed serves the site visits of the user named in a signed feed link as an iCalendar feed
+func VisitCalendarFeed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userHex := r.URL.Query().Get("user")
+		userID, err := primitive.ObjectIDFromHex(userHex)
+		if err != nil || !utils.VerifySignedValue(services.VisitCalendarPurpose, userHex, r.URL.Query().Get("token"), config.GetCachedConfig().JWTSecret) {
+			http.Error(w, "Invalid calendar link", http.StatusNotFound)
+			return
+		}
+
+		cursor, err := database.GetSiteVisitCollection().Find(r.Context(), bson.M{
+			"$or":   []bson.M{{"buyerId": userID}, {"ownerId": userID}},
+			"start": bson.M{"$gte": time.Now().AddDate(0, 0, -visitFeedPastDays)},
+		}, options.Find().SetSort(bson.D{{Key: "start", Value: 1}}).SetLimit(500))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to load visit calendar feed")
+			http.Error(w, "Failed to build calendar", http.StatusInternalServerError)
+			return
+		}
+		var visits []models.SiteVisit
+		if err = cursor.All(r.Context(), &visits); err != nil {
+			logrus.WithError(err).Error("Failed to decode visit calendar feed")
+			http.Error(w, "Failed to build calendar", http.StatusInternalServerError)
+			return
+		}
+		data, err := visitCalendar(r.Context(), "Site visits", visits)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to build visit calendar feed")
+			http.Error(w, "Failed to build calendar", http.StatusInternalServerError)
+			return
+		}
+		writeCalendar(w, data, "")
+	}
+}
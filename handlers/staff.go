@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/services"
+	"PropertyAppBackend/utils"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StaffLoginRequest is the username/password payload for staff logins
+type StaffLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Hash compared against when the username doesn't exist, so both failure paths take the same time
+var dummyPasswordHash, _ = utils.HashPassword("dummy-password-for-timing")
+
+// rejectThrottledLogin answers 429 while the username or the client IP is locked or delayed
+func rejectThrottledLogin(w http.ResponseWriter, r *http.Request, username, ip string) bool {
+	wait := services.LoginRetryAfter(r.Context(), username, ip)
+	if wait <= 0 {
+		return false
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many failed login attempts, try again later", http.StatusTooManyRequests)
+	return true
+}
+
+// failLogin records a failed attempt, tells the account owner when it caused a lockout
+// and answers with the same error whether the username or the password was wrong
+func failLogin(w http.ResponseWriter, r *http.Request, username, ip string, account *models.User) {
+	if services.RecordLoginFailure(r.Context(), username, ip) && account != nil {
+		cfg := config.GetCachedConfig()
+		message := fmt.Sprintf("Your account %s was locked for %d minutes after repeated failed login attempts. If this wasn't you, contact an administrator.", username, cfg.LoginLockoutMinutes)
+		go func(owner models.User) {
+			if err := services.NotifyUser(owner, message); err != nil {
+				logrus.WithError(err).Warn("Failed to notify account owner about login lockout")
+			}
+		}(*account)
+	}
+	http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+}
+
+// StaffLogin authenticates any staff account (admin, mini-admin, ...) by username and password
+// and issues the same access + refresh token pair as VerifyOTP
+func StaffLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req StaffLoginRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if err != nil || req.Username == "" || req.Password == "" {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+
+		ip := utils.ClientIP(r)
+		if rejectThrottledLogin(w, r, req.Username, ip) {
+			return
+		}
+
+		// **Find Staff Account** (every role except regular app users)
+		var staff models.User
+		err = database.GetUserCollection().FindOne(r.Context(), bson.M{"username": req.Username, "role": bson.M{"$ne": models.RegularUser}}).Decode(&staff)
+		if err != nil || staff.Password == nil {
+			utils.CheckPasswordHash(req.Password, dummyPasswordHash)
+			failLogin(w, r, req.Username, ip, nil)
+			return
+		}
+
+		// **Check Password**
+		if !utils.CheckPasswordHash(req.Password, *staff.Password) {
+			failLogin(w, r, req.Username, ip, &staff)
+			return
+		}
+		services.ResetLoginFailures(r.Context(), req.Username)
+
+		// **Generate Tokens**
+		accessToken, refreshToken, err := issueTokenPair(r.Context(), staff)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to issue staff tokens")
+			http.Error(w, "Failed to issue tokens", http.StatusInternalServerError)
+			return
+		}
+
+		// **Return Response**
+		staff.Password = nil
+		json.NewEncoder(w).Encode(AuthResponse{
+			Message:      "Login successful",
+			AccessToken:  accessToken,
+			RefreshToken: refreshToken,
+			User:         staff,
+			Role:         string(staff.Role),
+			Permissions:  models.PermissionsFor(staff),
+		})
+	}
+}
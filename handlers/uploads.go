@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Image types accepted for uploads, by detected content type
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+// readUpload reads one multipart file field of at most maxBytes and checks its detected content
+// type against allowed (content type -> file extension). It returns the bytes and the extension.
+func readUpload(w http.ResponseWriter, r *http.Request, field string, maxBytes int64, allowed map[string]string) ([]byte, string, error) {
+	data, _, err := readUploadFile(w, r, field, maxBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	ext, ok := allowed[http.DetectContentType(data)]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported file type")
+	}
+	return data, ext, nil
+}
+
+// readUploadFile reads one multipart file field of at most maxBytes. It returns the bytes and the
+// content type the client declared for them, which only says what the client believes they are.
+func readUploadFile(w http.ResponseWriter, r *http.Request, field string, maxBytes int64) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1024*1024) // room for the multipart envelope
+	file, header, err := r.FormFile(field)
+	if err != nil {
+		return nil, "", fmt.Errorf("missing or unreadable %q file", field)
+	}
+	defer file.Close()
+	if header.Size > maxBytes {
+		return nil, "", fmt.Errorf("file is larger than %d MB", maxBytes/(1024*1024))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil || int64(len(data)) > maxBytes {
+		return nil, "", fmt.Errorf("file is larger than %d MB", maxBytes/(1024*1024))
+	}
+	return data, header.Header.Get("Content-Type"), nil
+}
+
+// isStoredFileName reports whether name can only be the name of a file stored in an upload folder
+func isStoredFileName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, "/\\") && !strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/services"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// EstimatePriceRequest describes the property to estimate a price for
+type EstimatePriceRequest struct {
+	LocalityID   string   `json:"localityId"` // from the locality autocomplete, or else city and locality names
+	City         string   `json:"city"`
+	Locality     string   `json:"locality"`
+	ListingType  string   `json:"listingType"` // sale or rent
+	PropertyType string   `json:"propertyType"`
+	AreaSqft     float64  `json:"areaSqft"`
+	Bedrooms     int      `json:"bedrooms"`
+	Floor        *int     `json:"floor"`
+	Latitude     *float64 `json:"latitude"` // optional, weighs comparables by distance
+	Longitude    *float64 `json:"longitude"`
+}
+
+// EstimatePrice estimates the sale price or monthly rent of a property from comparable listings
+// nearby, as a range with a confidence and the listings it is based on
+func EstimatePrice() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req EstimatePriceRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		if req.ListingType != "sale" && req.ListingType != "rent" {
+			http.Error(w, "listingType must be sale or rent", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(req.PropertyType) == "" {
+			http.Error(w, "propertyType is required", http.StatusBadRequest)
+			return
+		}
+		if req.AreaSqft <= 0 || req.Bedrooms < 0 {
+			http.Error(w, "areaSqft must be positive and bedrooms can't be negative", http.StatusBadRequest)
+			return
+		}
+		pin, err := (CreateListingRequest{Latitude: req.Latitude, Longitude: req.Longitude}).pin()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		location, ok := resolveLocation(w, r, req.LocalityID, req.City, req.Locality)
+		if !ok {
+			return
+		}
+
+		subject := property.Property{
+			ListingType:  req.ListingType,
+			PropertyType: strings.TrimSpace(req.PropertyType),
+			AreaSqft:     req.AreaSqft,
+			Bedrooms:     req.Bedrooms,
+			Floor:        req.Floor,
+			Location:     pin,
+		}
+		subject.CityID, subject.LocalityID = location.IDs()
+		subject.City, subject.Locality = location.Names()
+
+		valuation, err := services.EstimatePrice(r.Context(), subject)
+		if errors.Is(err, services.ErrNoValuation) {
+			http.Error(w, "Not enough listings nearby to estimate a price yet", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logrus.WithError(err).Error("Failed to estimate price")
+			http.Error(w, "Failed to estimate price", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"city":      subject.City,
+			"locality":  subject.Locality,
+			"valuation": valuation,
+		})
+	}
+}
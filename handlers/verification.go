@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/services"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	maxVerificationDocumentBytes = 10 * 1024 * 1024
+	maxVerificationDocuments     = 5
+)
+
+// Verification documents may be images or PDFs
+var documentExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+// ReviewVerificationRequest is a staff decision on a professional profile
+type ReviewVerificationRequest struct {
+	Action string `json:"action"` // approve or reject
+	Reason string `json:"reason,omitempty"`
+}
+
+// loadProfessional loads the authenticated user and checks they are an agent or builder
+func loadProfessional(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	userID := r.Context().Value(middleware.UserIDKey).(primitive.ObjectID)
+	var user models.User
+	err := database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID, "role": models.RegularUser}).Decode(&user)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return user, false
+	}
+	if !models.IsProfessional(user.UserType) {
+		http.Error(w, "Only agents and builders have a professional profile", http.StatusForbidden)
+		return user, false
+	}
+	return user, true
+}
+
+// UpdateProfessionalProfile sets the agency name, RERA number and service areas of an agent or builder.
+// Changing a verified profile sends it back for review.
+func UpdateProfessionalProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := loadProfessional(w, r)
+		if !ok {
+			return
+		}
+
+		var req models.ProfessionalProfile
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		req.AgencyName = strings.TrimSpace(req.AgencyName)
+		req.RERANumber = strings.ToUpper(strings.TrimSpace(req.RERANumber))
+		if req.AgencyName == "" || req.RERANumber == "" {
+			http.Error(w, "Agency name and RERA registration number are required", http.StatusBadRequest)
+			return
+		}
+
+		update := bson.M{"professional": req, "updatedAt": time.Now()}
+		if user.IsVerified() {
+			update["verification.status"] = models.VerificationPending
+			update["verification.submittedAt"] = time.Now()
+		}
+		_, err := database.GetUserCollection().UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{"$set": update})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to update professional profile")
+			http.Error(w, "Failed to update professional profile", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":      "Professional profile updated",
+			"professional": req,
+		})
+	}
+}
+
+// UploadVerificationDocument adds a "document" file (image or PDF) of the given "kind" to the user's verification
+func UploadVerificationDocument() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := loadProfessional(w, r)
+		if !ok {
+			return
+		}
+		if user.Verification != nil && len(user.Verification.Documents) >= maxVerificationDocuments {
+			http.Error(w, fmt.Sprintf("At most %d documents can be uploaded", maxVerificationDocuments), http.StatusConflict)
+			return
+		}
+
+		data, ext, err := readUpload(w, r, "document", maxVerificationDocumentBytes, documentExtensions)
+		if err != nil {
+			http.Error(w, "Invalid document: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		kind := strings.TrimSpace(r.FormValue("kind"))
+		if kind == "" {
+			http.Error(w, "Document kind is required", http.StatusBadRequest)
+			return
+		}
+		path, err := services.SavePrivateFile("verification", data, ext)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to store verification document")
+			http.Error(w, "Failed to store document", http.StatusInternalServerError)
+			return
+		}
+
+		document := models.VerificationDocument{Kind: kind, Path: path, UploadedAt: time.Now()}
+		_, err = database.GetUserCollection().UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{"$push": bson.M{"verification.documents": document}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to save verification document")
+			http.Error(w, "Failed to save document", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message":  "Document uploaded",
+			"document": document,
+		})
+	}
+}
+
+// SubmitVerification sends the user's professional profile and documents for staff review
+func SubmitVerification() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := loadProfessional(w, r)
+		if !ok {
+			return
+		}
+		if user.Professional == nil {
+			http.Error(w, "Fill in the professional profile first", http.StatusBadRequest)
+			return
+		}
+		if user.Verification == nil || len(user.Verification.Documents) == 0 {
+			http.Error(w, "Upload at least one document first", http.StatusBadRequest)
+			return
+		}
+		if user.Verification.Status == models.VerificationPending || user.Verification.Status == models.VerificationVerified {
+			http.Error(w, "Verification is already "+user.Verification.Status, http.StatusConflict)
+			return
+		}
+
+		_, err := database.GetUserCollection().UpdateOne(r.Context(), bson.M{"_id": user.ID}, bson.M{
+			"$set":   bson.M{"verification.status": models.VerificationPending, "verification.submittedAt": time.Now()},
+			"$unset": bson.M{"verification.rejectionReason": ""},
+		})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to submit verification")
+			http.Error(w, "Failed to submit verification", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Verification submitted for review",
+			"status":  models.VerificationPending,
+		})
+	}
+}
+
+// ListPendingVerifications returns professionals in the caller's scope waiting for review, oldest first
+func ListPendingVerifications() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+
+		filter := models.ScopeFilter(staff, "city", "professional.serviceAreas")
+		filter["verification.status"] = models.VerificationPending
+		cursor, err := database.GetUserCollection().Find(r.Context(), filter, options.Find().
+			SetSort(bson.D{{Key: "verification.submittedAt", Value: 1}}).
+			SetProjection(bson.M{"password": 0}).
+			SetLimit(100))
+		if err != nil {
+			logrus.WithError(err).Error("Failed to list pending verifications")
+			http.Error(w, "Failed to list verifications", http.StatusInternalServerError)
+			return
+		}
+		users := []models.User{}
+		if err = cursor.All(r.Context(), &users); err != nil {
+			logrus.WithError(err).Error("Failed to decode pending verifications")
+			http.Error(w, "Failed to list verifications", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"users": users,
+		})
+	}
+}
+
+// loadScopedProfessional loads the professional named by the {id} path variable if it is in the caller's scope
+func loadScopedProfessional(w http.ResponseWriter, r *http.Request) (models.User, bool) {
+	staff := r.Context().Value(middleware.StaffKey).(models.User)
+	var user models.User
+	userID, err := primitive.ObjectIDFromHex(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return user, false
+	}
+	filter := models.ScopeFilter(staff, "city", "professional.serviceAreas")
+	filter["_id"] = userID
+	filter["verification"] = bson.M{"$exists": true}
+	if err = database.GetUserCollection().FindOne(r.Context(), filter).Decode(&user); err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return user, false
+	}
+	return user, true
+}
+
+// DownloadVerificationDocument serves one verification document to reviewing staff
+func DownloadVerificationDocument() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := loadScopedProfessional(w, r)
+		if !ok {
+			return
+		}
+		index, err := strconv.Atoi(mux.Vars(r)["index"])
+		if err != nil || index < 0 || index >= len(user.Verification.Documents) {
+			http.Error(w, "Document not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.ServeFile(w, r, services.PrivateFilePath(user.Verification.Documents[index].Path))
+	}
+}
+
+// ReviewVerification approves or rejects a pending professional profile and tells the user
+func ReviewVerification() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		staff := r.Context().Value(middleware.StaffKey).(models.User)
+		user, ok := loadScopedProfessional(w, r)
+		if !ok {
+			return
+		}
+
+		var req ReviewVerificationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request payload", http.StatusBadRequest)
+			return
+		}
+		var status, message string
+		switch req.Action {
+		case "approve":
+			status = models.VerificationVerified
+			message = "Your professional profile is verified. Your listings now show a verified badge."
+		case "reject":
+			if req.Reason == "" {
+				http.Error(w, "A reason is required to reject a verification", http.StatusBadRequest)
+				return
+			}
+			status = models.VerificationRejected
+			message = "Your professional profile verification was rejected: " + req.Reason
+		default:
+			http.Error(w, "Action must be approve or reject", http.StatusBadRequest)
+			return
+		}
+
+		result, err := database.GetUserCollection().UpdateOne(r.Context(),
+			bson.M{"_id": user.ID, "verification.status": models.VerificationPending},
+			bson.M{"$set": bson.M{
+				"verification.status":          status,
+				"verification.reviewedBy":      staff.ID,
+				"verification.reviewedAt":      time.Now(),
+				"verification.rejectionReason": req.Reason,
+			}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to review verification")
+			http.Error(w, "Failed to review verification", http.StatusInternalServerError)
+			return
+		}
+		if result.ModifiedCount == 0 {
+			http.Error(w, "Verification is not pending review", http.StatusConflict)
+			return
+		}
+
+		if err = services.NotifyUser(user, message); err != nil {
+			logrus.WithError(err).Warn("Failed to notify user about verification review")
+		}
+		logrus.Info("Verification ", status, " for user ", user.ID.Hex(), " by ", staff.ID.Hex())
+		json.NewEncoder(w).Encode(map[string]string{
+			"message": "Verification " + status,
+			"status":  status,
+		})
+	}
+}
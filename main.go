@@ -5,11 +5,16 @@ import (
 	database "PropertyAppBackend/db"
 	"PropertyAppBackend/handlers"
 	"PropertyAppBackend/middleware"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/services"
 	"PropertyAppBackend/utils"
 	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
+	"time"
+	_ "time/tzdata" // visit availability time zones must resolve without system zoneinfo
 
 	"github.com/gorilla/mux"
 	"go.mongodb.org/mongo-driver/bson"
@@ -24,6 +29,9 @@ func main() {
 	config.LoadConfig()
 	// Load configuration
 	cfg := config.GetCachedConfig()
+	if err := utils.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("TRUSTED_PROXIES: %v", err)
+	}
 	// Connect to MongoDB once at startup
 	client, err := database.ConnectDB(cfg.MongoDBURI)
 	if err != nil {
@@ -55,27 +63,392 @@ indexes := []mongo.IndexModel{
 		fmt.Println("Unique indexes ensured: phoneNumber (Users) & username (Admins)")
 	}
 
+	// Failed login counters, expired a day after the last failure
+	loginAttemptIndexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "lastFailure", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(24 * 60 * 60)},
+	}
+	_, err = database.GetLoginAttemptCollection().Indexes().CreateMany(database.Ctx, loginAttemptIndexes)
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for login_attempts collection: %v", err)
+	}
+
+	_, err = database.GetAuditLogCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "actorId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "subjectId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for audit_logs collection: %v", err)
+	}
+
+	_, err = database.GetPropertyCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "city", Value: 1}, {Key: "publishedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "projectId", Value: 1}, {Key: "status", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "cityId", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "localityId", Value: 1}, {Key: "status", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "amenityIds", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "updatedAt", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "bumpedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "duplicateGroupId", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "updatedAt", Value: 1}}},
+		// Blocks of listings compared for duplicates
+		{Keys: bson.D{{Key: "localityId", Value: 1}, {Key: "listingType", Value: 1}, {Key: "propertyType", Value: 1}, {Key: "bedrooms", Value: 1}}},
+		{Keys: bson.D{{Key: "cityId", Value: 1}, {Key: "listingType", Value: 1}, {Key: "propertyType", Value: 1}, {Key: "bedrooms", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for properties collection: %v", err)
+	}
+
+	// The one text index of the collection, behind the free-text listing search
+	_, err = database.GetPropertyCollection().Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}, {Key: "locality", Value: "text"}, {Key: "city", Value: "text"}, {Key: "amenityLabels", Value: "text"}},
+		Options: options.Index().SetName("listing_text").
+			SetWeights(bson.M{"title": 10, "locality": 8, "city": 5, "amenityLabels": 5, "description": 1}),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create text index for properties collection: %v", err)
+	}
+
+	_, err = database.GetFavoriteCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "propertyId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "propertyId", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for favorites collection: %v", err)
+	}
+
+	_, err = database.GetSavedSearchCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}}},
+		{Keys: bson.D{{Key: "alertsEnabled", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for saved_searches collection: %v", err)
+	}
+	_, err = database.GetNotificationCollection().Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for notifications collection: %v", err)
+	}
+
+	_, err = database.GetLeadCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "propertyId", Value: 1}, {Key: "buyerId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "updatedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "buyerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for leads collection: %v", err)
+	}
+
+	_, err = database.GetVisitAvailabilityCollection().Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "propertyId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for visit_availability collection: %v", err)
+	}
+	// slotKey is only set while a visit holds its slot, so the sparse unique index rejects double bookings
+	_, err = database.GetSiteVisitCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "slotKey", Value: 1}}, Options: options.Index().SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "buyerId", Value: 1}, {Key: "start", Value: 1}}},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "start", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "start", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for site_visits collection: %v", err)
+	}
+
+	_, err = database.GetConversationCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "propertyId", Value: 1}, {Key: "buyerId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "buyerId", Value: 1}, {Key: "lastMessageAt", Value: -1}}},
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "lastMessageAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for conversations collection: %v", err)
+	}
+	_, err = database.GetMessageCollection().Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "conversationId", Value: 1}, {Key: "createdAt", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for messages collection: %v", err)
+	}
+	_, err = database.GetUserBlockCollection().Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "blockerId", Value: 1}, {Key: "blockedId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for user_blocks collection: %v", err)
+	}
+
+	// Expired reveals are removed, which also frees their proxy codes for reuse
+	_, err = database.GetContactRevealCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "proxyId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "buyerId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for contact_reveals collection: %v", err)
+	}
+
+	// Reveal counters only matter for a day's limit
+	_, err = database.GetContactRevealCounterCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "buyerId", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(2 * 24 * 60 * 60)},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for contact_reveal_counters collection: %v", err)
+	}
+
+	// A user has at most one open report per listing
+	_, err = database.GetListingReportCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "propertyId", Value: 1}, {Key: "reporterId", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": models.ReportOpen}),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "city", Value: 1}}},
+		{Keys: bson.D{{Key: "reporterId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for listing_reports collection: %v", err)
+	}
+
+	_, err = database.GetProjectCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "reraId", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "builderId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for projects collection: %v", err)
+	}
+
+	// A place name is unique among its parent's children
+	_, err = database.GetLocalityCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "level", Value: 1}, {Key: "parentId", Value: 1}, {Key: "nameKey", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "searchKeys", Value: 1}, {Key: "listingCount", Value: -1}}},
+		{Keys: bson.D{{Key: "ancestorIds", Value: 1}}},
+		{Keys: bson.D{{Key: "parentId", Value: 1}, {Key: "name", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for localities collection: %v", err)
+	}
+
+	// Amenity labels are unique regardless of case
+	_, err = database.GetAmenityCollection().Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "label", Value: 1}},
+		Options: options.Index().SetUnique(true).SetCollation(&options.Collation{Locale: "en", Strength: 2}),
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for amenities collection: %v", err)
+	}
+
+	_, err = database.GetListingVersionCollection().Indexes().CreateOne(database.Ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "propertyId", Value: 1}, {Key: "createdAt", Value: 1}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for listing_versions collection: %v", err)
+	}
+
+	// Refreshes only matter for a day's limit
+	_, err = database.GetListingRefreshCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "createdAt", Value: 1}}},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(2 * 24 * 60 * 60)},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for listing_refreshes collection: %v", err)
+	}
+
+	_, err = database.GetListingRefreshCounterCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "ownerId", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "createdAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(2 * 24 * 60 * 60)},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for listing_refresh_counters collection: %v", err)
+	}
+
+	_, err = database.GetDuplicateGroupCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "placeId", Value: 1}, {Key: "listingType", Value: 1}, {Key: "propertyType", Value: 1}}},
+		{Keys: bson.D{{Key: "propertyIds", Value: 1}, {Key: "status", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "score", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for duplicate_groups collection: %v", err)
+	}
+
+	_, err = database.GetPriceStatsCollection().Indexes().CreateMany(database.Ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "placeId", Value: 1}, {Key: "listingType", Value: 1}, {Key: "propertyType", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "updatedAt", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Warning: Failed to create indexes for price_stats collection: %v", err)
+	}
+
+	// Background jobs
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	services.RunPeriodically(jobsCtx, "purge deleted accounts", time.Hour, services.PurgeDeletedAccounts)
+	services.RunPeriodically(jobsCtx, "purge expired data exports", time.Hour, services.PurgeExpiredExports)
+	services.RunPeriodically(jobsCtx, "saved search alerts", 5*time.Minute, services.SendSavedSearchAlerts)
+	services.RunPeriodically(jobsCtx, "site visit reminders", 5*time.Minute, services.SendVisitReminders)
+	services.RunPeriodically(jobsCtx, "archive let rentals", time.Hour, services.ArchiveLetRentals)
+	services.RunPeriodically(jobsCtx, "refresh project stats", time.Hour, services.RefreshAllProjectStats)
+	services.RunPeriodically(jobsCtx, "refresh locality stats", time.Hour, services.RefreshLocalityStats)
+	services.RunPeriodically(jobsCtx, "purge stale drafts", time.Hour, services.PurgeStaleDrafts)
+	services.RunPeriodically(jobsCtx, "expire listings", time.Hour, services.ExpireListings)
+	services.RunPeriodically(jobsCtx, "detect duplicate listings", time.Hour, services.DetectDuplicates)
+	services.RunPeriodically(jobsCtx, "aggregate price statistics", 24*time.Hour, services.AggregatePriceStats)
+	services.RunPeriodically(jobsCtx, "score listing quality", time.Hour, services.ScoreListings)
+
 	r := mux.NewRouter()
 
 	// Authentication routes - Pass the MongoDB client to handlers
 	r.HandleFunc("/send-otp", handlers.SendOTP()).Methods("POST")
 	r.HandleFunc("/verify-otp", handlers.VerifyOTP()).Methods("POST")
+	r.HandleFunc("/refresh-token", handlers.RefreshAccessToken()).Methods("POST")
+	r.HandleFunc("/saved-searches/unsubscribe", handlers.UnsubscribeAlertsByLink()).Methods("GET")
+	r.HandleFunc("/calendar/visits.ics", handlers.VisitCalendarFeed()).Methods("GET")
+	r.HandleFunc("/contact-proxy/resolve", handlers.ResolveContactProxy()).Methods("POST")
 
 
 // **Admin Creates Mini-Admin**
-r.HandleFunc("/admin/create-mini-admin", handlers.CreateMiniAdmin()).Methods("POST")
+r.Handle("/admin/create-mini-admin", middleware.AuthMiddleware(middleware.RequirePermission(models.ManageStaff)(handlers.CreateMiniAdmin()))).Methods("POST")
 
-// **Admin Login**
-r.HandleFunc("/admin/login", handlers.AdminLogin()).Methods("POST")
-r.HandleFunc("/mini-admin/login", handlers.MiniAdminLogin()).Methods("POST")
+// **Staff Login** (admin and mini-admin paths kept for existing clients)
+r.HandleFunc("/staff/login", handlers.StaffLogin()).Methods("POST")
+r.HandleFunc("/admin/login", handlers.StaffLogin()).Methods("POST")
+r.HandleFunc("/mini-admin/login", handlers.StaffLogin()).Methods("POST")
 
 
 
+	// Uploaded files (avatars, ...) are public by their random names; directory listings are not served
+	uploads := http.StripPrefix("/uploads/", http.FileServer(http.Dir(cfg.UploadDir)))
+	r.PathPrefix("/uploads/").Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasSuffix(req.URL.Path, "/") {
+			http.NotFound(w, req)
+			return
+		}
+		uploads.ServeHTTP(w, req)
+	})).Methods("GET")
+
+	// Real-time chat stream; registered outside /api's middleware so EventSource clients can pass the token in the query
+	r.Handle("/api/messages/stream", middleware.StreamAuthMiddleware(handlers.ChatStream())).Methods("GET")
+
 	// Protected routes (require authentication via JWT)
 	protectedRouter := r.PathPrefix("/api").Subrouter()
 	// Pass client to middleware if middleware needs DB access, else no change
 	protectedRouter.Use(middleware.AuthMiddleware) // No client needed for basic AuthMiddleware
-	// protectedRouter.HandleFunc("/profile", handlers.GetUserProfile(client)).Methods("GET")
+	protectedRouter.HandleFunc("/me", handlers.GetProfile()).Methods("GET")
+	protectedRouter.Handle("/me", middleware.DenyImpersonation(handlers.UpdateProfile())).Methods("PATCH")
+	protectedRouter.Handle("/me/avatar", middleware.DenyImpersonation(handlers.UploadAvatar())).Methods("POST")
+	protectedRouter.Handle("/me/phone", middleware.DenyImpersonation(handlers.RequestPhoneChange())).Methods("POST")
+	protectedRouter.Handle("/me/phone/verify", middleware.DenyImpersonation(handlers.VerifyPhoneChange())).Methods("POST")
+	protectedRouter.Handle("/me/exports", middleware.DenyImpersonation(handlers.RequestDataExport())).Methods("POST")
+	protectedRouter.HandleFunc("/me/exports", handlers.ListDataExports()).Methods("GET")
+	protectedRouter.Handle("/me/exports/{id}/download", middleware.DenyImpersonation(handlers.DownloadDataExport())).Methods("GET")
+	protectedRouter.Handle("/me/deletion", middleware.DenyImpersonation(handlers.RequestAccountDeletion())).Methods("POST")
+	protectedRouter.Handle("/me/deletion", middleware.DenyImpersonation(handlers.CancelAccountDeletion())).Methods("DELETE")
+	protectedRouter.Handle("/me/professional-profile", middleware.DenyImpersonation(handlers.UpdateProfessionalProfile())).Methods("PUT")
+	protectedRouter.Handle("/me/verification/documents", middleware.DenyImpersonation(handlers.UploadVerificationDocument())).Methods("POST")
+	protectedRouter.Handle("/me/verification/submit", middleware.DenyImpersonation(handlers.SubmitVerification())).Methods("POST")
+
+	// Listings
+	protectedRouter.HandleFunc("/listings", handlers.SearchListings()).Methods("GET")
+	protectedRouter.Handle("/listings", middleware.DenyImpersonation(handlers.CreateListing())).Methods("POST")
+	protectedRouter.HandleFunc("/listings/mine", handlers.ListMyListings()).Methods("GET")
+	protectedRouter.Handle("/listings/photos", middleware.DenyImpersonation(handlers.UploadListingPhoto())).Methods("POST")
+	protectedRouter.Handle("/listings/drafts", middleware.DenyImpersonation(handlers.CreateDraft())).Methods("POST")
+	protectedRouter.HandleFunc("/listings/drafts", handlers.ListMyDrafts()).Methods("GET")
+	protectedRouter.Handle("/listings/{id}/steps/{step}", middleware.DenyImpersonation(handlers.SaveDraftStep())).Methods("PUT")
+	protectedRouter.Handle("/listings/{id}/submit", middleware.DenyImpersonation(handlers.SubmitDraft())).Methods("POST")
+	protectedRouter.Handle("/listings/{id}/renew", middleware.DenyImpersonation(handlers.RenewListing())).Methods("POST")
+	protectedRouter.Handle("/listings/{id}/refresh", middleware.DenyImpersonation(handlers.RefreshListing())).Methods("POST")
+	protectedRouter.HandleFunc("/listings/{id}", handlers.GetListing()).Methods("GET")
+	protectedRouter.Handle("/listings/{id}", middleware.DenyImpersonation(handlers.UpdateListing())).Methods("PATCH")
+	protectedRouter.Handle("/listings/{id}/status", middleware.DenyImpersonation(handlers.UpdateListingStatus())).Methods("PATCH")
+	protectedRouter.HandleFunc("/amenities", handlers.ListAmenities()).Methods("GET")
+	protectedRouter.HandleFunc("/localities/autocomplete", handlers.AutocompleteLocalities()).Methods("GET")
+	protectedRouter.HandleFunc("/localities/{id}/prices", handlers.GetLocalityPrices()).Methods("GET")
+	protectedRouter.Handle("/valuations/estimate", middleware.DenyImpersonation(handlers.EstimatePrice())).Methods("POST")
+	protectedRouter.Handle("/projects", middleware.DenyImpersonation(handlers.CreateProject())).Methods("POST")
+	protectedRouter.HandleFunc("/projects/mine", handlers.ListMyProjects()).Methods("GET")
+	protectedRouter.HandleFunc("/projects/{id}", handlers.GetProject()).Methods("GET")
+	protectedRouter.Handle("/projects/{id}", middleware.DenyImpersonation(handlers.UpdateProject())).Methods("PUT")
+	protectedRouter.Handle("/listings/{id}/reports", middleware.DenyImpersonation(handlers.ReportListing())).Methods("POST")
+	protectedRouter.HandleFunc("/reports/listings", handlers.ListMyListingReports()).Methods("GET")
+
+	// Favorites
+	protectedRouter.HandleFunc("/favorites", handlers.ListFavorites()).Methods("GET")
+	protectedRouter.Handle("/favorites/{id}", middleware.DenyImpersonation(handlers.AddFavorite())).Methods("PUT")
+	protectedRouter.Handle("/favorites/{id}", middleware.DenyImpersonation(handlers.RemoveFavorite())).Methods("DELETE")
+
+	// Inquiries and leads
+	protectedRouter.Handle("/listings/{id}/inquiries", middleware.DenyImpersonation(handlers.CreateInquiry())).Methods("POST")
+	protectedRouter.Handle("/listings/{id}/contact", middleware.DenyImpersonation(handlers.RevealContact())).Methods("POST")
+	protectedRouter.HandleFunc("/inquiries", handlers.ListMyInquiries()).Methods("GET")
+	protectedRouter.HandleFunc("/leads", handlers.ListLeads()).Methods("GET")
+	protectedRouter.Handle("/leads/{id}", middleware.DenyImpersonation(handlers.UpdateLead())).Methods("PATCH")
+
+	// Site visits
+	protectedRouter.Handle("/listings/{id}/availability", middleware.DenyImpersonation(handlers.SetVisitAvailability())).Methods("PUT")
+	protectedRouter.HandleFunc("/listings/{id}/availability", handlers.GetVisitSlots()).Methods("GET")
+	protectedRouter.Handle("/listings/{id}/visits", middleware.DenyImpersonation(handlers.BookSiteVisit())).Methods("POST")
+	protectedRouter.HandleFunc("/visits", handlers.ListSiteVisits()).Methods("GET")
+	protectedRouter.HandleFunc("/visits/calendar-feed", handlers.GetVisitCalendarFeed()).Methods("GET")
+	protectedRouter.Handle("/visits/{id}/confirm", middleware.DenyImpersonation(handlers.ConfirmSiteVisit())).Methods("POST")
+	protectedRouter.Handle("/visits/{id}/reschedule", middleware.DenyImpersonation(handlers.RescheduleSiteVisit())).Methods("POST")
+	protectedRouter.Handle("/visits/{id}/cancel", middleware.DenyImpersonation(handlers.CancelSiteVisit())).Methods("POST")
+	protectedRouter.HandleFunc("/visits/{id}/calendar.ics", handlers.DownloadVisitCalendar()).Methods("GET")
+
+	// Messaging
+	protectedRouter.Handle("/listings/{id}/conversations", middleware.DenyImpersonation(handlers.StartConversation())).Methods("POST")
+	protectedRouter.HandleFunc("/conversations", handlers.ListConversations()).Methods("GET")
+	protectedRouter.HandleFunc("/conversations/{id}/messages", handlers.ListMessages()).Methods("GET")
+	protectedRouter.Handle("/conversations/{id}/messages", middleware.DenyImpersonation(handlers.SendMessage())).Methods("POST")
+	protectedRouter.Handle("/conversations/{id}/attachments", middleware.DenyImpersonation(handlers.UploadMessageImage())).Methods("POST")
+	protectedRouter.HandleFunc("/conversations/{id}/images/{sender}/{name}", handlers.DownloadMessageImage()).Methods("GET")
+	protectedRouter.Handle("/conversations/{id}/read", middleware.DenyImpersonation(handlers.MarkConversationRead())).Methods("POST")
+	protectedRouter.Handle("/conversations/{id}/block", middleware.DenyImpersonation(handlers.BlockConversationUser())).Methods("POST")
+	protectedRouter.Handle("/conversations/{id}/block", middleware.DenyImpersonation(handlers.UnblockConversationUser())).Methods("DELETE")
+	protectedRouter.Handle("/conversations/{id}/report", middleware.DenyImpersonation(handlers.ReportConversation())).Methods("POST")
+	protectedRouter.HandleFunc("/messages/updates", handlers.PollMessages()).Methods("GET")
+
+	// Saved searches and notifications
+	protectedRouter.HandleFunc("/saved-searches", handlers.ListSavedSearches()).Methods("GET")
+	protectedRouter.Handle("/saved-searches", middleware.DenyImpersonation(handlers.CreateSavedSearch())).Methods("POST")
+	protectedRouter.Handle("/saved-searches/alerts", middleware.DenyImpersonation(handlers.SetAlertSubscription())).Methods("PUT")
+	protectedRouter.Handle("/saved-searches/{id}", middleware.DenyImpersonation(handlers.UpdateSavedSearch())).Methods("PATCH")
+	protectedRouter.Handle("/saved-searches/{id}", middleware.DenyImpersonation(handlers.DeleteSavedSearch())).Methods("DELETE")
+	protectedRouter.HandleFunc("/notifications", handlers.ListNotifications()).Methods("GET")
+	protectedRouter.Handle("/notifications/read", middleware.DenyImpersonation(handlers.MarkNotificationsRead())).Methods("POST")
+
+	// Staff routes, each gated by the permission it needs; results are filtered to the caller's scope
+	adminRouter := protectedRouter.PathPrefix("/admin").Subrouter()
+	adminRouter.Handle("/mini-admins", middleware.RequirePermission(models.ManageStaff)(handlers.ListMiniAdmins())).Methods("GET")
+	adminRouter.Handle("/mini-admins/{id}", middleware.RequirePermission(models.ManageStaff)(handlers.UpdateMiniAdminAccess())).Methods("PATCH")
+	adminRouter.Handle("/listings", middleware.RequirePermission(models.ApproveListings)(handlers.ListListingsForReview())).Methods("GET")
+	adminRouter.Handle("/listings/{id}/review", middleware.RequirePermission(models.ApproveListings)(handlers.ReviewListing())).Methods("POST")
+	adminRouter.Handle("/listings/{id}/history", middleware.RequirePermission(models.ApproveListings)(handlers.GetListingHistory())).Methods("GET")
+	adminRouter.Handle("/duplicates", middleware.RequirePermission(models.ApproveListings)(handlers.ListDuplicateGroups())).Methods("GET")
+	adminRouter.Handle("/duplicates/{id}/resolve", middleware.RequirePermission(models.ApproveListings)(handlers.ResolveDuplicateGroup())).Methods("POST")
+	adminRouter.Handle("/listing-reports", middleware.RequirePermission(models.ApproveListings)(handlers.ListReportedListings())).Methods("GET")
+	adminRouter.Handle("/listing-reports/{id}", middleware.RequirePermission(models.ApproveListings)(handlers.GetListingReports())).Methods("GET")
+	adminRouter.Handle("/listing-reports/{id}/resolve", middleware.RequirePermission(models.ApproveListings)(handlers.ResolveListingReports())).Methods("POST")
+	adminRouter.Handle("/localities", middleware.RequirePermission(models.ManageLocalities)(handlers.ListLocalities())).Methods("GET")
+	adminRouter.Handle("/localities", middleware.RequirePermission(models.ManageLocalities)(handlers.CreateLocality())).Methods("POST")
+	adminRouter.Handle("/localities/import", middleware.RequirePermission(models.ManageLocalities)(handlers.ImportLocalities())).Methods("POST")
+	adminRouter.Handle("/localities/{id}", middleware.RequirePermission(models.ManageLocalities)(handlers.UpdateLocality())).Methods("PATCH")
+	adminRouter.Handle("/localities/{id}", middleware.RequirePermission(models.ManageLocalities)(handlers.DeleteLocality())).Methods("DELETE")
+	adminRouter.Handle("/amenities", middleware.RequirePermission(models.ManageAmenities)(handlers.CreateAmenity())).Methods("POST")
+	adminRouter.Handle("/amenities/{id}", middleware.RequirePermission(models.ManageAmenities)(handlers.UpdateAmenity())).Methods("PATCH")
+	adminRouter.Handle("/amenities/{id}", middleware.RequirePermission(models.ManageAmenities)(handlers.DeleteAmenity())).Methods("DELETE")
+	adminRouter.Handle("/impersonate/{id}", middleware.RequireRole(models.Admin)(handlers.ImpersonateUser())).Methods("POST")
+	adminRouter.Handle("/leads", middleware.RequirePermission(models.ViewLeads)(handlers.ListLeadsForStaff())).Methods("GET")
+	adminRouter.Handle("/exports/listings", middleware.RequirePermission(models.ExportData)(handlers.ExportListings())).Methods("GET")
+	adminRouter.Handle("/message-reports", middleware.RequirePermission(models.ManageUsers)(handlers.ListMessageReports())).Methods("GET")
+	adminRouter.Handle("/message-reports/{id}", middleware.RequirePermission(models.ManageUsers)(handlers.ResolveMessageReport())).Methods("PATCH")
+	adminRouter.Handle("/verifications", middleware.RequirePermission(models.VerifyUsers)(handlers.ListPendingVerifications())).Methods("GET")
+	adminRouter.Handle("/verifications/{id}/documents/{index}", middleware.RequirePermission(models.VerifyUsers)(handlers.DownloadVerificationDocument())).Methods("GET")
+	adminRouter.Handle("/verifications/{id}/review", middleware.RequirePermission(models.VerifyUsers)(handlers.ReviewVerification())).Methods("POST")
 
 	fmt.Printf("Server listening on %s\n", cfg.Port)
 	log.Fatal(http.ListenAndServe(cfg.Port, r))
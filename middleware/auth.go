@@ -2,16 +2,24 @@ package middleware
 
 import (
 	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/services"
 	"PropertyAppBackend/utils"
 	"context"
 	"net/http"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 //Define a constant for the context key
 const UserIDKey = "userID"
+const RoleKey = "role"
+const StaffKey = "staff"
+const ActorIDKey = "actorID" // set only for impersonation tokens, holds the acting admin's ID
 
 
 func AuthMiddleware(next http.Handler) http.Handler {
@@ -33,15 +41,93 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		tokenString := parts[1]
 		cfg := config.GetCachedConfig()
 		// Use ParseJWT to get ObjectID, but for access token, we don't need claims back here usually.
-		userID, _, err := utils.ParseJWT(tokenString, cfg.JWTSecret) // ParseJWT now returns ObjectID and claims
+		userID, claims, err := utils.ParseJWT(tokenString, cfg.JWTSecret) // ParseJWT now returns ObjectID and claims
 		if err != nil {
 			logrus.Warn("Invalid or expired token")
 			http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
 			return
 		}
+		role, _ := claims["role"].(string)
 
-		// Add user ID (as ObjectID) to request context
+		// Add user ID (as ObjectID) and role to request context
 		ctx := context.WithValue(r.Context(), UserIDKey, userID) // Store ObjectID directly
+		ctx = context.WithValue(ctx, RoleKey, models.Role(role))
+
+		// Impersonation tokens: remember the acting admin and audit every request made with them
+		if actorID, ok := utils.ActorFromClaims(claims); ok {
+			ctx = context.WithValue(ctx, ActorIDKey, actorID)
+			w.Header().Set("X-Impersonated-By", actorID.Hex())
+			services.RecordAudit(r.Context(), models.AuditLog{
+				Action:    models.AuditImpersonationRequest,
+				ActorID:   actorID,
+				SubjectID: userID,
+				IP:        utils.ClientIP(r),
+				Details:   map[string]interface{}{"method": r.Method, "path": r.URL.Path},
+			})
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
-}
\ No newline at end of file
+}
+
+// DenyImpersonation rejects requests made with an impersonation token, for destructive
+// actions support staff must never perform on a user's behalf. It must run after AuthMiddleware.
+func DenyImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actorID, ok := r.Context().Value(ActorIDKey).(primitive.ObjectID); ok {
+			logrus.Warn("Blocked impersonated request to ", r.URL.Path, " by ", actorID.Hex())
+			http.Error(w, "Forbidden: not allowed while impersonating", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequireRole only lets requests through whose token carries one of the given roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...models.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value(RoleKey).(models.Role)
+			for _, allowed := range roles {
+				if role == allowed {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			logrus.Warn("Role not allowed: ", role)
+			http.Error(w, "Forbidden: insufficient role", http.StatusForbidden)
+		})
+	}
+}
+
+// RequirePermission loads the caller's staff account and only lets the request through if it
+// holds the permission. The account is stored in the context under StaffKey so handlers can
+// apply its scope. It must run after AuthMiddleware.
+func RequirePermission(permission models.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, _ := r.Context().Value(UserIDKey).(primitive.ObjectID)
+			var staff models.User
+			err := database.GetUserCollection().FindOne(r.Context(), bson.M{"_id": userID, "role": bson.M{"$ne": models.RegularUser}}).Decode(&staff)
+			if err != nil || !models.HasPermission(staff, permission) {
+				logrus.Warn("Permission denied: ", permission, " for ", userID.Hex())
+				http.Error(w, "Forbidden: missing permission "+string(permission), http.StatusForbidden)
+				return
+			}
+			ctx := context.WithValue(r.Context(), StaffKey, staff)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// StreamAuthMiddleware is AuthMiddleware for real-time streams opened by browsers' EventSource,
+// which can't set headers: the access token may come in the access_token query parameter instead.
+func StreamAuthMiddleware(next http.Handler) http.Handler {
+	auth := AuthMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		auth.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Amenity categories
+const (
+	AmenityCategoryBuilding  = "building"  // lift, power backup
+	AmenityCategoryCommunity = "community" // gym, pool, gated community
+	AmenityCategoryUnit      = "unit"      // modular kitchen, balcony
+	AmenityCategorySafety    = "safety"    // CCTV, security guard
+)
+
+// IsValidAmenityCategory reports whether c is a known amenity category
+func IsValidAmenityCategory(c string) bool {
+	switch c {
+	case AmenityCategoryBuilding, AmenityCategoryCommunity, AmenityCategoryUnit, AmenityCategorySafety:
+		return true
+	}
+	return false
+}
+
+// Amenity is an entry of the staff-managed amenity catalogue. Listings and projects reference amenities by ID.
+type Amenity struct {
+	ID            primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Label         string             `json:"label" bson:"label"`
+	IconKey       string             `json:"iconKey" bson:"iconKey"` // icon the apps show for it
+	Category      string             `json:"category" bson:"category"`
+	PropertyTypes []string           `json:"propertyTypes,omitempty" bson:"propertyTypes,omitempty"` // empty means every property type
+	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AppliesTo reports whether the amenity can be offered by a property of the given type
+func (a Amenity) AppliesTo(propertyType string) bool {
+	if len(a.PropertyTypes) == 0 {
+		return true
+	}
+	for _, t := range a.PropertyTypes {
+		if t == propertyType {
+			return true
+		}
+	}
+	return false
+}
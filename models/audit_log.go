@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditImpersonationStart   = "impersonation.start"
+	AuditImpersonationRequest = "impersonation.request"
+	AuditAccountDeletionAsked = "account.deletion_requested"
+	AuditAccountDeletionUndo  = "account.deletion_cancelled"
+	AuditAccountDeleted       = "account.deleted"
+	AuditDataExportRequested  = "account.export_requested"
+	AuditListingReportsClosed = "listing.reports_resolved"
+	AuditDuplicatesResolved   = "listing.duplicates_resolved"
+	AuditListingsExported     = "listing.exported"
+)
+
+// AuditLog is an append-only record of a sensitive action
+type AuditLog struct {
+	ID        primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
+	Action    string                 `json:"action" bson:"action"`
+	ActorID   primitive.ObjectID     `json:"actorId" bson:"actorId"`                         // who did it
+	SubjectID primitive.ObjectID     `json:"subjectId,omitempty" bson:"subjectId,omitempty"` // who or what it was done to
+	IP        string                 `json:"ip,omitempty" bson:"ip,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty" bson:"details,omitempty"`
+	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ContactReveal is a buyer asking to call a lister. The buyer gets a temporary proxy code to dial
+// through the app's masked number instead of the lister's real phone number.
+type ContactReveal struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	OwnerID    primitive.ObjectID `json:"-" bson:"ownerId"`
+	BuyerID    primitive.ObjectID `json:"buyerId" bson:"buyerId"`
+	BuyerPhone string             `json:"-" bson:"buyerPhone"`    // only calls from this number are forwarded
+	ProxyID    string             `json:"proxyId" bson:"proxyId"` // extension dialled after the masked number
+	ExpiresAt  time.Time          `json:"expiresAt" bson:"expiresAt"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// ContactRevealCounter counts the contacts a buyer revealed on one day, kept apart from the
+// reveals so the daily limit holds however soon their proxy codes expire
+type ContactRevealCounter struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	BuyerID   primitive.ObjectID `json:"buyerId" bson:"buyerId"`
+	Day       string             `json:"day" bson:"day"` // UTC date, YYYY-MM-DD
+	Count     int                `json:"count" bson:"count"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
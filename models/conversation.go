@@ -0,0 +1,96 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Conversation is the chat between a buyer and the lister about one listing
+type Conversation struct {
+	ID                 primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID         primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	BuyerID            primitive.ObjectID `json:"buyerId" bson:"buyerId"`
+	OwnerID            primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	LastMessageAt      time.Time          `json:"lastMessageAt,omitempty" bson:"lastMessageAt,omitempty"`
+	LastMessagePreview string             `json:"lastMessagePreview,omitempty" bson:"lastMessagePreview,omitempty"`
+	BuyerUnread        int                `json:"-" bson:"buyerUnread"`
+	OwnerUnread        int                `json:"-" bson:"ownerUnread"`
+	BuyerLastReadAt    time.Time          `json:"buyerLastReadAt,omitempty" bson:"buyerLastReadAt,omitempty"`
+	OwnerLastReadAt    time.Time          `json:"ownerLastReadAt,omitempty" bson:"ownerLastReadAt,omitempty"`
+	CreatedAt          time.Time          `json:"createdAt" bson:"createdAt"`
+	Unread             int                `json:"unread" bson:"-"`  // for the requesting user
+	Blocked            bool               `json:"blocked" bson:"-"` // either side blocked the other
+}
+
+// OtherParty returns the participant that isn't userID
+func (c Conversation) OtherParty(userID primitive.ObjectID) primitive.ObjectID {
+	if c.BuyerID == userID {
+		return c.OwnerID
+	}
+	return c.BuyerID
+}
+
+// UnreadField is the stored unread counter of the participant userID
+func (c Conversation) UnreadField(userID primitive.ObjectID) string {
+	if c.BuyerID == userID {
+		return "buyerUnread"
+	}
+	return "ownerUnread"
+}
+
+// LastReadField is the stored read receipt time of the participant userID
+func (c Conversation) LastReadField(userID primitive.ObjectID) string {
+	if c.BuyerID == userID {
+		return "buyerLastReadAt"
+	}
+	return "ownerLastReadAt"
+}
+
+// LastReadBy returns when the participant userID last read the conversation
+func (c Conversation) LastReadBy(userID primitive.ObjectID) time.Time {
+	if c.BuyerID == userID {
+		return c.BuyerLastReadAt
+	}
+	return c.OwnerLastReadAt
+}
+
+// Message is one chat message, with optional image attachments
+type Message struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ConversationID primitive.ObjectID `json:"conversationId" bson:"conversationId"`
+	SenderID       primitive.ObjectID `json:"senderId" bson:"senderId"`
+	Text           string             `json:"text,omitempty" bson:"text,omitempty"`
+	Images         []string           `json:"images,omitempty" bson:"images,omitempty"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	Read           bool               `json:"read" bson:"-"` // the recipient has read it
+}
+
+// UserBlock stops a user from messaging the user who blocked them
+type UserBlock struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	BlockerID primitive.ObjectID `json:"blockerId" bson:"blockerId"`
+	BlockedID primitive.ObjectID `json:"blockedId" bson:"blockedId"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// Message report states
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// MessageReport is a user flagging a conversation, or one message of it, for staff review
+type MessageReport struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ConversationID primitive.ObjectID `json:"conversationId" bson:"conversationId"`
+	MessageID      primitive.ObjectID `json:"messageId,omitempty" bson:"messageId,omitempty"`
+	ReporterID     primitive.ObjectID `json:"reporterId" bson:"reporterId"`
+	ReportedUserID primitive.ObjectID `json:"reportedUserId" bson:"reportedUserId"`
+	Reason         string             `json:"reason" bson:"reason"`
+	Status         string             `json:"status" bson:"status"`
+	City           string             `json:"city" bson:"city"` // copied from the listing talked about for staff scoping
+	Locality       string             `json:"locality" bson:"locality"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Data export states
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// DataExport is a user's request for a copy of their personal data
+type DataExport struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	Status    string             `json:"status" bson:"status"`
+	FilePath  string             `json:"-" bson:"filePath,omitempty"` // on disk under the export directory, never public
+	Error     string             `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+}
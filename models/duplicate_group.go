@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// States of a duplicate group
+const (
+	DuplicatesOpen      = "open"      // found by the detection job, awaiting review
+	DuplicatesConfirmed = "confirmed" // staff kept one listing and took the others down
+	DuplicatesDismissed = "dismissed" // not duplicates; these listings are never grouped again
+)
+
+// DuplicateGroup is a set of listings of one place, listing type, property type and number of
+// bedrooms that likely advertise the same property
+type DuplicateGroup struct {
+	ID           primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyIDs  []primitive.ObjectID `json:"propertyIds" bson:"propertyIds"`
+	PrimaryID    primitive.ObjectID   `json:"primaryId" bson:"primaryId"` // the listing shown when duplicates are collapsed
+	Score        float64              `json:"score" bson:"score"`         // average share of matching evidence
+	Status       string               `json:"status" bson:"status"`
+	PlaceID      primitive.ObjectID   `json:"placeId" bson:"placeId"` // locality, or city for listings without one
+	ListingType  string               `json:"listingType" bson:"listingType"`
+	PropertyType string               `json:"propertyType" bson:"propertyType"`
+	Bedrooms     int                  `json:"bedrooms" bson:"bedrooms"`
+	City         string               `json:"city" bson:"city"`
+	Locality     string               `json:"locality,omitempty" bson:"locality,omitempty"`
+	MemberKey    string               `json:"-" bson:"memberKey"` // sorted member IDs, to match regrouped sets
+	ResolvedBy   primitive.ObjectID   `json:"resolvedBy,omitempty" bson:"resolvedBy,omitempty"`
+	ResolvedAt   time.Time            `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+	CreatedAt    time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
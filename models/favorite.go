@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Favorite is a listing a user shortlisted
+type Favorite struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	PropertyID primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LeadStatus is how far a buyer inquiry got with the lister
+type LeadStatus string
+
+const (
+	LeadNew                LeadStatus = "new"
+	LeadContacted          LeadStatus = "contacted"
+	LeadSiteVisitScheduled LeadStatus = "site_visit_scheduled"
+	LeadNegotiating        LeadStatus = "negotiating"
+	LeadClosed             LeadStatus = "closed"
+	LeadLost               LeadStatus = "lost"
+)
+
+// IsValidLeadStatus reports whether s is a known lead status
+func IsValidLeadStatus(s LeadStatus) bool {
+	switch s {
+	case LeadNew, LeadContacted, LeadSiteVisitScheduled, LeadNegotiating, LeadClosed, LeadLost:
+		return true
+	}
+	return false
+}
+
+// How a lead came in
+const (
+	LeadSourceInquiry       = "inquiry"
+	LeadSourceContactReveal = "contact_reveal"
+)
+
+// LeadNote is a private note the lister keeps on a lead
+type LeadNote struct {
+	Text      string    `json:"text" bson:"text"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// LeadContact is the other party of a lead as shown to one side. PhoneNumber is only
+// filled in when the privacy rules allow it; buyers never get the lister's real number,
+// only the masked number and proxy code of an active contact reveal.
+type LeadContact struct {
+	ID          primitive.ObjectID `json:"_id"`
+	Name        string             `json:"name"`
+	PhoneNumber string             `json:"phoneNumber,omitempty"`
+	ProxyNumber string             `json:"proxyNumber,omitempty"`
+	ProxyID     string             `json:"proxyId,omitempty"`
+}
+
+// Lead is a buyer's inquiry on a listing, tracked by the lister through to a deal
+type Lead struct {
+	ID           primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID   primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	OwnerID      primitive.ObjectID `json:"ownerId" bson:"ownerId"` // lister of the property
+	BuyerID      primitive.ObjectID `json:"buyerId" bson:"buyerId"`
+	Message      string             `json:"message" bson:"message"`
+	Source       string             `json:"source,omitempty" bson:"source,omitempty"` // inquiry or contact_reveal
+	ShareContact bool               `json:"shareContact" bson:"shareContact"`         // buyer agreed to show their number to the lister
+	Status       LeadStatus         `json:"status" bson:"status"`
+	Notes        []LeadNote         `json:"notes,omitempty" bson:"notes,omitempty"` // lister only
+	FollowUpAt   time.Time          `json:"followUpAt,omitempty" bson:"followUpAt,omitempty"`
+	City         string             `json:"city" bson:"city"` // copied from the listing for staff scoping
+	Locality     string             `json:"locality" bson:"locality"`
+	CreatedAt    time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time          `json:"updatedAt" bson:"updatedAt"`
+	Contact      *LeadContact       `json:"contact,omitempty" bson:"-"` // the other party, filled in per viewer
+}
+
+// RevealBuyerContact reports whether the lister may see the buyer's phone number
+func (l Lead) RevealBuyerContact() bool {
+	return l.ShareContact
+}
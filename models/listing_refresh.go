@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ListingRefresh is an owner moving one of their listings back to the top of search
+type ListingRefresh struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	OwnerID    primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// ListingRefreshCounter counts the refreshes of an owner's listings on one day, claimed before a
+// listing moves up so concurrent refreshes can't exceed the daily limit
+type ListingRefreshCounter struct {
+	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	OwnerID   primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	Day       string             `json:"day" bson:"day"` // UTC date, YYYY-MM-DD
+	Count     int                `json:"count" bson:"count"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Reasons a user may report a listing for
+const (
+	ReportFake      = "fake"
+	ReportDuplicate = "duplicate"
+	ReportSold      = "already_sold"
+	ReportWrongInfo = "wrong_info"
+	ReportFraud     = "fraud"
+	ReportOther     = "other"
+)
+
+// IsValidReportReason reports whether reason is a known listing report reason
+func IsValidReportReason(reason string) bool {
+	switch reason {
+	case ReportFake, ReportDuplicate, ReportSold, ReportWrongInfo, ReportFraud, ReportOther:
+		return true
+	}
+	return false
+}
+
+// Outcomes of a listing report besides ReportOpen
+const (
+	ReportUpheld = "upheld"
+)
+
+// ListingReport is a user flagging a listing as fake, duplicate, sold, ...
+type ListingReport struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	ReporterID primitive.ObjectID `json:"reporterId" bson:"reporterId"`
+	Reason     string             `json:"reason" bson:"reason"`
+	Details    string             `json:"details,omitempty" bson:"details,omitempty"`
+	Status     string             `json:"status" bson:"status"` // open, upheld or dismissed
+	Outcome    string             `json:"outcome,omitempty" bson:"outcome,omitempty"`
+	City       string             `json:"city" bson:"city"` // copied from the listing for staff scoping
+	Locality   string             `json:"locality" bson:"locality"`
+	ResolvedBy primitive.ObjectID `json:"-" bson:"resolvedBy,omitempty"`
+	ResolvedAt time.Time          `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Who made a listing change
+const (
+	ChangedByOwner  = "owner"
+	ChangedByStaff  = "staff"
+	ChangedBySystem = "system"
+)
+
+// FieldChange is one field of a listing changing value. Fields of nested terms are dotted, "rental.monthlyRent".
+type FieldChange struct {
+	Field string      `json:"field" bson:"field"`
+	Old   interface{} `json:"old,omitempty" bson:"old,omitempty"`
+	New   interface{} `json:"new,omitempty" bson:"new,omitempty"`
+}
+
+// ListingVersion records one update of a listing as the fields it changed
+type ListingVersion struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	ActorID    primitive.ObjectID `json:"actorId,omitempty" bson:"actorId,omitempty"`
+	ChangedBy  string             `json:"changedBy" bson:"changedBy"`
+	Changes    []FieldChange      `json:"changes" bson:"changes"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"PropertyAppBackend/models/property"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Levels of the locality hierarchy, from the widest
+const (
+	LevelCountry  = "country"
+	LevelState    = "state"
+	LevelCity     = "city"
+	LevelLocality = "locality"
+)
+
+// localityLevels maps each level to the level of its parent
+var localityLevels = map[string]string{
+	LevelCountry:  "",
+	LevelState:    LevelCountry,
+	LevelCity:     LevelState,
+	LevelLocality: LevelCity,
+}
+
+// ParentLevel returns the level a place of the given level sits under, and whether level is known
+func ParentLevel(level string) (string, bool) {
+	parent, ok := localityLevels[level]
+	return parent, ok
+}
+
+// Locality is a place of the locality master: a country, state, city or locality. Listings
+// reference their city and locality by ID and carry the canonical names.
+type Locality struct {
+	ID       primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Level    string             `json:"level" bson:"level"`
+	Name     string             `json:"name" bson:"name"`
+	Aliases  []string           `json:"aliases,omitempty" bson:"aliases,omitempty"` // other spellings, "Bangalore" for Bengaluru
+	ParentID primitive.ObjectID `json:"parentId,omitempty" bson:"parentId,omitempty"`
+	// Every place above this one, widest first
+	AncestorIDs []primitive.ObjectID `json:"ancestorIds,omitempty" bson:"ancestorIds,omitempty"`
+	Country     string               `json:"country,omitempty" bson:"country,omitempty"` // names of the ancestors
+	State       string               `json:"state,omitempty" bson:"state,omitempty"`
+	City        string               `json:"city,omitempty" bson:"city,omitempty"`
+	CityID      primitive.ObjectID   `json:"cityId,omitempty" bson:"cityId,omitempty"` // localities only
+	Centroid    *property.GeoPoint   `json:"centroid,omitempty" bson:"centroid,omitempty"`
+	// Normalized name, and name and aliases, for exact and prefix lookups
+	NameKey      string    `json:"-" bson:"nameKey"`
+	SearchKeys   []string  `json:"-" bson:"searchKeys"`
+	ListingCount int       `json:"listingCount" bson:"listingCount"` // published listings, refreshed periodically
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// NormalizePlaceName folds a place name for matching: lower case, single spaces, no dots or commas
+func NormalizePlaceName(name string) string {
+	name = strings.NewReplacer(".", " ", ",", " ", "-", " ").Replace(strings.ToLower(name))
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// SetSearchKeys recomputes the normalized lookup keys from the name and aliases
+func (l *Locality) SetSearchKeys() {
+	l.NameKey = NormalizePlaceName(l.Name)
+	keys := []string{l.NameKey}
+	for _, alias := range l.Aliases {
+		if key := NormalizePlaceName(alias); key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+	l.SearchKeys = keys
+}
+
+// PlaceUnder sets the parent of the place and the ancestry it inherits from it
+func (l *Locality) PlaceUnder(parent Locality) {
+	l.ParentID = parent.ID
+	l.AncestorIDs = append(append([]primitive.ObjectID{}, parent.AncestorIDs...), parent.ID)
+	l.Country, l.State, l.City, l.CityID = parent.Country, parent.State, parent.City, parent.CityID
+	switch parent.Level {
+	case LevelCountry:
+		l.Country = parent.Name
+	case LevelState:
+		l.State = parent.Name
+	case LevelCity:
+		l.City, l.CityID = parent.Name, parent.ID
+	}
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoginAttempt tracks consecutive failed password logins for one username or one client IP
+type LoginAttempt struct {
+	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Key         string             `json:"key" bson:"key"` // "username:<name>" or "ip:<address>"
+	Failures    int                `json:"failures" bson:"failures"`
+	LastFailure time.Time          `json:"lastFailure" bson:"lastFailure"`
+	LockedUntil time.Time          `json:"lockedUntil,omitempty" bson:"lockedUntil,omitempty"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Notification kinds
+const (
+	NotificationSavedSearchMatch = "saved_search_match"
+	NotificationNewLead          = "new_lead"
+	NotificationLeadUpdate       = "lead_update"
+	NotificationSiteVisit        = "site_visit"
+	NotificationNewMessage       = "new_message"
+	NotificationListingReport    = "listing_report"
+	NotificationListingExpiry    = "listing_expiry"
+	NotificationListingDuplicate = "listing_duplicate"
+	NotificationListingQuality   = "listing_quality"
+)
+
+// Notification is an entry of a user's in-app notification inbox
+type Notification struct {
+	ID        primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID     `json:"userId" bson:"userId"`
+	Kind      string                 `json:"kind" bson:"kind"`
+	Title     string                 `json:"title" bson:"title"`
+	Body      string                 `json:"body" bson:"body"`
+	Data      map[string]interface{} `json:"data,omitempty" bson:"data,omitempty"`
+	Read      bool                   `json:"read" bson:"read"`
+	CreatedAt time.Time              `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,105 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// Permission is a single staff capability checked by the admin API
+type Permission string
+
+const (
+	ApproveListings  Permission = "approve_listings"
+	ManageUsers      Permission = "manage_users"
+	ViewLeads        Permission = "view_leads"
+	ExportData       Permission = "export_data"
+	ManageStaff      Permission = "manage_staff"
+	VerifyUsers      Permission = "verify_users"
+	ManageLocalities Permission = "manage_localities"
+	ManageAmenities  Permission = "manage_amenities"
+)
+
+// AllPermissions lists every permission, in the order they are shown to admins
+var AllPermissions = []Permission{ApproveListings, ManageUsers, ViewLeads, ExportData, ManageStaff, VerifyUsers, ManageLocalities, ManageAmenities}
+
+// rolePermissions is the permission set each role gets when none was assigned explicitly
+var rolePermissions = map[Role][]Permission{
+	Admin:     AllPermissions,
+	MiniAdmin: {ApproveListings, ViewLeads, VerifyUsers},
+}
+
+// StaffScope restricts a staff account to listings and users in some cities or localities.
+// Staff without a scope are unrestricted; an empty scope covers no place at all.
+type StaffScope struct {
+	Cities     []string `json:"cities,omitempty" bson:"cities,omitempty"`
+	Localities []string `json:"localities,omitempty" bson:"localities,omitempty"`
+}
+
+// PermissionsFor returns the permissions held by a user. Admins always hold every permission,
+// other staff hold what an admin assigned them or their role's defaults.
+func PermissionsFor(user User) []Permission {
+	if user.Role == Admin || user.Permissions == nil {
+		return rolePermissions[user.Role]
+	}
+	return user.Permissions
+}
+
+// HasPermission reports whether the user holds the permission
+func HasPermission(user User, permission Permission) bool {
+	for _, p := range PermissionsFor(user) {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidPermission reports whether p is a known permission
+func IsValidPermission(p Permission) bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Unscoped reports whether the user may see everything regardless of location
+func Unscoped(user User) bool {
+	return user.Role == Admin || user.Scope == nil
+}
+
+// ScopeFilter returns the query restricting documents to the user's scope, matching
+// cityField against the scope's cities and localityField against its localities
+func ScopeFilter(user User, cityField, localityField string) bson.M {
+	if Unscoped(user) {
+		return bson.M{}
+	}
+	var or []bson.M
+	if len(user.Scope.Cities) > 0 {
+		or = append(or, bson.M{cityField: bson.M{"$in": user.Scope.Cities}})
+	}
+	if len(user.Scope.Localities) > 0 {
+		or = append(or, bson.M{localityField: bson.M{"$in": user.Scope.Localities}})
+	}
+	if len(or) == 0 {
+		// An empty scope matches nothing
+		return bson.M{"_id": bson.M{"$exists": false}}
+	}
+	return bson.M{"$or": or}
+}
+
+// InScope reports whether a city/locality pair falls inside the user's scope
+func InScope(user User, city, locality string) bool {
+	if Unscoped(user) {
+		return true
+	}
+	for _, c := range user.Scope.Cities {
+		if c == city {
+			return true
+		}
+	}
+	for _, l := range user.Scope.Localities {
+		if l == locality {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PriceStats are the price per square foot statistics of the listings of one city or locality,
+// listing type and property type over the last year, aggregated periodically from published, sold
+// and let listings
+type PriceStats struct {
+	ID           primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+	PlaceID      primitive.ObjectID `json:"placeId" bson:"placeId"`
+	Level        string             `json:"level" bson:"level"` // city or locality
+	City         string             `json:"city" bson:"city"`
+	Locality     string             `json:"locality,omitempty" bson:"locality,omitempty"`
+	ListingType  string             `json:"listingType" bson:"listingType"`
+	PropertyType string             `json:"propertyType" bson:"propertyType"`
+	Listings     int                `json:"listings" bson:"listings"`
+	Median       float64            `json:"median" bson:"median"`
+	P10          float64            `json:"p10" bson:"p10"`
+	P25          float64            `json:"p25" bson:"p25"`
+	P75          float64            `json:"p75" bson:"p75"`
+	P90          float64            `json:"p90" bson:"p90"`
+	Trend        []MonthlyPrice     `json:"trend" bson:"trend"` // oldest month first
+	UpdatedAt    time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// MonthlyPrice is the median price per square foot of the listings published in one month
+type MonthlyPrice struct {
+	Month    string  `json:"month" bson:"month"` // YYYY-MM
+	Median   float64 `json:"median" bson:"median"`
+	Listings int     `json:"listings" bson:"listings"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Construction stages of a project
+const (
+	ConstructionUpcoming          = "upcoming"
+	ConstructionUnderConstruction = "under_construction"
+	ConstructionReadyToMove       = "ready_to_move"
+)
+
+// IsValidConstructionStatus reports whether s is a known construction stage
+func IsValidConstructionStatus(s string) bool {
+	return s == ConstructionUpcoming || s == ConstructionUnderConstruction || s == ConstructionReadyToMove
+}
+
+// Project is a builder's new-construction development. Its unit configurations are ordinary sale
+// listings pointing at it with their projectId; they share its location, amenities and approvals.
+type Project struct {
+	ID                 primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	BuilderID          primitive.ObjectID   `json:"builderId" bson:"builderId"`
+	Name               string               `json:"name" bson:"name"`
+	Description        string               `json:"description,omitempty" bson:"description,omitempty"`
+	RERAID             string               `json:"reraId" bson:"reraId"`
+	PossessionDate     time.Time            `json:"possessionDate" bson:"possessionDate"`
+	ConstructionStatus string               `json:"constructionStatus" bson:"constructionStatus"`
+	Towers             []string             `json:"towers,omitempty" bson:"towers,omitempty"`
+	TotalUnits         int                  `json:"totalUnits" bson:"totalUnits"`
+	AmenityIDs         []primitive.ObjectID `json:"amenityIds,omitempty" bson:"amenityIds,omitempty"` // shared by its units
+	Approvals          []string             `json:"approvals,omitempty" bson:"approvals,omitempty"`   // approving authorities, bank approvals...
+	Address            string               `json:"address" bson:"address"`
+	City               string               `json:"city" bson:"city"`
+	Locality           string               `json:"locality" bson:"locality"`
+	CityID             primitive.ObjectID   `json:"cityId,omitempty" bson:"cityId,omitempty"`
+	LocalityID         primitive.ObjectID   `json:"localityId,omitempty" bson:"localityId,omitempty"`
+	Images             []string             `json:"images,omitempty" bson:"images,omitempty"`
+	CreatedAt          time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt          time.Time            `json:"updatedAt" bson:"updatedAt"`
+
+	// Aggregated from the project's published units by RefreshProjectStats
+	MinPrice       float64 `json:"minPrice,omitempty" bson:"minPrice,omitempty"`
+	MaxPrice       float64 `json:"maxPrice,omitempty" bson:"maxPrice,omitempty"`
+	PublishedUnits int     `json:"publishedUnits" bson:"publishedUnits"`
+}
+
+// Validate checks the fields every project needs
+func (p Project) Validate() error {
+	if p.Name == "" || p.RERAID == "" || p.City == "" {
+		return fmt.Errorf("name, reraId and city are required")
+	}
+	if !IsValidConstructionStatus(p.ConstructionStatus) {
+		return fmt.Errorf("constructionStatus must be upcoming, under_construction or ready_to_move")
+	}
+	if p.PossessionDate.IsZero() {
+		return fmt.Errorf("possessionDate is required")
+	}
+	if p.TotalUnits < 1 {
+		return fmt.Errorf("totalUnits must be positive")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package property
+
+// Steps of the listing form, in the order they are filled in
+const (
+	StepBasics    = "basics"    // title, property type and listing type
+	StepLocation  = "location"  // city, locality, address and map pin
+	StepDetails   = "details"   // area, bedrooms, floor and description
+	StepPricing   = "pricing"   // price, or rental terms for rent listings
+	StepAmenities = "amenities" // amenity IDs from the catalogue
+	StepPhotos    = "photos"    // uploaded images
+)
+
+// ListingSteps are the steps of the listing form in order
+var ListingSteps = []string{StepBasics, StepLocation, StepDetails, StepPricing, StepAmenities, StepPhotos}
+
+// IsValidStep reports whether step is a step of the listing form
+func IsValidStep(step string) bool {
+	for _, s := range ListingSteps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// Completeness tells how much of a listing is filled in and what is still missing
+type Completeness struct {
+	Score    int      `json:"score"`              // percentage of the fields filled in
+	Missing  []string `json:"missing"`            // fields not filled in yet
+	NextStep string   `json:"nextStep,omitempty"` // first step with a missing field
+	Ready    bool     `json:"ready"`              // every field needed to submit for review is there
+}
+
+// completenessCheck is one field of the listing form
+type completenessCheck struct {
+	step     string
+	field    string
+	required bool // needed to submit the listing for review
+	filled   func(p Property) bool
+}
+
+var completenessChecks = []completenessCheck{
+	{StepBasics, "title", true, func(p Property) bool { return p.Title != "" }},
+	{StepBasics, "propertyType", true, func(p Property) bool { return p.PropertyType != "" }},
+	{StepBasics, "listingType", true, func(p Property) bool { return p.ListingType != "" }},
+	{StepLocation, "city", true, func(p Property) bool { return p.City != "" }},
+	{StepLocation, "locality", false, func(p Property) bool { return p.Locality != "" }},
+	{StepLocation, "address", false, func(p Property) bool { return p.Address != "" }},
+	{StepDetails, "areaSqft", false, func(p Property) bool { return p.AreaSqft > 0 }},
+	{StepDetails, "description", false, func(p Property) bool { return p.Description != "" }},
+	{StepPricing, "price", true, func(p Property) bool { return p.Price > 0 && (p.ListingType != "rent" || p.Rental != nil) }},
+	{StepAmenities, "amenityIds", false, func(p Property) bool { return len(p.AmenityIDs) > 0 }},
+	{StepPhotos, "images", false, func(p Property) bool { return len(p.Images) > 0 }},
+}
+
+// CheckCompleteness scores how much of the listing form p fills in
+func (p Property) CheckCompleteness() Completeness {
+	completeness := Completeness{Missing: []string{}, Ready: true}
+	filled := 0
+	for _, check := range completenessChecks {
+		if check.filled(p) {
+			filled++
+			continue
+		}
+		completeness.Missing = append(completeness.Missing, check.field)
+		if completeness.NextStep == "" {
+			completeness.NextStep = check.step
+		}
+		if check.required {
+			completeness.Ready = false
+		}
+	}
+	completeness.Score = filled * 100 / len(completenessChecks)
+	return completeness
+}
@@ -0,0 +1,172 @@
+package property
+
+import (
+	"math"
+	"math/bits"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fingerprint is what a listing is compared on to find duplicates, beside its pin, area and floor
+type Fingerprint struct {
+	AddressWords []string  `bson:"addressWords,omitempty"` // normalized, sorted
+	Unit         string    `bson:"unit,omitempty"`         // flat or unit number from the address, normalized
+	PriceBand    int       `bson:"priceBand"`
+	Image        string    `bson:"image,omitempty"`     // the primary image hashed
+	ImageHash    string    `bson:"imageHash,omitempty"` // 64-bit difference hash, hex
+	At           time.Time `bson:"at"`                  // updatedAt of the listing when fingerprinted
+}
+
+// Words of an address that don't tell places apart
+var addressFillerWords = map[string]bool{
+	"flat": true, "no": true, "apartment": true, "apartments": true, "apt": true, "house": true,
+	"road": true, "rd": true, "street": true, "st": true, "lane": true, "main": true, "cross": true,
+	"near": true, "opp": true, "opposite": true, "behind": true, "the": true, "and": true, "of": true,
+}
+
+// AddressWords normalizes an address into its distinctive words, sorted and without repeats
+func AddressWords(address string) []string {
+	seen := map[string]bool{}
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(address), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		if !addressFillerWords[word] && !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return words
+}
+
+// Flat or unit numbers in an address: "Flat No. 402", "Apt #12B", "Unit 7" or "A-402"
+var (
+	unitNumberPattern  = regexp.MustCompile(`(?i)\b(?:flat|apartment|apt|unit|villa|door|house|plot|shop|office)\b\s*(?:no\b\.?|number\b|#)?\s*[:\-]?\s*([a-z]?\s*-?\s*\d+[a-z]?)\b`)
+	towerNumberPattern = regexp.MustCompile(`(?i)(?:^|[\s,#])([a-z]{1,2}\s*-\s*\d{1,4})\b`)
+)
+
+// UnitNumber returns the flat or unit number an address names, normalized to lower case without
+// spaces or dashes, or "" when it names none
+func UnitNumber(address string) string {
+	match := unitNumberPattern.FindStringSubmatch(address)
+	if match == nil {
+		match = towerNumberPattern.FindStringSubmatch(address)
+	}
+	if match == nil {
+		return ""
+	}
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(match[1]))
+}
+
+// PriceBand returns the band of a price on a scale of 10% steps
+func PriceBand(price float64) int {
+	if price <= 0 {
+		return 0
+	}
+	return int(math.Floor(math.Log(price) / math.Log(1.1)))
+}
+
+// Weights of the evidence two listings are the same property, and when each matches
+const (
+	imageWeight   = 0.35
+	geoWeight     = 0.2
+	addressWeight = 0.2
+	areaWeight    = 0.1
+	priceWeight   = 0.1
+	floorWeight   = 0.05
+
+	maxImageHashDistance = 10   // differing bits of the image hashes
+	maxPinDistance       = 100  // meters
+	minAddressOverlap    = 0.6  // shared share of the address words
+	maxAreaDifference    = 0.05 // relative
+)
+
+// Two listings are likely duplicates when at least minDuplicateEvidence of the evidence weight can
+// be compared, at least duplicateScore of it matches and their photos or pins match: flats of one
+// society share the address, area and price, so those alone don't tell them apart
+const (
+	minDuplicateEvidence = 0.4
+	duplicateScore       = 0.75
+)
+
+// DuplicateScore compares two fingerprinted listings of the same place, listing type and property
+// type. It returns the share of the comparable evidence that matches and whether that makes them
+// likely duplicates. Listings on different floors, in different towers or with different flat
+// numbers are never duplicates.
+func DuplicateScore(a, b Property) (float64, bool) {
+	if a.Fingerprint == nil || b.Fingerprint == nil || a.Bedrooms != b.Bedrooms {
+		return 0, false
+	}
+	if a.Floor != nil && b.Floor != nil && *a.Floor != *b.Floor {
+		return 0, false
+	}
+	if a.Tower != "" && b.Tower != "" && !strings.EqualFold(strings.TrimSpace(a.Tower), strings.TrimSpace(b.Tower)) {
+		return 0, false
+	}
+	fa, fb := a.Fingerprint, b.Fingerprint
+	if fa.Unit != "" && fb.Unit != "" && fa.Unit != fb.Unit {
+		return 0, false
+	}
+
+	var available, matched float64
+	weigh := func(weight float64, match bool) {
+		available += weight
+		if match {
+			matched += weight
+		}
+	}
+	// Only the same photo or pin shows it is the same flat rather than one like it
+	placed := false
+	if fa.ImageHash != "" && fb.ImageHash != "" {
+		ha, errA := strconv.ParseUint(fa.ImageHash, 16, 64)
+		hb, errB := strconv.ParseUint(fb.ImageHash, 16, 64)
+		if errA == nil && errB == nil {
+			same := bits.OnesCount64(ha^hb) <= maxImageHashDistance
+			weigh(imageWeight, same)
+			placed = placed || same
+		}
+	}
+	if a.Location != nil && b.Location != nil {
+		near := a.Location.DistanceTo(*b.Location) <= maxPinDistance
+		weigh(geoWeight, near)
+		placed = placed || near
+	}
+	if len(fa.AddressWords) > 0 && len(fb.AddressWords) > 0 {
+		weigh(addressWeight, wordOverlap(fa.AddressWords, fb.AddressWords) >= minAddressOverlap)
+	}
+	if a.AreaSqft > 0 && b.AreaSqft > 0 {
+		weigh(areaWeight, math.Abs(a.AreaSqft-b.AreaSqft)/math.Max(a.AreaSqft, b.AreaSqft) <= maxAreaDifference)
+	}
+	if fa.PriceBand > 0 && fb.PriceBand > 0 {
+		band := fa.PriceBand - fb.PriceBand
+		weigh(priceWeight, band >= -1 && band <= 1)
+	}
+	if a.Floor != nil && b.Floor != nil {
+		weigh(floorWeight, true)
+	}
+
+	if available < minDuplicateEvidence {
+		return 0, false
+	}
+	score := matched / available
+	return score, placed && score >= duplicateScore
+}
+
+// wordOverlap returns the share of the words of the shorter sorted list found in the other
+func wordOverlap(a, b []string) float64 {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	shared := 0
+	for _, word := range a {
+		i := sort.SearchStrings(b, word)
+		if i < len(b) && b[i] == word {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a))
+}
@@ -0,0 +1,115 @@
+package property
+
+import (
+	"testing"
+)
+
+func TestUnitNumber(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"", ""},
+		{"Flat No. 402, Sunrise Apartments", "402"},
+		{"flat no 402", "402"},
+		{"Apt #12B, Hill Road", "12b"},
+		{"Unit 7, Tech Park", "7"},
+		{"Plot no: 23, Sector 5", "23"},
+		{"Apartment A-402", "a402"},
+		{"A-402, Green Towers", "a402"},
+		{"B - 12 Lake View", "b12"},
+		// Names and wings aren't unit numbers
+		{"Sunrise Apartments 2, Powai", ""},
+		{"402 B wing, Lake Homes", ""},
+		{"Hiranandani Gardens, Powai", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := UnitNumber(tt.address); got != tt.want {
+				t.Errorf("UnitNumber(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPriceBand(t *testing.T) {
+	if band := PriceBand(0); band != 0 {
+		t.Errorf("PriceBand(0) = %d, want 0", band)
+	}
+	if a, b := PriceBand(1e7), PriceBand(1.05e7); b-a > 1 {
+		t.Errorf("prices 5%% apart are %d bands apart", b-a)
+	}
+	if a, b := PriceBand(1e7), PriceBand(1.5e7); b-a <= 1 {
+		t.Errorf("prices 50%% apart are only %d bands apart", b-a)
+	}
+}
+
+// duplicateListing returns a fingerprinted listing; two of them are the same flat
+func duplicateListing() Property {
+	floor := 4
+	pin, _ := NewGeoPoint(19.1176, 72.9060)
+	return Property{
+		Bedrooms: 2,
+		Floor:    &floor,
+		Location: pin,
+		AreaSqft: 1000,
+		Fingerprint: &Fingerprint{
+			AddressWords: AddressWords("Flat 402, Lake Homes, Powai"),
+			Unit:         "402",
+			PriceBand:    PriceBand(1e7),
+			ImageHash:    "f0f0f0f0f0f0f0f0",
+		},
+	}
+}
+
+func TestDuplicateScore(t *testing.T) {
+	farPin, _ := NewGeoPoint(19.1276, 72.9060)
+	tests := []struct {
+		name  string
+		edit  func(b *Property)
+		score float64
+		dup   bool
+	}{
+		{"same flat", func(b *Property) {}, 1, true},
+		{"not fingerprinted", func(b *Property) { b.Fingerprint = nil }, 0, false},
+		{"other bedrooms", func(b *Property) { b.Bedrooms = 3 }, 0, false},
+		{"other floor", func(b *Property) { floor := 5; b.Floor = &floor }, 0, false},
+		{"floor unknown", func(b *Property) { b.Floor = nil }, 1, true},
+		{"other tower", func(b *Property) { b.Tower = "B" }, 1, true}, // only one side names a tower
+		{"other flat number", func(b *Property) { b.Fingerprint.Unit = "403" }, 0, false},
+		{"flat number unknown", func(b *Property) { b.Fingerprint.Unit = "" }, 1, true},
+		{"photo recompressed", func(b *Property) { b.Fingerprint.ImageHash = "f0f0f0f0f0f0f00f" }, 1, true},
+		{"same photo, pin moved", func(b *Property) { b.Location = farPin }, 0.8, true},
+		// Flats of one society share the pin, address, area and price
+		{"other photo", func(b *Property) { b.Fingerprint.ImageHash = "0f0f0f0f0f0f0f0f" }, 0.65, false},
+		{"no photo or pin", func(b *Property) { b.Fingerprint.ImageHash, b.Location = "", nil }, 1, false},
+		{"too little evidence", func(b *Property) {
+			b.Fingerprint.ImageHash, b.Location, b.Fingerprint.AddressWords, b.Floor = "", nil, nil, nil
+		}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := duplicateListing(), duplicateListing()
+			tt.edit(&b)
+			score, dup := DuplicateScore(a, b)
+			if dup != tt.dup || score < tt.score-1e-9 || score > tt.score+1e-9 {
+				t.Errorf("DuplicateScore = %v, %v, want %v, %v", score, dup, tt.score, tt.dup)
+			}
+			if rScore, rDup := DuplicateScore(b, a); rScore != score || rDup != dup {
+				t.Errorf("DuplicateScore isn't symmetric: %v, %v the other way round", rScore, rDup)
+			}
+		})
+	}
+}
+
+func TestDuplicateScoreTowers(t *testing.T) {
+	a, b := duplicateListing(), duplicateListing()
+	a.Tower, b.Tower = "A", "a "
+	if _, dup := DuplicateScore(a, b); !dup {
+		t.Error("towers differing only in case and spaces should match")
+	}
+	b.Tower = "B"
+	if score, dup := DuplicateScore(a, b); dup || score != 0 {
+		t.Errorf("listings in different towers = %v, %v, want 0, false", score, dup)
+	}
+}
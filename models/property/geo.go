@@ -0,0 +1,32 @@
+package property
+
+import (
+	"fmt"
+	"math"
+)
+
+// GeoPoint is a GeoJSON point, coordinates in longitude, latitude order
+type GeoPoint struct {
+	Type        string     `json:"type" bson:"type"`
+	Coordinates [2]float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// NewGeoPoint returns the point at the given latitude and longitude
+func NewGeoPoint(lat, lng float64) (*GeoPoint, error) {
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return nil, fmt.Errorf("coordinates out of range")
+	}
+	return &GeoPoint{Type: "Point", Coordinates: [2]float64{lng, lat}}, nil
+}
+
+// Mean radius of the earth in meters
+const earthRadius = 6371000
+
+// DistanceTo returns the great-circle distance between two points in meters
+func (p GeoPoint) DistanceTo(other GeoPoint) float64 {
+	lat1, lat2 := p.Coordinates[1]*math.Pi/180, other.Coordinates[1]*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (other.Coordinates[0] - p.Coordinates[0]) * math.Pi / 180
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
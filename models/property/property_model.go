@@ -1,5 +1,111 @@
 package property
 
-type  Property  struct{
+import (
+	"time"
 
-}
\ No newline at end of file
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Status is the state of a listing in its moderation lifecycle
+type Status string
+
+const (
+	Draft         Status = "draft"
+	PendingReview Status = "pending_review"
+	Published     Status = "published"
+	Rejected      Status = "rejected"
+	Sold          Status = "sold"
+	Archived      Status = "archived"
+	Suspended     Status = "suspended" // hidden after too many user reports until staff review them
+	Let           Status = "let"       // rental taken by a tenant, archived automatically after a while
+	Expired       Status = "expired"   // past its expiry date until the owner renews it
+)
+
+// transitions lists the states a listing may move to from each state
+var transitions = map[Status][]Status{
+	Draft:         {PendingReview, Archived},
+	PendingReview: {Published, Rejected, Archived},
+	Published:     {Sold, Let, Archived, PendingReview, Suspended, Rejected, Expired},
+	Rejected:      {PendingReview, Archived},
+	Sold:          {Archived},
+	Let:           {Archived, PendingReview},
+	Suspended:     {Published, Rejected, Archived},
+	Expired:       {PendingReview, Archived}, // renewed listings are reviewed again before they go back up
+	Archived:      {},
+}
+
+// CanTransition reports whether a listing may move from one status to another
+func CanTransition(from, to Status) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// PricePoint is a listing's price from a moment on
+type PricePoint struct {
+	Price float64   `json:"price"`
+	From  time.Time `json:"from"`
+}
+
+// ListerSummary is the public view of whoever posted a listing. It never carries the lister's
+// phone number: buyers reach listers through inquiries, chat or a masked contact reveal.
+type ListerSummary struct {
+	ID         primitive.ObjectID `json:"_id"`
+	Name       string             `json:"name"`
+	UserType   string             `json:"userType,omitempty"`
+	AgencyName string             `json:"agencyName,omitempty"`
+	Verified   bool               `json:"verified"`
+}
+
+type Property struct {
+	ID              primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	OwnerID         primitive.ObjectID   `json:"ownerId" bson:"ownerId"`
+	Title           string               `json:"title" bson:"title"`
+	Description     string               `json:"description" bson:"description"`
+	PropertyType    string               `json:"propertyType" bson:"propertyType"` // apartment, villa, plot, office...
+	ListingType     string               `json:"listingType" bson:"listingType"`   // sale or rent
+	Price           float64              `json:"price" bson:"price"`
+	PreviousPrice   float64              `json:"previousPrice,omitempty" bson:"previousPrice,omitempty"`   // before the last price change
+	PriceDroppedAt  time.Time            `json:"priceDroppedAt,omitempty" bson:"priceDroppedAt,omitempty"` // when the price was last reduced
+	AreaSqft        float64              `json:"areaSqft" bson:"areaSqft"`
+	Bedrooms        int                  `json:"bedrooms" bson:"bedrooms"`
+	Floor           *int                 `json:"floor,omitempty" bson:"floor,omitempty"` // 0 for the ground floor
+	Address         string               `json:"address" bson:"address"`
+	City            string               `json:"city" bson:"city"` // canonical names from the locality master
+	Locality        string               `json:"locality" bson:"locality"`
+	CityID          primitive.ObjectID   `json:"cityId,omitempty" bson:"cityId,omitempty"`
+	LocalityID      primitive.ObjectID   `json:"localityId,omitempty" bson:"localityId,omitempty"`
+	Location        *GeoPoint            `json:"location,omitempty" bson:"location,omitempty"` // pin dropped by the lister
+	Images          []string             `json:"images,omitempty" bson:"images,omitempty"`
+	AmenityIDs      []primitive.ObjectID `json:"amenityIds,omitempty" bson:"amenityIds,omitempty"` // from the amenity catalogue
+	AmenityLabels   []string             `json:"-" bson:"amenityLabels,omitempty"`                 // copied from the catalogue for the text index
+	Status          Status               `json:"status" bson:"status"`
+	RejectionReason string               `json:"rejectionReason,omitempty" bson:"rejectionReason,omitempty"`
+	ReviewedBy      primitive.ObjectID   `json:"reviewedBy,omitempty" bson:"reviewedBy,omitempty"`
+	CreatedAt       time.Time            `json:"createdAt" bson:"createdAt"`
+	UpdatedAt       time.Time            `json:"updatedAt" bson:"updatedAt"`
+	PublishedAt     time.Time            `json:"publishedAt,omitempty" bson:"publishedAt,omitempty"`
+	BumpedAt        time.Time            `json:"bumpedAt,omitempty" bson:"bumpedAt,omitempty"` // search sort date, moved up on refresh and renewal
+	ExpiresAt       time.Time            `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+	ExpiryNoticeAt  time.Time            `json:"-" bson:"expiryNoticeAt,omitempty"`              // when the owner was told it expires soon
+	Rental          *RentalTerms         `json:"rental,omitempty" bson:"rental,omitempty"`       // rent listings only
+	ProjectID       primitive.ObjectID   `json:"projectId,omitempty" bson:"projectId,omitempty"` // set on the unit configurations of a builder project
+	UnitType        string               `json:"unitType,omitempty" bson:"unitType,omitempty"`   // "2BHK Type A"
+	Tower           string               `json:"tower,omitempty" bson:"tower,omitempty"`
+	LetAt           time.Time            `json:"letAt,omitempty" bson:"letAt,omitempty"`
+	FavoriteCount   int                  `json:"favoriteCount" bson:"favoriteCount"`
+	Fingerprint     *Fingerprint         `json:"-" bson:"fingerprint,omitempty"`
+	DuplicateGroup  primitive.ObjectID   `json:"duplicateGroupId,omitempty" bson:"duplicateGroupId,omitempty"` // likely duplicates awaiting review
+	DuplicateOf     primitive.ObjectID   `json:"duplicateOf,omitempty" bson:"duplicateOf,omitempty"`           // the listing of its group kept in collapsed search
+	Quality         *Quality             `json:"quality,omitempty" bson:"quality,omitempty"`                   // automated score and content checks
+	Lister          *ListerSummary       `json:"lister,omitempty" bson:"-"`                                    // filled in when listings are returned
+	IsFavorited     bool                 `json:"isFavorited" bson:"-"`                                         // for the requesting user
+	Relevance       float64              `json:"relevance,omitempty" bson:"relevance,omitempty"`               // text search ranking, never stored
+	Highlights      map[string]string    `json:"highlights,omitempty" bson:"-"`                                // text search snippets by field
+	PriceDropped    bool                 `json:"priceDropped" bson:"-"`                                        // price reduced recently
+	PriceHistory    []PricePoint         `json:"priceHistory,omitempty" bson:"-"`
+	Completeness    *Completeness        `json:"completeness,omitempty" bson:"-"` // drafts only
+}
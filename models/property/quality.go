@@ -0,0 +1,185 @@
+package property
+
+import (
+	"image"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Quality rules
+const (
+	RuleCompleteness = "completeness"
+	RulePhotos       = "photos"
+	RuleResolution   = "photo_resolution"
+	RuleDescription  = "description"
+	RulePrice        = "price"
+	RuleContact      = "contact_details" // phone numbers or email addresses in the text
+	RuleLinks        = "links"
+	RuleProfanity    = "profanity"
+)
+
+// Points each rule adds to the quality score, 100 in total
+const (
+	completenessPoints = 30
+	photoPoints        = 20
+	resolutionPoints   = 15
+	descriptionPoints  = 15
+	pricePoints        = 20
+)
+
+const (
+	wantedPhotos          = 5
+	minDescriptionLength  = 80  // characters, below which a description is too thin
+	goodDescriptionLength = 400 // characters for the full description points
+	minPhotoWidth         = 1024
+	minPhotoHeight        = 768
+)
+
+// Quality is the automated assessment of a listing, used to rank it and to reject obvious failures
+type Quality struct {
+	Score     int            `json:"score" bson:"score"` // 0 to 100
+	Issues    []QualityIssue `json:"issues" bson:"issues"`
+	Blocked   bool           `json:"blocked" bson:"blocked"` // a hard failure: the listing can't go live as is
+	Flagged   bool           `json:"flagged" bson:"flagged"` // a moderator should look at it: blocked or likely a phone number
+	UpdatedAt time.Time      `json:"-" bson:"updatedAt"`     // of the listing version scored
+	ScoredAt  time.Time      `json:"scoredAt" bson:"scoredAt"`
+}
+
+// QualityIssue is a rule a listing fails
+type QualityIssue struct {
+	Rule    string `json:"rule" bson:"rule"`
+	Message string `json:"message" bson:"message"`
+	Hard    bool   `json:"hard,omitempty" bson:"hard,omitempty"` // the listing is rejected for it
+}
+
+// QualityInputs are the facts about a listing that are looked up outside it
+type QualityInputs struct {
+	MedianPricePerSqft float64       // of comparable listings of its place, 0 when there are too few
+	PhotoSizes         []image.Point // of the photos that could be measured
+}
+
+var (
+	// Indian mobile numbers, optionally with +91 or 0, in the usual groupings: 9876543210,
+	// 98765 43210, 987 654 3210 or 9876 543 210
+	phonePattern = regexp.MustCompile(`(?:^|\D)(?:\+?91[\s\-]?|0)?[6-9](?:\d{9}|\d{4}[\s\-.]\d{5}|\d{2}[\s\-.]\d{3}[\s\-.]\d{4}|\d{3}[\s\-.]\d{3}[\s\-.]\d{3})(?:\D|$)`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	linkPattern  = regexp.MustCompile(`(?i:https?://|www\.)\S+|\b[a-z0-9\-]+\.(?:com|net|org|io|info|biz|co\.in)\b`)
+)
+
+// Words that get a listing rejected, matched on whole words or, for stems, word starts
+var (
+	profaneWords = map[string]bool{
+		"asshole": true, "bastard": true, "bitch": true, "cunt": true, "dickhead": true, "slut": true, "whore": true,
+		"shit": true, "shitty": true, "bullshit": true,
+		"chutiya": true, "madarchod": true, "bhenchod": true, "behenchod": true, "gandu": true, "harami": true,
+	}
+	profaneStems = []string{"fuck", "motherfuck"}
+)
+
+// CheckQuality scores p on how complete it is, its photos, its description and how its price
+// compares with similar listings, and looks for email addresses, links and profanity in its text,
+// which are hard failures. Phone numbers flag it for a moderator, as numbers like them can be
+// prices or areas.
+func (p Property) CheckQuality(in QualityInputs) Quality {
+	quality := Quality{Issues: []QualityIssue{}}
+	points := 0.0
+	issue := func(rule, message string, hard bool) {
+		quality.Issues = append(quality.Issues, QualityIssue{Rule: rule, Message: message, Hard: hard})
+		quality.Blocked = quality.Blocked || hard
+		quality.Flagged = quality.Flagged || hard
+	}
+
+	completeness := p.CheckCompleteness()
+	points += float64(completeness.Score) * completenessPoints / 100
+	if len(completeness.Missing) > 0 {
+		issue(RuleCompleteness, "Missing "+strings.Join(completeness.Missing, ", "), false)
+	}
+
+	photos := len(p.Images)
+	points += float64(min(photos, wantedPhotos)) * photoPoints / wantedPhotos
+	if photos < wantedPhotos {
+		issue(RulePhotos, "Listings with at least 5 photos get more attention", false)
+	}
+	if photos > 0 {
+		sharp := 0
+		for _, size := range in.PhotoSizes {
+			if max(size.X, size.Y) >= minPhotoWidth && min(size.X, size.Y) >= minPhotoHeight {
+				sharp++
+			}
+		}
+		// Photos that couldn't be measured earn no resolution points
+		points += float64(sharp) * resolutionPoints / float64(max(photos, len(in.PhotoSizes)))
+		if sharp < len(in.PhotoSizes) {
+			issue(RuleResolution, "Some photos are smaller than 1024x768", false)
+		}
+		if len(in.PhotoSizes) < photos {
+			issue(RuleResolution, "Upload the photos through the app so their resolution can be checked", false)
+		}
+	}
+
+	length := utf8.RuneCountInString(p.Description)
+	if length >= minDescriptionLength {
+		points += math.Min(float64(length), goodDescriptionLength) * descriptionPoints / goodDescriptionLength
+	} else {
+		issue(RuleDescription, "Describe the property in at least 80 characters", false)
+	}
+
+	switch ratio := p.priceRatio(in.MedianPricePerSqft); {
+	case ratio == 0:
+		points += pricePoints / 2
+	case ratio < 0.1 || ratio > 10:
+		issue(RulePrice, "The price is far off similar listings nearby, check it for typos", true)
+	case ratio < 0.5 || ratio > 2:
+		issue(RulePrice, "The price is well off similar listings nearby", false)
+	case ratio < 0.67 || ratio > 1.5:
+		points += pricePoints / 2
+	default:
+		points += pricePoints
+	}
+
+	text := p.Title + "\n" + p.Description + "\n" + p.Address
+	if emailPattern.MatchString(text) {
+		issue(RuleContact, "Remove email addresses: buyers contact you through the app", true)
+	}
+	if phonePattern.MatchString(text) {
+		issue(RuleContact, "Remove phone numbers: buyers contact you through the app", false)
+		quality.Flagged = true
+	}
+	if linkPattern.MatchString(emailPattern.ReplaceAllString(text, "")) {
+		issue(RuleLinks, "Remove links to other websites", true)
+	}
+	if hasProfanity(text) {
+		issue(RuleProfanity, "Remove offensive language", true)
+	}
+
+	quality.Score = int(math.Round(points))
+	return quality
+}
+
+// priceRatio returns how the price per square foot of p compares with median, or 0 when either is unknown
+func (p Property) priceRatio(median float64) float64 {
+	if median <= 0 || p.AreaSqft <= 0 || p.Price <= 0 {
+		return 0
+	}
+	return p.Price / p.AreaSqft / median
+}
+
+// hasProfanity reports whether text contains one of the profane words
+func hasProfanity(text string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
+	for _, word := range words {
+		if profaneWords[word] {
+			return true
+		}
+		for _, stem := range profaneStems {
+			if strings.HasPrefix(word, stem) {
+				return true
+			}
+		}
+	}
+	return false
+}
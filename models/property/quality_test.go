@@ -0,0 +1,133 @@
+package property
+
+import (
+	"image"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// goodListing returns a listing that passes every quality rule with full points against goodInputs
+func goodListing() Property {
+	return Property{
+		Title:        "Sunny 2BHK in Powai",
+		Description:  strings.Repeat("Spacious corner flat with lots of light. ", 10),
+		PropertyType: "apartment",
+		ListingType:  "sale",
+		Price:        1e7,
+		AreaSqft:     1000,
+		Bedrooms:     2,
+		Address:      "Hiranandani Gardens",
+		City:         "Mumbai",
+		Locality:     "Powai",
+		AmenityIDs:   []primitive.ObjectID{primitive.NewObjectID()},
+		Images:       []string{"1.jpg", "2.jpg", "3.jpg", "4.jpg", "5.jpg"},
+	}
+}
+
+var goodInputs = QualityInputs{
+	MedianPricePerSqft: 10000,
+	PhotoSizes:         []image.Point{{1600, 1200}, {1600, 1200}, {1200, 1600}, {1024, 768}, {2000, 1500}},
+}
+
+func TestCheckQualityScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(p *Property, in *QualityInputs)
+		score  int
+		issues []string
+	}{
+		{"complete", func(p *Property, in *QualityInputs) {}, 100, nil},
+		{"empty", func(p *Property, in *QualityInputs) { *p, *in = Property{}, QualityInputs{} }, 10, []string{RuleCompleteness, RulePhotos, RuleDescription}},
+		{"photos not measured", func(p *Property, in *QualityInputs) { in.PhotoSizes = nil }, 85, []string{RuleResolution}},
+		{"small photos", func(p *Property, in *QualityInputs) {
+			in.PhotoSizes = []image.Point{{800, 600}, {1600, 1200}, {1600, 1200}, {640, 480}, {1600, 1200}}
+		}, 94, []string{RuleResolution}},
+		{"some photos not measured", func(p *Property, in *QualityInputs) { in.PhotoSizes = in.PhotoSizes[:3] }, 94, []string{RuleResolution}},
+		{"small and unmeasured photos", func(p *Property, in *QualityInputs) {
+			in.PhotoSizes = []image.Point{{800, 600}, {1600, 1200}}
+		}, 88, []string{RuleResolution, RuleResolution}},
+		{"two photos", func(p *Property, in *QualityInputs) { p.Images = p.Images[:2] }, 88, []string{RulePhotos}},
+		{"short description", func(p *Property, in *QualityInputs) { p.Description = "Nice flat" }, 85, []string{RuleDescription}},
+		{"no comparables", func(p *Property, in *QualityInputs) { in.MedianPricePerSqft = 0 }, 90, nil},
+		{"price somewhat off", func(p *Property, in *QualityInputs) { p.Price = 1.6e7 }, 90, nil},
+		{"price well off", func(p *Property, in *QualityInputs) { p.Price = 3e7 }, 80, []string{RulePrice}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, in := goodListing(), goodInputs
+			tt.edit(&p, &in)
+			quality := p.CheckQuality(in)
+			if quality.Score != tt.score {
+				t.Errorf("score = %d, want %d", quality.Score, tt.score)
+			}
+			if got := issueRules(quality); strings.Join(got, ",") != strings.Join(tt.issues, ",") {
+				t.Errorf("issues = %v, want %v", got, tt.issues)
+			}
+			if quality.Blocked || quality.Flagged {
+				t.Errorf("blocked = %v, flagged = %v, want neither", quality.Blocked, quality.Flagged)
+			}
+		})
+	}
+}
+
+func TestCheckQualityContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		issue   string // the rule failed, "" for none
+		blocked bool
+		flagged bool
+	}{
+		{"clean", "Walking distance from the station, 2 parking spots.", "", false, false},
+		{"mobile number", "Call 9876543210 for a visit", RuleContact, false, true},
+		{"mobile with country code", "WhatsApp +91 98765 43210", RuleContact, false, true},
+		{"mobile with trunk prefix", "Call 09876543210", RuleContact, false, true},
+		{"mobile in groups", "Reach me on 987-654-3210.", RuleContact, false, true},
+		{"mobile at the end", "Owner 7012345678", RuleContact, false, true},
+		{"price digits", "Asking Rs 9500000, negotiable", "", false, false},
+		{"long number", "Survey no 987654321012", "", false, false},
+		{"landline-like start", "Plot 1234567890", "", false, false},
+		{"email", "Mail owner.flat@example.com", RuleContact, true, true},
+		{"website", "More photos at www.flatsite.in/powai", RuleLinks, true, true},
+		{"bare domain", "Listed on myflats.co.in too", RuleLinks, true, true},
+		{"profanity", "No bullshit brokers", RuleProfanity, true, true},
+		{"profane stem", "Fucking great view", RuleProfanity, true, true},
+		{"harmless word", "Shitake mushrooms grow in the garden", "", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := goodListing()
+			p.Description += tt.text
+			quality := p.CheckQuality(goodInputs)
+			var want []string
+			if tt.issue != "" {
+				want = []string{tt.issue}
+			}
+			if got := issueRules(quality); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("issues = %v, want %v", got, want)
+			}
+			if quality.Blocked != tt.blocked || quality.Flagged != tt.flagged {
+				t.Errorf("blocked = %v, flagged = %v, want %v, %v", quality.Blocked, quality.Flagged, tt.blocked, tt.flagged)
+			}
+		})
+	}
+}
+
+func TestCheckQualityPriceTypo(t *testing.T) {
+	p := goodListing()
+	p.Price = 1e9
+	quality := p.CheckQuality(goodInputs)
+	if !quality.Blocked || !quality.Flagged {
+		t.Errorf("a price 100 times the median should block the listing, got %+v", quality)
+	}
+}
+
+func issueRules(quality Quality) []string {
+	var rules []string
+	for _, issue := range quality.Issues {
+		rules = append(rules, issue.Rule)
+	}
+	return rules
+}
@@ -0,0 +1,79 @@
+package property
+
+import (
+	"fmt"
+	"time"
+)
+
+// Who a lister prefers to rent to
+const (
+	TenantFamily   = "family"
+	TenantBachelor = "bachelors"
+	TenantCompany  = "company"
+)
+
+// How a rental comes furnished
+const (
+	Unfurnished    = "unfurnished"
+	SemiFurnished  = "semi_furnished"
+	FullyFurnished = "fully_furnished"
+)
+
+// FurnishingItem is one entry of a rental's furnishing inventory
+type FurnishingItem struct {
+	Item     string `json:"item" bson:"item"`
+	Quantity int    `json:"quantity" bson:"quantity"`
+}
+
+// RentalTerms are the lease terms of a rent listing. The listing's Price is its monthly rent.
+type RentalTerms struct {
+	MonthlyRent           float64          `json:"monthlyRent" bson:"monthlyRent"`
+	MaintenanceCharges    float64          `json:"maintenanceCharges,omitempty" bson:"maintenanceCharges,omitempty"` // per month
+	SecurityDepositMonths float64          `json:"securityDepositMonths" bson:"securityDepositMonths"`
+	MinLeaseMonths        int              `json:"minLeaseMonths" bson:"minLeaseMonths"`
+	AvailableFrom         time.Time        `json:"availableFrom" bson:"availableFrom"`
+	PreferredTenants      []string         `json:"preferredTenants,omitempty" bson:"preferredTenants,omitempty"` // empty means anyone
+	PetsAllowed           bool             `json:"petsAllowed" bson:"petsAllowed"`
+	Furnishing            string           `json:"furnishing" bson:"furnishing"`
+	Inventory             []FurnishingItem `json:"inventory,omitempty" bson:"inventory,omitempty"`
+}
+
+// IsValidTenantType reports whether t is a known preferred tenant type
+func IsValidTenantType(t string) bool {
+	return t == TenantFamily || t == TenantBachelor || t == TenantCompany
+}
+
+// IsValidFurnishing reports whether f is a known furnishing level
+func IsValidFurnishing(f string) bool {
+	return f == Unfurnished || f == SemiFurnished || f == FullyFurnished
+}
+
+// Validate checks the amounts, lease term, tenant preferences and furnishing
+func (t RentalTerms) Validate() error {
+	if t.MonthlyRent <= 0 {
+		return fmt.Errorf("monthlyRent must be positive")
+	}
+	if t.MaintenanceCharges < 0 || t.SecurityDepositMonths < 0 || t.SecurityDepositMonths > 12 {
+		return fmt.Errorf("maintenanceCharges can't be negative and securityDepositMonths must be 0 to 12")
+	}
+	if t.MinLeaseMonths < 1 || t.MinLeaseMonths > 120 {
+		return fmt.Errorf("minLeaseMonths must be 1 to 120")
+	}
+	if t.AvailableFrom.IsZero() {
+		return fmt.Errorf("availableFrom is required")
+	}
+	for _, tenant := range t.PreferredTenants {
+		if !IsValidTenantType(tenant) {
+			return fmt.Errorf("preferredTenants must be family, bachelors or company")
+		}
+	}
+	if !IsValidFurnishing(t.Furnishing) {
+		return fmt.Errorf("furnishing must be unfurnished, semi_furnished or fully_furnished")
+	}
+	for _, item := range t.Inventory {
+		if item.Item == "" || item.Quantity < 1 {
+			return fmt.Errorf("every inventory item needs a name and a quantity")
+		}
+	}
+	return nil
+}
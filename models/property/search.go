@@ -0,0 +1,211 @@
+package property
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SearchFilters is the filter set accepted by the listing search
+type SearchFilters struct {
+	Text         string   `json:"text,omitempty" bson:"text,omitempty"` // words matched by the listing text index
+	City         string   `json:"city,omitempty" bson:"city,omitempty"`
+	Locality     string   `json:"locality,omitempty" bson:"locality,omitempty"`
+	PropertyType string   `json:"propertyType,omitempty" bson:"propertyType,omitempty"`
+	ListingType  string   `json:"listingType,omitempty" bson:"listingType,omitempty"`
+	MinPrice     float64  `json:"minPrice,omitempty" bson:"minPrice,omitempty"`
+	MaxPrice     float64  `json:"maxPrice,omitempty" bson:"maxPrice,omitempty"`
+	MinBedrooms  int      `json:"minBedrooms,omitempty" bson:"minBedrooms,omitempty"`
+	Bedrooms     int      `json:"bedrooms,omitempty" bson:"bedrooms,omitempty"` // exactly
+	CityID       string   `json:"cityId,omitempty" bson:"cityId,omitempty"`     // from the locality autocomplete, instead of the names
+	LocalityID   string   `json:"localityId,omitempty" bson:"localityId,omitempty"`
+	AmenityIDs   []string `json:"amenityIds,omitempty" bson:"amenityIds,omitempty"` // listings must have all of them
+	// Shows one listing of each group of likely duplicates
+	CollapseDuplicates bool `json:"collapseDuplicates,omitempty" bson:"collapseDuplicates,omitempty"`
+
+	// Rental filters, only matching rent listings
+	MaxDepositMonths float64 `json:"maxDepositMonths,omitempty" bson:"maxDepositMonths,omitempty"`
+	MaxMaintenance   float64 `json:"maxMaintenance,omitempty" bson:"maxMaintenance,omitempty"`
+	MaxLeaseMonths   int     `json:"maxLeaseMonths,omitempty" bson:"maxLeaseMonths,omitempty"` // minimum lease no longer than this
+	AvailableBy      string  `json:"availableBy,omitempty" bson:"availableBy,omitempty"`       // YYYY-MM-DD
+	Tenant           string  `json:"tenant,omitempty" bson:"tenant,omitempty"`                 // family, bachelors or company
+	PetsAllowed      bool    `json:"petsAllowed,omitempty" bson:"petsAllowed,omitempty"`
+	Furnishing       string  `json:"furnishing,omitempty" bson:"furnishing,omitempty"`
+}
+
+// PriceBuckets are the lower bounds of the price facet buckets by listing type. Prices from the
+// last bound up fall in an open-ended bucket.
+var PriceBuckets = map[string][]float64{
+	"sale": {0, 2500000, 5000000, 10000000, 20000000, 50000000},
+	"rent": {0, 10000, 20000, 40000, 75000, 150000},
+}
+
+// ParseSearchFilters reads search filters from query parameters
+func ParseSearchFilters(query url.Values) (SearchFilters, error) {
+	filters := SearchFilters{
+		City:         strings.TrimSpace(query.Get("city")),
+		Locality:     strings.TrimSpace(query.Get("locality")),
+		PropertyType: strings.TrimSpace(query.Get("propertyType")),
+		ListingType:  strings.TrimSpace(query.Get("listingType")),
+	}
+	var err error
+	for param, id := range map[string]*string{"cityId": &filters.CityID, "localityId": &filters.LocalityID} {
+		if v := query.Get(param); v != "" {
+			if !primitive.IsValidObjectID(v) {
+				return filters, fmt.Errorf("invalid %s", param)
+			}
+			*id = v
+		}
+	}
+	if v := query.Get("amenities"); v != "" {
+		for _, id := range strings.Split(v, ",") {
+			if !primitive.IsValidObjectID(id) {
+				return filters, fmt.Errorf("invalid amenities")
+			}
+			filters.AmenityIDs = append(filters.AmenityIDs, id)
+		}
+	}
+	if v := query.Get("minPrice"); v != "" {
+		if filters.MinPrice, err = strconv.ParseFloat(v, 64); err != nil {
+			return filters, fmt.Errorf("invalid minPrice")
+		}
+	}
+	if v := query.Get("maxPrice"); v != "" {
+		if filters.MaxPrice, err = strconv.ParseFloat(v, 64); err != nil {
+			return filters, fmt.Errorf("invalid maxPrice")
+		}
+	}
+	if v := query.Get("minBedrooms"); v != "" {
+		if filters.MinBedrooms, err = strconv.Atoi(v); err != nil {
+			return filters, fmt.Errorf("invalid minBedrooms")
+		}
+	}
+	if v := query.Get("bedrooms"); v != "" {
+		if filters.Bedrooms, err = strconv.Atoi(v); err != nil {
+			return filters, fmt.Errorf("invalid bedrooms")
+		}
+	}
+	if v := query.Get("maxDepositMonths"); v != "" {
+		if filters.MaxDepositMonths, err = strconv.ParseFloat(v, 64); err != nil {
+			return filters, fmt.Errorf("invalid maxDepositMonths")
+		}
+	}
+	if v := query.Get("maxMaintenance"); v != "" {
+		if filters.MaxMaintenance, err = strconv.ParseFloat(v, 64); err != nil {
+			return filters, fmt.Errorf("invalid maxMaintenance")
+		}
+	}
+	if v := query.Get("maxLeaseMonths"); v != "" {
+		if filters.MaxLeaseMonths, err = strconv.Atoi(v); err != nil {
+			return filters, fmt.Errorf("invalid maxLeaseMonths")
+		}
+	}
+	if v := query.Get("availableBy"); v != "" {
+		if _, err = time.Parse("2006-01-02", v); err != nil {
+			return filters, fmt.Errorf("availableBy must be a YYYY-MM-DD date")
+		}
+		filters.AvailableBy = v
+	}
+	if v := query.Get("tenant"); v != "" {
+		if !IsValidTenantType(v) {
+			return filters, fmt.Errorf("tenant must be family, bachelors or company")
+		}
+		filters.Tenant = v
+	}
+	if v := query.Get("furnishing"); v != "" {
+		if !IsValidFurnishing(v) {
+			return filters, fmt.Errorf("invalid furnishing")
+		}
+		filters.Furnishing = v
+	}
+	filters.PetsAllowed = query.Get("petsAllowed") == "true"
+	filters.CollapseDuplicates = query.Get("collapseDuplicates") == "true"
+	return filters, nil
+}
+
+// Query returns the Mongo filter matching published listings that satisfy the filters
+func (f SearchFilters) Query() bson.M {
+	query := bson.M{"status": Published}
+	if f.Text != "" {
+		query["$text"] = bson.M{"$search": f.Text}
+	}
+	if f.City != "" {
+		query["city"] = f.City
+	}
+	if f.Locality != "" {
+		query["locality"] = f.Locality
+	}
+	if id, err := primitive.ObjectIDFromHex(f.CityID); err == nil {
+		query["cityId"] = id
+	}
+	if id, err := primitive.ObjectIDFromHex(f.LocalityID); err == nil {
+		query["localityId"] = id
+	}
+	if len(f.AmenityIDs) > 0 {
+		ids := bson.A{}
+		for _, v := range f.AmenityIDs {
+			if id, err := primitive.ObjectIDFromHex(v); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		query["amenityIds"] = bson.M{"$all": ids}
+	}
+	if f.PropertyType != "" {
+		query["propertyType"] = f.PropertyType
+	}
+	if f.ListingType != "" {
+		query["listingType"] = f.ListingType
+	}
+	price := bson.M{}
+	if f.MinPrice > 0 {
+		price["$gte"] = f.MinPrice
+	}
+	if f.MaxPrice > 0 {
+		price["$lte"] = f.MaxPrice
+	}
+	if len(price) > 0 {
+		query["price"] = price
+	}
+	if f.MinBedrooms > 0 {
+		query["bedrooms"] = bson.M{"$gte": f.MinBedrooms}
+	}
+	if f.Bedrooms > 0 {
+		query["bedrooms"] = f.Bedrooms
+	}
+	if f.MaxDepositMonths > 0 {
+		query["rental.securityDepositMonths"] = bson.M{"$lte": f.MaxDepositMonths}
+	}
+	if f.MaxMaintenance > 0 {
+		// Listings without maintenance charges have no such field
+		query["rental.maintenanceCharges"] = bson.M{"$not": bson.M{"$gt": f.MaxMaintenance}}
+		query["rental"] = bson.M{"$exists": true}
+	}
+	if f.MaxLeaseMonths > 0 {
+		query["rental.minLeaseMonths"] = bson.M{"$lte": f.MaxLeaseMonths}
+	}
+	if f.AvailableBy != "" {
+		if by, err := time.Parse("2006-01-02", f.AvailableBy); err == nil {
+			query["rental.availableFrom"] = bson.M{"$lte": by.Add(24*time.Hour - time.Nanosecond)}
+		}
+	}
+	if f.Tenant != "" {
+		// No preference means every kind of tenant is welcome
+		query["rental.preferredTenants"] = bson.M{"$in": bson.A{f.Tenant, nil}}
+		query["rental"] = bson.M{"$exists": true}
+	}
+	if f.PetsAllowed {
+		query["rental.petsAllowed"] = true
+	}
+	if f.Furnishing != "" {
+		query["rental.furnishing"] = f.Furnishing
+	}
+	if f.CollapseDuplicates {
+		query["duplicateOf"] = bson.M{"$exists": false}
+	}
+	return query
+}
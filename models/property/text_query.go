@@ -0,0 +1,134 @@
+package property
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TextQuery is a free-text search split into the structured filters it mentions and the words
+// left for the text index
+type TextQuery struct {
+	Terms        []string
+	Bedrooms     int
+	MinPrice     float64
+	MaxPrice     float64
+	ListingType  string
+	PropertyType string
+}
+
+// Multipliers of the amounts people write budgets in
+var amountUnits = map[string]float64{
+	"cr": 1e7, "crs": 1e7, "crore": 1e7, "crores": 1e7,
+	"l": 1e5, "lac": 1e5, "lacs": 1e5, "lakh": 1e5, "lakhs": 1e5,
+	"k": 1e3, "thousand": 1e3,
+}
+
+const (
+	amountUnitPattern = `(cr|crs|crores?|l|lacs?|lakhs?|k|thousand)`
+	amountPattern     = `(\d+(?:\.\d+)?)\s*` + amountUnitPattern + `?\b`
+)
+
+var (
+	bhkPattern      = regexp.MustCompile(`\b(\d+)\s*(?:bhk|bedrooms?|beds?|br)\b`)
+	betweenPattern  = regexp.MustCompile(`\b(?:between\s+)?` + amountPattern + `\s*(?:-|to|and)\s*` + amountPattern)
+	maxPricePattern = regexp.MustCompile(`\b(?:under|below|less than|upto|up to|within|max|budget)\s+(?:rs\.?\s*)?` + amountPattern)
+	minPricePattern = regexp.MustCompile(`\b(?:above|over|more than|min|from|starting)\s+(?:rs\.?\s*)?` + amountPattern)
+	// An amount on its own is only a budget with a unit, "2.5 cr" but not "2"
+	budgetPattern = regexp.MustCompile(`\b(\d+(?:\.\d+)?)\s*` + amountUnitPattern + `\b`)
+)
+
+// Words naming a listing type or property type, by what they mean
+var (
+	listingTypeWords = map[string]string{
+		"rent": "rent", "rental": "rent", "lease": "rent",
+		"sale": "sale", "buy": "sale", "resale": "sale",
+	}
+	propertyTypeWords = map[string]string{
+		"flat": "apartment", "flats": "apartment", "apartment": "apartment", "apartments": "apartment",
+		"villa": "villa", "villas": "villa", "bungalow": "villa",
+		"plot": "plot", "plots": "plot", "land": "plot",
+		"office": "office", "offices": "office",
+	}
+	// Words that only glue the query together
+	fillerWords = map[string]bool{"for": true, "in": true, "at": true, "near": true, "with": true, "a": true, "the": true, "and": true, "of": true}
+)
+
+// ParseTextQuery extracts bedroom count, budget, listing type and property type from a query like
+// "3bhk flat for rent under 50k bandra", leaving the rest as search terms
+func ParseTextQuery(text string) TextQuery {
+	var parsed TextQuery
+	text = strings.ToLower(text)
+	text = strings.NewReplacer("₹", " ", ",", "").Replace(text)
+
+	if m := bhkPattern.FindStringSubmatch(text); m != nil {
+		parsed.Bedrooms, _ = strconv.Atoi(m[1])
+		text = strings.Replace(text, m[0], " ", 1)
+	}
+	if m := betweenPattern.FindStringSubmatch(text); m != nil && (m[2] != "" || m[4] != "") {
+		// "1-2 cr": the unit of the second amount applies to the first too
+		lowUnit := m[2]
+		if lowUnit == "" {
+			lowUnit = m[4]
+		}
+		parsed.MinPrice = amount(m[1], lowUnit)
+		parsed.MaxPrice = amount(m[3], m[4])
+		text = strings.Replace(text, m[0], " ", 1)
+	}
+	if m := maxPricePattern.FindStringSubmatch(text); m != nil {
+		parsed.MaxPrice = amount(m[1], m[2])
+		text = strings.Replace(text, m[0], " ", 1)
+	}
+	if m := minPricePattern.FindStringSubmatch(text); m != nil {
+		parsed.MinPrice = amount(m[1], m[2])
+		text = strings.Replace(text, m[0], " ", 1)
+	}
+	if parsed.MinPrice == 0 && parsed.MaxPrice == 0 {
+		if m := budgetPattern.FindStringSubmatch(text); m != nil {
+			parsed.MaxPrice = amount(m[1], m[2])
+			text = strings.Replace(text, m[0], " ", 1)
+		}
+	}
+
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 127)
+	}) {
+		if listingType, ok := listingTypeWords[word]; ok && parsed.ListingType == "" {
+			parsed.ListingType = listingType
+			continue
+		}
+		if propertyType, ok := propertyTypeWords[word]; ok && parsed.PropertyType == "" {
+			parsed.PropertyType = propertyType
+			continue
+		}
+		if _, err := strconv.Atoi(word); err == nil || fillerWords[word] {
+			continue
+		}
+		parsed.Terms = append(parsed.Terms, word)
+	}
+	return parsed
+}
+
+// ApplyTo fills the filters the query mentions into filters, keeping those already set explicitly
+func (q TextQuery) ApplyTo(filters *SearchFilters) {
+	if filters.Bedrooms == 0 && filters.MinBedrooms == 0 {
+		filters.Bedrooms = q.Bedrooms
+	}
+	if filters.MinPrice == 0 && filters.MaxPrice == 0 {
+		filters.MinPrice, filters.MaxPrice = q.MinPrice, q.MaxPrice
+	}
+	if filters.ListingType == "" {
+		filters.ListingType = q.ListingType
+	}
+	if filters.PropertyType == "" {
+		filters.PropertyType = q.PropertyType
+	}
+}
+
+func amount(number, unit string) float64 {
+	value, _ := strconv.ParseFloat(number, 64)
+	if multiplier, ok := amountUnits[unit]; ok {
+		value *= multiplier
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package property
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTextQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want TextQuery
+	}{
+		{"", TextQuery{}},
+		{"   ", TextQuery{}},
+		{"3bhk sea view bandra", TextQuery{Bedrooms: 3, Terms: []string{"sea", "view", "bandra"}}},
+		{"3bhk flat for rent under 50k bandra", TextQuery{Bedrooms: 3, MaxPrice: 50000, ListingType: "rent", PropertyType: "apartment", Terms: []string{"bandra"}}},
+		{"2 bedrooms villa for sale", TextQuery{Bedrooms: 2, ListingType: "sale", PropertyType: "villa"}},
+		{"2.5 cr", TextQuery{MaxPrice: 2.5e7}},
+		{"50k", TextQuery{MaxPrice: 50000}},
+		{"under 1 lakh", TextQuery{MaxPrice: 1e5}},
+		{"flat under 2 cr", TextQuery{MaxPrice: 2e7, PropertyType: "apartment"}},
+		{"budget ₹2.5 crores", TextQuery{MaxPrice: 2.5e7}},
+		{"above 80 lakhs", TextQuery{MinPrice: 8e6}},
+		{"1-2 cr", TextQuery{MinPrice: 1e7, MaxPrice: 2e7}},
+		{"between 50 lakh and 1.2 cr", TextQuery{MinPrice: 5e6, MaxPrice: 1.2e7}},
+		{"rent 25 to 40 thousand", TextQuery{MinPrice: 25000, MaxPrice: 40000, ListingType: "rent"}},
+		{"under 1,50,000", TextQuery{MaxPrice: 150000}},
+		// Numbers without a unit or keyword aren't prices
+		{"2 powai", TextQuery{Terms: []string{"powai"}}},
+		// Studios are searched for by the word, not read as a bedroom count
+		{"studio", TextQuery{Terms: []string{"studio"}}},
+		{"studio apartment in andheri", TextQuery{PropertyType: "apartment", Terms: []string{"studio", "andheri"}}},
+		// Only the first listing type and property type count, the others stay search terms
+		{"flat rent villa sale", TextQuery{ListingType: "rent", PropertyType: "apartment", Terms: []string{"villa", "sale"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got := ParseTextQuery(tt.text)
+			if len(got.Terms) == 0 {
+				got.Terms = nil
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseTextQuery(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTextQueryApplyTo(t *testing.T) {
+	query := TextQuery{Bedrooms: 3, MinPrice: 1e6, MaxPrice: 2e6, ListingType: "sale", PropertyType: "villa"}
+
+	var empty SearchFilters
+	query.ApplyTo(&empty)
+	if empty.Bedrooms != 3 || empty.MinPrice != 1e6 || empty.MaxPrice != 2e6 || empty.ListingType != "sale" || empty.PropertyType != "villa" {
+		t.Errorf("ApplyTo on empty filters = %+v", empty)
+	}
+
+	explicit := SearchFilters{MinBedrooms: 2, MaxPrice: 5e6, ListingType: "rent", PropertyType: "plot"}
+	query.ApplyTo(&explicit)
+	if explicit.Bedrooms != 0 || explicit.MinPrice != 0 || explicit.MaxPrice != 5e6 || explicit.ListingType != "rent" || explicit.PropertyType != "plot" {
+		t.Errorf("ApplyTo overrode explicit filters: %+v", explicit)
+	}
+}
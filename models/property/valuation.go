@@ -0,0 +1,170 @@
+package property
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Confidence of a price estimate
+const (
+	ConfidenceHigh   = "high"   // many close comparables agreeing on the price
+	ConfidenceMedium = "medium" // a few comparables, or ones that disagree somewhat
+	ConfidenceLow    = "low"    // from area statistics, or comparables far apart
+)
+
+const (
+	MaxComparables          = 10 // most listings a price estimate is based on
+	minValuationComparables = 3  // fewest listings a price estimate is made from
+)
+
+// Valuation is a price estimate for a property, as a range around the most likely price
+type Valuation struct {
+	Estimate     float64      `json:"estimate"`
+	Low          float64      `json:"low"`
+	High         float64      `json:"high"`
+	PricePerSqft float64      `json:"pricePerSqft"`
+	Confidence   string       `json:"confidence"`
+	Basis        string       `json:"basis"` // comparables, or the locality or city statistics
+	Comparables  []Comparable `json:"comparables"`
+}
+
+// Comparable is a listing a price estimate is based on
+type Comparable struct {
+	ID           primitive.ObjectID `json:"_id"`
+	Title        string             `json:"title"`
+	Status       Status             `json:"status"`
+	Locality     string             `json:"locality,omitempty"`
+	Price        float64            `json:"price"`
+	AreaSqft     float64            `json:"areaSqft"`
+	PricePerSqft float64            `json:"pricePerSqft"`
+	Bedrooms     int                `json:"bedrooms"`
+	DistanceKm   *float64           `json:"distanceKm,omitempty"`
+	Similarity   float64            `json:"similarity"` // weight in the estimate, 0 to 1
+}
+
+// Similarity weighs how much a comparable listing tells about the price of p: alike in area,
+// bedrooms and floor, close by and recent count most
+func (p Property) Similarity(c Property, now time.Time) float64 {
+	weight := 1.0
+	if p.AreaSqft > 0 {
+		weight *= math.Max(0, 1-math.Abs(c.AreaSqft-p.AreaSqft)/p.AreaSqft)
+	}
+	if p.Bedrooms > 0 && c.Bedrooms > 0 {
+		weight /= 1 + math.Abs(float64(c.Bedrooms-p.Bedrooms))
+	}
+	if p.Floor != nil && c.Floor != nil {
+		weight /= 1 + math.Abs(float64(*c.Floor-*p.Floor))/10
+	}
+	if p.Location != nil && c.Location != nil {
+		weight /= 1 + p.Location.DistanceTo(*c.Location)/1000
+	} else if !p.LocalityID.IsZero() && c.LocalityID != p.LocalityID {
+		weight /= 2
+	}
+	listed := c.PublishedAt
+	if listed.IsZero() {
+		listed = c.CreatedAt
+	}
+	return weight / (1 + now.Sub(listed).Hours()/24/365)
+}
+
+// Valuate estimates the price of p from the price per square foot of its most similar
+// comparables, weighted by similarity. ok is false when there are too few to go by.
+func (p Property) Valuate(candidates []Property, now time.Time) (valuation Valuation, ok bool) {
+	type weighted struct {
+		listing Property
+		weight  float64
+	}
+	var ranked []weighted
+	for _, c := range candidates {
+		if c.ID == p.ID || c.AreaSqft <= 0 || c.Price <= 0 {
+			continue
+		}
+		if weight := p.Similarity(c, now); weight > 0 {
+			ranked = append(ranked, weighted{c, weight})
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool { return ranked[i].weight > ranked[j].weight })
+	if len(ranked) > MaxComparables {
+		ranked = ranked[:MaxComparables]
+	}
+
+	valuation = Valuation{Basis: "comparables", Comparables: []Comparable{}}
+	prices := make([]weightedPrice, 0, len(ranked))
+	for _, r := range ranked {
+		perSqft := r.listing.Price / r.listing.AreaSqft
+		prices = append(prices, weightedPrice{perSqft, r.weight})
+		comparable := Comparable{
+			ID:           r.listing.ID,
+			Title:        r.listing.Title,
+			Status:       r.listing.Status,
+			Locality:     r.listing.Locality,
+			Price:        r.listing.Price,
+			AreaSqft:     r.listing.AreaSqft,
+			PricePerSqft: math.Round(perSqft),
+			Bedrooms:     r.listing.Bedrooms,
+			Similarity:   math.Round(r.weight*100) / 100,
+		}
+		if p.Location != nil && r.listing.Location != nil {
+			km := math.Round(p.Location.DistanceTo(*r.listing.Location)/100) / 10
+			comparable.DistanceKm = &km
+		}
+		valuation.Comparables = append(valuation.Comparables, comparable)
+	}
+	if len(prices) < minValuationComparables {
+		return valuation, false
+	}
+
+	median, low, high := weightedQuantile(prices, 0.5), weightedQuantile(prices, 0.25), weightedQuantile(prices, 0.75)
+	valuation.SetRange(p.AreaSqft, median, low, high)
+	switch spread := (high - low) / median; {
+	case len(prices) >= 8 && spread <= 0.2:
+		valuation.Confidence = ConfidenceHigh
+	case len(prices) >= 4 && spread <= 0.4:
+		valuation.Confidence = ConfidenceMedium
+	default:
+		valuation.Confidence = ConfidenceLow
+	}
+	return valuation, true
+}
+
+// SetRange sets the estimate and its range for an area from prices per square foot
+func (v *Valuation) SetRange(areaSqft, perSqft, lowPerSqft, highPerSqft float64) {
+	v.PricePerSqft = math.Round(perSqft)
+	v.Estimate = roundPrice(perSqft * areaSqft)
+	v.Low = roundPrice(lowPerSqft * areaSqft)
+	v.High = roundPrice(highPerSqft * areaSqft)
+}
+
+type weightedPrice struct {
+	value, weight float64
+}
+
+// weightedQuantile returns the value below which the share q of the total weight lies
+func weightedQuantile(prices []weightedPrice, q float64) float64 {
+	sorted := append([]weightedPrice(nil), prices...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].value < sorted[j].value })
+	total := 0.0
+	for _, price := range sorted {
+		total += price.weight
+	}
+	cumulative := 0.0
+	for _, price := range sorted {
+		cumulative += price.weight
+		if cumulative >= q*total {
+			return price.value
+		}
+	}
+	return sorted[len(sorted)-1].value
+}
+
+// roundPrice rounds a price to three significant digits, as estimates aren't more precise
+func roundPrice(price float64) float64 {
+	if price <= 0 {
+		return 0
+	}
+	unit := math.Pow(10, math.Floor(math.Log10(price))-2)
+	return math.Round(price/unit) * unit
+}
@@ -0,0 +1,125 @@
+package property
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestWeightedQuantile(t *testing.T) {
+	tests := []struct {
+		name   string
+		prices []weightedPrice
+		q      float64
+		want   float64
+	}{
+		{"single", []weightedPrice{{5, 1}}, 0.5, 5},
+		{"equal weights median", []weightedPrice{{4, 1}, {1, 1}, {3, 1}, {2, 1}}, 0.5, 2},
+		{"equal weights lower quartile", []weightedPrice{{4, 1}, {1, 1}, {3, 1}, {2, 1}}, 0.25, 1},
+		{"equal weights upper quartile", []weightedPrice{{4, 1}, {1, 1}, {3, 1}, {2, 1}}, 0.75, 3},
+		{"heavy outlier", []weightedPrice{{1, 1}, {2, 1}, {10, 5}}, 0.5, 10},
+		{"light outlier", []weightedPrice{{1, 5}, {2, 1}, {10, 1}}, 0.5, 1},
+		{"top", []weightedPrice{{1, 1}, {2, 1}, {3, 1}}, 1, 3},
+		{"no weight", []weightedPrice{{2, 0}, {1, 0}}, 0.5, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := weightedQuantile(tt.prices, tt.q); got != tt.want {
+				t.Errorf("weightedQuantile(%v, %v) = %v, want %v", tt.prices, tt.q, got, tt.want)
+			}
+		})
+	}
+
+	prices := []weightedPrice{{3, 1}, {1, 1}, {2, 1}}
+	weightedQuantile(prices, 0.5)
+	if prices[0].value != 3 || prices[1].value != 1 || prices[2].value != 2 {
+		t.Errorf("weightedQuantile reordered its input: %v", prices)
+	}
+}
+
+func TestValuate(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	subject := Property{ID: primitive.NewObjectID(), AreaSqft: 1000, Bedrooms: 2}
+	comparable := func(perSqft float64) Property {
+		return Property{ID: primitive.NewObjectID(), AreaSqft: 1000, Price: perSqft * 1000, Bedrooms: 2, PublishedAt: now}
+	}
+	comparables := func(perSqft ...float64) []Property {
+		var listings []Property
+		for _, price := range perSqft {
+			listings = append(listings, comparable(price))
+		}
+		return listings
+	}
+
+	tests := []struct {
+		name        string
+		candidates  []Property
+		ok          bool
+		estimate    float64
+		low, high   float64
+		confidence  string
+		comparables int
+	}{
+		{"none", nil, false, 0, 0, 0, "", 0},
+		{"too few", comparables(10000, 11000), false, 0, 0, 0, "", 2},
+		{"few", comparables(10000, 10000, 10000), true, 1e7, 1e7, 1e7, ConfidenceLow, 3},
+		{"some agreeing", comparables(9000, 10000, 10000, 11000), true, 1e7, 9e6, 1e7, ConfidenceMedium, 4},
+		{"many agreeing", comparables(9500, 10000, 10000, 10000, 10000, 10000, 10000, 10500), true, 1e7, 1e7, 1e7, ConfidenceHigh, 8},
+		{"many disagreeing", comparables(5000, 7000, 9000, 10000, 11000, 13000, 15000, 20000), true, 1e7, 7e6, 1.3e7, ConfidenceLow, 8},
+		{"rounded", comparables(12345.6, 12345.6, 12345.6), true, 1.23e7, 1.23e7, 1.23e7, ConfidenceLow, 3},
+		{"capped", comparables(10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000, 10000), true, 1e7, 1e7, 1e7, ConfidenceHigh, MaxComparables},
+		{"unusable skipped", append(comparables(10000, 10000), subject, Property{ID: primitive.NewObjectID(), Price: 1e7}, Property{ID: primitive.NewObjectID(), AreaSqft: 1000}), false, 0, 0, 0, "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valuation, ok := subject.Valuate(tt.candidates, now)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if len(valuation.Comparables) != tt.comparables {
+				t.Errorf("%d comparables, want %d", len(valuation.Comparables), tt.comparables)
+			}
+			if !ok {
+				return
+			}
+			if valuation.Estimate != tt.estimate || valuation.Low != tt.low || valuation.High != tt.high {
+				t.Errorf("estimate %v (%v to %v), want %v (%v to %v)", valuation.Estimate, valuation.Low, valuation.High, tt.estimate, tt.low, tt.high)
+			}
+			if valuation.Confidence != tt.confidence {
+				t.Errorf("confidence %q, want %q", valuation.Confidence, tt.confidence)
+			}
+		})
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	subject := Property{AreaSqft: 1000, Bedrooms: 2}
+	alike := Property{AreaSqft: 1000, Bedrooms: 2, PublishedAt: now}
+	if weight := subject.Similarity(alike, now); weight != 1 {
+		t.Errorf("an identical new listing weighs %v, want 1", weight)
+	}
+
+	tests := []struct {
+		name string
+		edit func(c *Property)
+	}{
+		{"larger", func(c *Property) { c.AreaSqft = 1200 }},
+		{"more bedrooms", func(c *Property) { c.Bedrooms = 3 }},
+		{"older", func(c *Property) { c.PublishedAt = now.AddDate(-1, 0, 0) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := alike
+			tt.edit(&c)
+			if weight := subject.Similarity(c, now); weight >= 1 || weight <= 0 {
+				t.Errorf("weight %v, want between 0 and 1", weight)
+			}
+		})
+	}
+
+	if weight := subject.Similarity(Property{AreaSqft: 2500, Bedrooms: 2, PublishedAt: now}, now); weight != 0 {
+		t.Errorf("a listing over twice the area weighs %v, want 0", weight)
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"PropertyAppBackend/models/property"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// How often a saved search sends new-match alerts
+const (
+	AlertInstant = "instant"
+	AlertDaily   = "daily"
+	AlertWeekly  = "weekly"
+)
+
+// AlertInterval returns the minimum time between two alerts of a frequency, and false for unknown ones
+func AlertInterval(frequency string) (time.Duration, bool) {
+	switch frequency {
+	case AlertInstant:
+		return 0, true
+	case AlertDaily:
+		return 24 * time.Hour, true
+	case AlertWeekly:
+		return 7 * 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// SavedSearch is a named listing search a user gets alerted about when new listings match it
+type SavedSearch struct {
+	ID             primitive.ObjectID     `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserID         primitive.ObjectID     `json:"userId" bson:"userId"`
+	Name           string                 `json:"name" bson:"name"`
+	Filters        property.SearchFilters `json:"filters" bson:"filters"`
+	Frequency      string                 `json:"frequency" bson:"frequency"`
+	AlertsEnabled  bool                   `json:"alertsEnabled" bson:"alertsEnabled"`
+	LastCheckedAt  time.Time              `json:"lastCheckedAt" bson:"lastCheckedAt"` // listings published after this are new
+	LastNotifiedAt time.Time              `json:"lastNotifiedAt,omitempty" bson:"lastNotifiedAt,omitempty"`
+	LastDigestAt   time.Time              `json:"lastDigestAt,omitempty" bson:"lastDigestAt,omitempty"` // start of the current digest window
+	CreatedAt      time.Time              `json:"createdAt" bson:"createdAt"`
+}
@@ -0,0 +1,157 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Site visit states. A requested visit waits for the party that didn't pick the time to confirm it.
+const (
+	VisitRequested = "requested"
+	VisitConfirmed = "confirmed"
+	VisitCancelled = "cancelled"
+)
+
+// Visit lengths a lister may offer, in minutes
+const (
+	MinVisitSlotMinutes = 15
+	MaxVisitSlotMinutes = 240
+)
+
+// AvailabilityWindow is a weekly time range in which visits can be booked, "HH:MM" in the availability's time zone
+type AvailabilityWindow struct {
+	Weekday time.Weekday `json:"weekday" bson:"weekday"` // 0 is Sunday
+	Start   string       `json:"start" bson:"start"`
+	End     string       `json:"end" bson:"end"`
+}
+
+// VisitAvailability is when a listing can be visited: weekly windows cut into fixed slots,
+// except on blackout dates
+type VisitAvailability struct {
+	ID            primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID    primitive.ObjectID   `json:"propertyId" bson:"propertyId"`
+	OwnerID       primitive.ObjectID   `json:"ownerId" bson:"ownerId"`
+	Windows       []AvailabilityWindow `json:"windows" bson:"windows"`
+	SlotMinutes   int                  `json:"slotMinutes" bson:"slotMinutes"`
+	BlackoutDates []string             `json:"blackoutDates,omitempty" bson:"blackoutDates,omitempty"` // YYYY-MM-DD
+	TimeZone      string               `json:"timeZone" bson:"timeZone"`
+	UpdatedAt     time.Time            `json:"updatedAt" bson:"updatedAt"`
+}
+
+// clockMinutes parses "HH:MM" into minutes after midnight
+func clockMinutes(value string) (int, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(value, "%d:%d", &hours, &minutes); err != nil || hours < 0 || hours > 24 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return hours*60 + minutes, nil
+}
+
+// Validate checks the windows, slot length, blackout dates and time zone
+func (a VisitAvailability) Validate() error {
+	if a.SlotMinutes < MinVisitSlotMinutes || a.SlotMinutes > MaxVisitSlotMinutes {
+		return fmt.Errorf("slotMinutes must be between %d and %d", MinVisitSlotMinutes, MaxVisitSlotMinutes)
+	}
+	if _, err := time.LoadLocation(a.TimeZone); err != nil {
+		return fmt.Errorf("unknown time zone %q", a.TimeZone)
+	}
+	for _, window := range a.Windows {
+		if window.Weekday < time.Sunday || window.Weekday > time.Saturday {
+			return fmt.Errorf("weekday must be 0 (Sunday) to 6 (Saturday)")
+		}
+		start, err := clockMinutes(window.Start)
+		if err != nil {
+			return err
+		}
+		end, err := clockMinutes(window.End)
+		if err != nil {
+			return err
+		}
+		if end-start < a.SlotMinutes {
+			return fmt.Errorf("window %s-%s is shorter than one slot", window.Start, window.End)
+		}
+	}
+	for _, date := range a.BlackoutDates {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return fmt.Errorf("invalid blackout date %q, expected YYYY-MM-DD", date)
+		}
+	}
+	return nil
+}
+
+// blackedOut reports whether the calendar day of t is a blackout date
+func (a VisitAvailability) blackedOut(t time.Time) bool {
+	day := t.Format("2006-01-02")
+	for _, date := range a.BlackoutDates {
+		if date == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Slots returns the start of every slot in [from, to), ignoring bookings
+func (a VisitAvailability) Slots(from, to time.Time) []time.Time {
+	loc, err := time.LoadLocation(a.TimeZone)
+	if err != nil {
+		return nil
+	}
+	var slots []time.Time
+	from, to = from.In(loc), to.In(loc)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		if a.blackedOut(day) {
+			continue
+		}
+		for _, window := range a.Windows {
+			if window.Weekday != day.Weekday() {
+				continue
+			}
+			start, _ := clockMinutes(window.Start)
+			end, _ := clockMinutes(window.End)
+			for minute := start; minute+a.SlotMinutes <= end; minute += a.SlotMinutes {
+				// Wall clock time, so days the clocks change on keep their slots in place
+				t := time.Date(day.Year(), day.Month(), day.Day(), 0, minute, 0, 0, loc)
+				if !t.Before(from) && t.Before(to) {
+					slots = append(slots, t)
+				}
+			}
+		}
+	}
+	return slots
+}
+
+// IsSlot reports whether a visit may start at t: on a slot boundary of a window, off blackout dates
+func (a VisitAvailability) IsSlot(t time.Time) bool {
+	for _, slot := range a.Slots(t, t.Add(time.Minute)) {
+		if slot.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// SiteVisit is a buyer's booked viewing of a listing
+type SiteVisit struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	PropertyID     primitive.ObjectID `json:"propertyId" bson:"propertyId"`
+	OwnerID        primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	BuyerID        primitive.ObjectID `json:"buyerId" bson:"buyerId"`
+	Start          time.Time          `json:"start" bson:"start"`
+	End            time.Time          `json:"end" bson:"end"`
+	Status         string             `json:"status" bson:"status"`
+	RequestedBy    primitive.ObjectID `json:"requestedBy" bson:"requestedBy"` // who picked the current time
+	SlotKey        string             `json:"-" bson:"slotKey,omitempty"`     // unique while the visit holds its slot
+	CancelledBy    primitive.ObjectID `json:"cancelledBy,omitempty" bson:"cancelledBy,omitempty"`
+	CancelReason   string             `json:"cancelReason,omitempty" bson:"cancelReason,omitempty"`
+	ReminderSentAt time.Time          `json:"reminderSentAt,omitempty" bson:"reminderSentAt,omitempty"`
+	Sequence       int                `json:"-" bson:"sequence"` // bumped on every change, for calendar apps
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+	UpdatedAt      time.Time          `json:"updatedAt" bson:"updatedAt"`
+}
+
+// VisitSlotKey identifies a listing's slot; a unique index on it keeps a slot from being booked twice
+func VisitSlotKey(propertyID primitive.ObjectID, start time.Time) string {
+	return propertyID.Hex() + "@" + start.UTC().Format(time.RFC3339)
+}
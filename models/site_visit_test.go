@@ -0,0 +1,87 @@
+package models
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // the test time zones, wherever the tests run
+)
+
+func TestVisitAvailabilitySlots(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	london, _ := time.LoadLocation("Europe/London")
+	// 2026-06-01 is a Monday
+	monday := time.Date(2026, 6, 1, 0, 0, 0, 0, kolkata)
+	at := func(day time.Time, hour, minute int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location())
+	}
+	weekdayMornings := VisitAvailability{
+		Windows: []AvailabilityWindow{
+			{Weekday: time.Monday, Start: "10:00", End: "11:30"},
+			{Weekday: time.Wednesday, Start: "09:00", End: "10:00"},
+		},
+		SlotMinutes: 30,
+		TimeZone:    "Asia/Kolkata",
+	}
+
+	tests := []struct {
+		name         string
+		availability VisitAvailability
+		from, to     time.Time
+		want         []time.Time
+	}{
+		{"no windows", VisitAvailability{SlotMinutes: 30, TimeZone: "Asia/Kolkata"}, monday, monday.AddDate(0, 0, 7), nil},
+		{"unknown time zone", VisitAvailability{Windows: weekdayMornings.Windows, SlotMinutes: 30, TimeZone: "Mars/Olympus"}, monday, monday.AddDate(0, 0, 7), nil},
+		{"empty range", weekdayMornings, monday, monday, nil},
+		{"one week", weekdayMornings, monday, monday.AddDate(0, 0, 7), []time.Time{
+			at(monday, 10, 0), at(monday, 10, 30), at(monday, 11, 0),
+			at(monday.AddDate(0, 0, 2), 9, 0), at(monday.AddDate(0, 0, 2), 9, 30),
+		}},
+		{"from mid window", weekdayMornings, at(monday, 10, 15), at(monday, 23, 0), []time.Time{at(monday, 10, 30), at(monday, 11, 0)}},
+		{"to is exclusive", weekdayMornings, monday, at(monday, 11, 0), []time.Time{at(monday, 10, 0), at(monday, 10, 30)}},
+		{"range in another zone", weekdayMornings, at(monday, 10, 0).UTC(), at(monday, 10, 1).UTC(), []time.Time{at(monday, 10, 0)}},
+		{"blackout date", VisitAvailability{Windows: weekdayMornings.Windows, SlotMinutes: 30, TimeZone: "Asia/Kolkata", BlackoutDates: []string{"2026-06-01"}},
+			monday, monday.AddDate(0, 0, 7), []time.Time{at(monday.AddDate(0, 0, 2), 9, 0), at(monday.AddDate(0, 0, 2), 9, 30)}},
+		{"slot longer than what is left", VisitAvailability{Windows: []AvailabilityWindow{{Weekday: time.Monday, Start: "10:00", End: "11:30"}}, SlotMinutes: 60, TimeZone: "Asia/Kolkata"},
+			monday, monday.AddDate(0, 0, 1), []time.Time{at(monday, 10, 0)}},
+		// Clocks in London go forward an hour at 01:00 on 2026-03-29, a Sunday
+		{"daylight saving day", VisitAvailability{Windows: []AvailabilityWindow{{Weekday: time.Sunday, Start: "10:00", End: "11:00"}}, SlotMinutes: 30, TimeZone: "Europe/London"},
+			time.Date(2026, 3, 29, 0, 0, 0, 0, london), time.Date(2026, 3, 30, 0, 0, 0, 0, london),
+			[]time.Time{time.Date(2026, 3, 29, 10, 0, 0, 0, london), time.Date(2026, 3, 29, 10, 30, 0, 0, london)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.availability.Slots(tt.from, tt.to)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Slots = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("slot %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVisitAvailabilityIsSlot(t *testing.T) {
+	availability := VisitAvailability{
+		Windows:     []AvailabilityWindow{{Weekday: time.Monday, Start: "10:00", End: "11:00"}},
+		SlotMinutes: 30,
+		TimeZone:    "Asia/Kolkata",
+	}
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	tests := []struct {
+		t    time.Time
+		want bool
+	}{
+		{time.Date(2026, 6, 1, 10, 30, 0, 0, kolkata), true},
+		{time.Date(2026, 6, 1, 10, 15, 0, 0, kolkata), false},
+		{time.Date(2026, 6, 1, 11, 0, 0, 0, kolkata), false},
+		{time.Date(2026, 6, 2, 10, 0, 0, 0, kolkata), false},
+	}
+	for _, tt := range tests {
+		if got := availability.IsSlot(tt.t); got != tt.want {
+			t.Errorf("IsSlot(%v) = %v, want %v", tt.t, got, tt.want)
+		}
+	}
+}
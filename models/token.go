@@ -12,9 +12,10 @@ type RefreshRequest struct {
 
 // RefreshResponse is the response for a successful token refresh
 type RefreshResponse struct {
-	Message     string `json:"message"`
-	AccessToken string `json:"accessToken"`
-	User        User   `json:"user"`
+	Message      string `json:"message"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	User         User   `json:"user"`
 }
 
 type RefreshToken struct {
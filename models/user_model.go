@@ -14,6 +14,119 @@ const (
     RegularUser Role = "user"
 )
 
+// UserType is what a regular user mainly uses the app for
+type UserType string
+
+const (
+	Buyer   UserType = "buyer"
+	Tenant  UserType = "tenant"
+	Owner   UserType = "owner"   // individual owner listing their own property
+	Agent   UserType = "agent"   // broker listing on behalf of owners
+	Builder UserType = "builder" // developer listing new construction
+	Seller  UserType = "seller"  // chosen before owners, agents and builders were told apart; listed like owners
+)
+
+// IsValidUserType reports whether t is a known user type
+func IsValidUserType(t UserType) bool {
+	switch t {
+	case Buyer, Tenant, Owner, Agent, Builder:
+		return true
+	}
+	return false
+}
+
+// IsProfessional reports whether the user type lists property as a business and can be verified
+func IsProfessional(t UserType) bool {
+	return t == Agent || t == Builder
+}
+
+// Active listings each user type may have at once. Agents and builders get their
+// higher limit only once verified; until then they are limited like owners.
+var activeListingLimits = map[UserType]int{
+	Buyer:   0,
+	Tenant:  0,
+	Owner:   3,
+	Agent:   50,
+	Builder: 200,
+}
+
+// Days a listing stays published before it expires, by user type
+var listingValidityDays = map[UserType]int{
+	Owner:   30,
+	Agent:   60,
+	Builder: 90,
+}
+
+// Times a day a user may refresh their listings to the top of search, by user type
+var dailyRefreshLimits = map[UserType]int{
+	Owner:   1,
+	Agent:   10,
+	Builder: 20,
+}
+
+// listingPlan returns the user type whose listing allowances apply to the user. Sellers, and
+// users with no or an unknown type, list like owners.
+func listingPlan(user User) UserType {
+	userType := user.UserType
+	if _, ok := activeListingLimits[userType]; !ok {
+		return Owner
+	}
+	if IsProfessional(userType) && !user.IsVerified() {
+		return Owner
+	}
+	return userType
+}
+
+// ActiveListingLimit returns how many active listings the user may have
+func ActiveListingLimit(user User) int {
+	return activeListingLimits[listingPlan(user)]
+}
+
+// ListingValidityDays returns how many days the user's listings stay published before expiring
+func ListingValidityDays(user User) int {
+	if days, ok := listingValidityDays[listingPlan(user)]; ok {
+		return days
+	}
+	return listingValidityDays[Owner]
+}
+
+// DailyRefreshLimit returns how many times a day the user may refresh their listings
+func DailyRefreshLimit(user User) int {
+	return dailyRefreshLimits[listingPlan(user)]
+}
+
+// Verification states of a professional profile
+const (
+	VerificationNone     = ""
+	VerificationPending  = "pending"
+	VerificationVerified = "verified"
+	VerificationRejected = "rejected"
+)
+
+// ProfessionalProfile is the business profile of an agent or builder
+type ProfessionalProfile struct {
+	AgencyName   string   `json:"agencyName" bson:"agencyName"` // agency for agents, company for builders
+	RERANumber   string   `json:"reraNumber" bson:"reraNumber"`
+	ServiceAreas []string `json:"serviceAreas,omitempty" bson:"serviceAreas,omitempty"`
+}
+
+// VerificationDocument is a file uploaded to prove a professional profile, kept out of public storage
+type VerificationDocument struct {
+	Kind       string    `json:"kind" bson:"kind"` // rera_certificate, id_proof, ...
+	Path       string    `json:"-" bson:"path"`
+	UploadedAt time.Time `json:"uploadedAt" bson:"uploadedAt"`
+}
+
+// Verification tracks the review of a professional profile
+type Verification struct {
+	Status          string                 `json:"status" bson:"status"`
+	Documents       []VerificationDocument `json:"documents,omitempty" bson:"documents,omitempty"`
+	SubmittedAt     time.Time              `json:"submittedAt,omitempty" bson:"submittedAt,omitempty"`
+	ReviewedBy      primitive.ObjectID     `json:"reviewedBy,omitempty" bson:"reviewedBy,omitempty"`
+	ReviewedAt      time.Time              `json:"reviewedAt,omitempty" bson:"reviewedAt,omitempty"`
+	RejectionReason string                 `json:"rejectionReason,omitempty" bson:"rejectionReason,omitempty"`
+}
+
 type User struct {
     ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
     Name        string             `json:"name" bson:"name"`
@@ -23,13 +136,36 @@ type User struct {
     Role        Role               `json:"role" bson:"role,omitempty"`
     CreatedAt   time.Time          `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
 	CreatedBy  primitive.ObjectID `json:"createdBy,omitempty" bson:"createdBy,omitempty"`
+	Permissions []Permission `json:"permissions,omitempty" bson:"permissions"` // staff only, the role's defaults when unset; an empty list holds none
+	Scope       *StaffScope  `json:"scope,omitempty" bson:"scope,omitempty"`             // staff only, cities/localities they handle
+	Email             string    `json:"email,omitempty" bson:"email,omitempty"`
+	AvatarURL         string    `json:"avatarUrl,omitempty" bson:"avatarUrl,omitempty"`
+	PreferredLanguage string    `json:"preferredLanguage,omitempty" bson:"preferredLanguage,omitempty"`
+	City              string    `json:"city,omitempty" bson:"city,omitempty"`
+	UserType          UserType  `json:"userType,omitempty" bson:"userType,omitempty"`
+	UpdatedAt         time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+	DeletionScheduledFor time.Time `json:"deletionScheduledFor,omitempty" bson:"deletionScheduledFor,omitempty"` // set while a deletion request is in its grace period
+	DeletedAt            time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`                       // set once the account was anonymized
+	Professional         *ProfessionalProfile `json:"professional,omitempty" bson:"professional,omitempty"` // agents and builders only
+	Verification         *Verification        `json:"verification,omitempty" bson:"verification,omitempty"`
+	AlertsUnsubscribed   bool                 `json:"alertsUnsubscribed,omitempty" bson:"alertsUnsubscribed,omitempty"` // opted out of every saved-search alert
+}
+
+// IsVerified reports whether the user's professional profile was verified by staff
+func (u User) IsVerified() bool {
+	return u.Verification != nil && u.Verification.Status == VerificationVerified
 }
 
+// OTP purposes other than login/sign-up, which is stored without a purpose
+const OTPPurposeChangePhone = "change_phone"
+
 //OTPRecord
 type OTPRecord struct {
 	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
 	PhoneNumber string             `json:"phoneNumber" bson:"phoneNumber"`
 	OTP         string             `json:"otp" bson:"otp"`
+	Purpose     string             `json:"purpose,omitempty" bson:"purpose,omitempty"`
+	UserID      primitive.ObjectID `json:"userId,omitempty" bson:"userId,omitempty"` // account the OTP was requested from, for non-login purposes
 	ExpiresAt   time.Time          `json:"expiresAt" bson:"expiresAt"`
 	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
 }
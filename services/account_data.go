@@ -0,0 +1,385 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// exportSection is one JSON file of a personal data export
+type exportSection struct {
+	File    string
+	Collect func(ctx context.Context, userID primitive.ObjectID) (interface{}, error)
+}
+
+// findAll decodes every document matching filter into a fresh slice of T
+func findAll[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, opts ...*options.FindOptions) ([]T, error) {
+	cursor, err := collection.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	results := []T{}
+	if err = cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// exportSections lists everything we hold about a user. Add a section here when a new
+// collection stores user data.
+var exportSections = []exportSection{
+	{"profile.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		var user models.User
+		err := database.GetUserCollection().FindOne(ctx, bson.M{"_id": userID}, options.FindOne().SetProjection(bson.M{"password": 0})).Decode(&user)
+		return user, err
+	}},
+	{"listings.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[property.Property](ctx, database.GetPropertyCollection(), bson.M{"ownerId": userID})
+	}},
+	{"favorites.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.Favorite](ctx, database.GetFavoriteCollection(), bson.M{"userId": userID})
+	}},
+	{"inquiries.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.Lead](ctx, database.GetLeadCollection(), bson.M{"buyerId": userID}, options.Find().SetProjection(bson.M{"notes": 0, "followUpAt": 0}))
+	}},
+	{"leads.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.Lead](ctx, database.GetLeadCollection(), bson.M{"ownerId": userID})
+	}},
+	{"site_visits.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.SiteVisit](ctx, database.GetSiteVisitCollection(), bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}})
+	}},
+	{"conversations.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.Conversation](ctx, database.GetConversationCollection(), bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}})
+	}},
+	{"messages_sent.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.Message](ctx, database.GetMessageCollection(), bson.M{"senderId": userID})
+	}},
+	{"blocked_users.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.UserBlock](ctx, database.GetUserBlockCollection(), bson.M{"blockerId": userID})
+	}},
+	{"contact_reveals.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.ContactReveal](ctx, database.GetContactRevealCollection(), bson.M{"buyerId": userID})
+	}},
+	{"contact_reveal_counters.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.ContactRevealCounter](ctx, database.GetContactRevealCounterCollection(), bson.M{"buyerId": userID})
+	}},
+	{"listing_reports.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.ListingReport](ctx, database.GetListingReportCollection(), bson.M{"reporterId": userID})
+	}},
+	{"projects.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.Project](ctx, database.GetProjectCollection(), bson.M{"builderId": userID})
+	}},
+	{"listing_history.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		listingIDs, err := database.GetPropertyCollection().Distinct(ctx, "_id", bson.M{"ownerId": userID})
+		if err != nil {
+			return nil, err
+		}
+		return findAll[models.ListingVersion](ctx, database.GetListingVersionCollection(), bson.M{"propertyId": bson.M{"$in": listingIDs}})
+	}},
+	{"listing_refreshes.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.ListingRefresh](ctx, database.GetListingRefreshCollection(), bson.M{"ownerId": userID})
+	}},
+	{"listing_refresh_counters.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.ListingRefreshCounter](ctx, database.GetListingRefreshCounterCollection(), bson.M{"ownerId": userID})
+	}},
+	{"saved_searches.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.SavedSearch](ctx, database.GetSavedSearchCollection(), bson.M{"userId": userID})
+	}},
+	{"notifications.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.Notification](ctx, database.GetNotificationCollection(), bson.M{"userId": userID})
+	}},
+	{"sessions.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		// The token strings themselves are credentials and stay out of the export
+		return findAll[models.RefreshToken](ctx, database.GetRefreshTokenCollection(), bson.M{"userId": userID}, options.Find().SetProjection(bson.M{"token": 0}))
+	}},
+	{"account_activity.json", func(ctx context.Context, userID primitive.ObjectID) (interface{}, error) {
+		return findAll[models.AuditLog](ctx, database.GetAuditLogCollection(), bson.M{"subjectId": userID})
+	}},
+}
+
+// BuildDataExport writes the ZIP for a pending export request and marks it ready, or failed
+func BuildDataExport(ctx context.Context, export models.DataExport) {
+	cfg := config.GetCachedConfig()
+	collection := database.GetDataExportCollection()
+
+	filePath, err := writeExportZip(ctx, cfg.ExportDir, export)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to build data export ", export.ID.Hex())
+		collection.UpdateOne(ctx, bson.M{"_id": export.ID}, bson.M{"$set": bson.M{"status": models.ExportFailed, "error": "Export could not be generated"}})
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(cfg.DataExportRetentionHours) * time.Hour)
+	_, err = collection.UpdateOne(ctx, bson.M{"_id": export.ID}, bson.M{"$set": bson.M{"status": models.ExportReady, "filePath": filePath, "expiresAt": expiresAt}})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to mark data export ready")
+		os.Remove(filePath)
+		return
+	}
+
+	var user models.User
+	if err = database.GetUserCollection().FindOne(ctx, bson.M{"_id": export.UserID}).Decode(&user); err == nil {
+		message := fmt.Sprintf("Your data export is ready to download in the app. It will be available for %d hours.", cfg.DataExportRetentionHours)
+		if err = NotifyUser(user, message); err != nil {
+			logrus.WithError(err).Warn("Failed to notify user about data export")
+		}
+	}
+}
+
+func writeExportZip(ctx context.Context, dir string, export models.DataExport) (string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create export folder: %w", err)
+	}
+	filePath := filepath.Join(dir, export.ID.Hex()+".zip")
+	file, err := os.OpenFile(filePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", fmt.Errorf("failed to create export file: %w", err)
+	}
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	for _, section := range exportSections {
+		data, err := section.Collect(ctx, export.UserID)
+		if err != nil {
+			os.Remove(filePath)
+			return "", fmt.Errorf("failed to collect %s: %w", section.File, err)
+		}
+		entry, err := archive.Create(section.File)
+		if err != nil {
+			os.Remove(filePath)
+			return "", fmt.Errorf("failed to add %s: %w", section.File, err)
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err = encoder.Encode(data); err != nil {
+			os.Remove(filePath)
+			return "", fmt.Errorf("failed to write %s: %w", section.File, err)
+		}
+	}
+	if err = archive.Close(); err != nil {
+		os.Remove(filePath)
+		return "", fmt.Errorf("failed to finish export archive: %w", err)
+	}
+	return filePath, nil
+}
+
+// PurgeExpiredExports deletes export files past their retention period
+func PurgeExpiredExports(ctx context.Context) error {
+	collection := database.GetDataExportCollection()
+	expired, err := findAll[models.DataExport](ctx, collection, bson.M{"expiresAt": bson.M{"$lte": time.Now()}})
+	if err != nil {
+		return fmt.Errorf("failed to load expired exports: %w", err)
+	}
+	for _, export := range expired {
+		if export.FilePath != "" {
+			if err = os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+				logrus.WithError(err).Warn("Failed to delete export file ", export.FilePath)
+				continue
+			}
+		}
+		collection.DeleteOne(ctx, bson.M{"_id": export.ID})
+	}
+	return nil
+}
+
+// accountPurgeSteps remove or anonymize a deleted user's data outside the users collection.
+// Add a step here when a new collection stores user data.
+var accountPurgeSteps = []func(ctx context.Context, userID primitive.ObjectID) error{
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetPropertyCollection().UpdateMany(ctx,
+			bson.M{"ownerId": userID, "status": bson.M{"$ne": property.Archived}},
+			bson.M{"$set": bson.M{"status": property.Archived, "updatedAt": time.Now()}})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetRefreshTokenCollection().DeleteMany(ctx, bson.M{"userId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		favorites, err := findAll[models.Favorite](ctx, database.GetFavoriteCollection(), bson.M{"userId": userID})
+		if err != nil {
+			return err
+		}
+		for _, favorite := range favorites {
+			database.GetPropertyCollection().UpdateOne(ctx, bson.M{"_id": favorite.PropertyID, "favoriteCount": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"favoriteCount": -1}})
+		}
+		_, err = database.GetFavoriteCollection().DeleteMany(ctx, bson.M{"userId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetOTPCollection().DeleteMany(ctx, bson.M{"userId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetLeadCollection().DeleteMany(ctx, bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetSiteVisitCollection().DeleteMany(ctx, bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}})
+		if err == nil {
+			_, err = database.GetVisitAvailabilityCollection().DeleteMany(ctx, bson.M{"ownerId": userID})
+		}
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		conversationIDs, err := database.GetConversationCollection().Distinct(ctx, "_id", bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}})
+		if err != nil {
+			return err
+		}
+		messages, err := findAll[models.Message](ctx, database.GetMessageCollection(), bson.M{"conversationId": bson.M{"$in": conversationIDs}, "images": bson.M{"$exists": true}})
+		if err != nil {
+			return err
+		}
+		for _, message := range messages {
+			for _, image := range message.Images {
+				// Images sent before attachments were kept private
+				if strings.HasPrefix(image, UploadURLPrefix) {
+					DeleteUpload(image)
+				}
+			}
+		}
+		for _, id := range conversationIDs {
+			if id, ok := id.(primitive.ObjectID); ok {
+				os.RemoveAll(PrivateFilePath("messages/" + id.Hex()))
+			}
+		}
+		if _, err = database.GetMessageCollection().DeleteMany(ctx, bson.M{"conversationId": bson.M{"$in": conversationIDs}}); err != nil {
+			return err
+		}
+		if _, err = database.GetConversationCollection().DeleteMany(ctx, bson.M{"_id": bson.M{"$in": conversationIDs}}); err != nil {
+			return err
+		}
+		_, err = database.GetUserBlockCollection().DeleteMany(ctx, bson.M{"$or": []bson.M{{"blockerId": userID}, {"blockedId": userID}}})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetContactRevealCollection().DeleteMany(ctx, bson.M{"$or": []bson.M{{"buyerId": userID}, {"ownerId": userID}}})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetContactRevealCounterCollection().DeleteMany(ctx, bson.M{"buyerId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetListingReportCollection().DeleteMany(ctx, bson.M{"reporterId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		// Their units were archived with the rest of the builder's listings
+		_, err := database.GetProjectCollection().DeleteMany(ctx, bson.M{"builderId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		listingIDs, err := database.GetPropertyCollection().Distinct(ctx, "_id", bson.M{"ownerId": userID})
+		if err != nil {
+			return err
+		}
+		_, err = database.GetListingVersionCollection().DeleteMany(ctx, bson.M{"propertyId": bson.M{"$in": listingIDs}})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetListingRefreshCollection().DeleteMany(ctx, bson.M{"ownerId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetListingRefreshCounterCollection().DeleteMany(ctx, bson.M{"ownerId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetSavedSearchCollection().DeleteMany(ctx, bson.M{"userId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		_, err := database.GetNotificationCollection().DeleteMany(ctx, bson.M{"userId": userID})
+		return err
+	},
+	func(ctx context.Context, userID primitive.ObjectID) error {
+		exports, err := findAll[models.DataExport](ctx, database.GetDataExportCollection(), bson.M{"userId": userID})
+		if err != nil {
+			return err
+		}
+		for _, export := range exports {
+			if export.FilePath != "" {
+				os.Remove(export.FilePath)
+			}
+		}
+		_, err = database.GetDataExportCollection().DeleteMany(ctx, bson.M{"userId": userID})
+		return err
+	},
+}
+
+// PurgeDeletedAccounts anonymizes every account whose deletion grace period is over
+func PurgeDeletedAccounts(ctx context.Context) error {
+	users, err := findAll[models.User](ctx, database.GetUserCollection(), bson.M{
+		"deletionScheduledFor": bson.M{"$lte": time.Now()},
+		"deletedAt":            bson.M{"$exists": false},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load accounts due for deletion: %w", err)
+	}
+	for _, user := range users {
+		if err = anonymizeAccount(ctx, user); err != nil {
+			logrus.WithError(err).Error("Failed to delete account ", user.ID.Hex())
+		}
+	}
+	return nil
+}
+
+func anonymizeAccount(ctx context.Context, user models.User) error {
+	for _, step := range accountPurgeSteps {
+		if err := step(ctx, user.ID); err != nil {
+			return err
+		}
+	}
+	if user.AvatarURL != "" {
+		DeleteUpload(user.AvatarURL)
+	}
+	if user.Verification != nil {
+		for _, document := range user.Verification.Documents {
+			os.Remove(PrivateFilePath(document.Path))
+		}
+	}
+	if user.PhoneNumber != nil {
+		database.GetOTPCollection().DeleteMany(ctx, bson.M{"phoneNumber": *user.PhoneNumber})
+	}
+
+	// phoneNumber is uniquely indexed, so it gets a per-account placeholder instead of being removed
+	_, err := database.GetUserCollection().UpdateOne(ctx, bson.M{"_id": user.ID}, bson.M{
+		"$set": bson.M{
+			"name":        "Deleted user",
+			"phoneNumber": "deleted:" + user.ID.Hex(),
+			"deletedAt":   time.Now(),
+		},
+		"$unset": bson.M{
+			"email":                "",
+			"avatarUrl":            "",
+			"city":                 "",
+			"preferredLanguage":    "",
+			"deletionScheduledFor": "",
+			"professional":         "",
+			"verification":         "",
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to anonymize user: %w", err)
+	}
+
+	RecordAudit(ctx, models.AuditLog{Action: models.AuditAccountDeleted, ActorID: user.ID, SubjectID: user.ID})
+	logrus.Info("Account deleted and anonymized: ", user.ID.Hex())
+	return nil
+}
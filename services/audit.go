@@ -0,0 +1,21 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RecordAudit writes an entry to the audit log. Failures are logged, never returned,
+// so auditing can't break the request being audited.
+func RecordAudit(ctx context.Context, entry models.AuditLog) {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	if _, err := database.GetAuditLogCollection().InsertOne(ctx, entry); err != nil {
+		logrus.WithError(err).WithField("action", entry.Action).Error("Failed to write audit log")
+	}
+}
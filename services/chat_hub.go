@@ -0,0 +1,61 @@
+package services
+
+import (
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Chat event types pushed to connected clients
+const (
+	ChatEventMessage = "message"
+	ChatEventRead    = "read"
+)
+
+// ChatEvent is pushed to a user's open real-time connections
+type ChatEvent struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// chatHub fans chat events out to the connections each user has open on this server.
+// Clients that miss events (slow connection, other server instance) catch up by polling.
+type chatHub struct {
+	mu          sync.Mutex
+	subscribers map[primitive.ObjectID]map[chan ChatEvent]struct{}
+}
+
+var hub = &chatHub{subscribers: map[primitive.ObjectID]map[chan ChatEvent]struct{}{}}
+
+// SubscribeChat opens a stream of the user's chat events. Call the returned function to close it.
+func SubscribeChat(userID primitive.ObjectID) (<-chan ChatEvent, func()) {
+	ch := make(chan ChatEvent, 32)
+	hub.mu.Lock()
+	if hub.subscribers[userID] == nil {
+		hub.subscribers[userID] = map[chan ChatEvent]struct{}{}
+	}
+	hub.subscribers[userID][ch] = struct{}{}
+	hub.mu.Unlock()
+
+	return ch, func() {
+		hub.mu.Lock()
+		delete(hub.subscribers[userID], ch)
+		if len(hub.subscribers[userID]) == 0 {
+			delete(hub.subscribers, userID)
+		}
+		hub.mu.Unlock()
+	}
+}
+
+// PublishChat sends an event to every open stream of the user without blocking; streams
+// whose buffer is full miss it
+func PublishChat(userID primitive.ObjectID, event ChatEvent) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for ch := range hub.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// counterDay is the UTC date daily counters are kept under
+func counterDay(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// ClaimDailyCount adds one to the day's counter of key in collection and returns the new count.
+// It reports false without counting when the counter already reached limit. Counters need a
+// unique index on the fields of key and "day".
+func ClaimDailyCount(ctx context.Context, collection *mongo.Collection, key bson.M, limit int, now time.Time) (int, bool, error) {
+	filter := bson.M{"day": counterDay(now), "count": bson.M{"$lt": limit}}
+	for field, value := range key {
+		filter[field] = value
+	}
+	var counter struct {
+		Count int `bson:"count"`
+	}
+	err := collection.FindOneAndUpdate(ctx, filter,
+		bson.M{"$inc": bson.M{"count": 1}, "$setOnInsert": bson.M{"createdAt": now}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&counter)
+	// A full counter doesn't match the filter, so the upsert collides with it
+	if mongo.IsDuplicateKeyError(err) {
+		return limit, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return counter.Count, true, nil
+}
+
+// ReleaseDailyCount takes back a count claimed with ClaimDailyCount at now for an action that failed
+func ReleaseDailyCount(ctx context.Context, collection *mongo.Collection, key bson.M, now time.Time) error {
+	filter := bson.M{"day": counterDay(now), "count": bson.M{"$gt": 0}}
+	for field, value := range key {
+		filter[field] = value
+	}
+	_, err := collection.UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"count": -1}})
+	return err
+}
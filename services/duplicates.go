@@ -0,0 +1,423 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/utils"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg" // decoders for hashing listing photos
+	_ "image/png"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	fingerprintBatch = 500  // most listings fingerprinted per run
+	maxHashedPixels  = 50e6 // larger photos aren't decoded for hashing
+	// How far back of the last listing fingerprinted the next run looks, for updates stored late
+	fingerprintOverlap = 5 * time.Minute
+)
+
+// fingerprintedThrough is the update time up to which every listing has been fingerprinted, so
+// runs only look at listings updated since. It starts at zero: the first run looks at all of them.
+var fingerprintedThrough time.Time
+
+// Statuses of the listings grouped with their likely duplicates
+var duplicateCheckedStatuses = []property.Status{property.PendingReview, property.Published}
+
+// listingBlock is the place, listing type and property type listings are compared within, for
+// duplicates and prices
+type listingBlock struct {
+	PlaceID      primitive.ObjectID
+	ListingType  string
+	PropertyType string
+}
+
+// blockOf returns the block of a listing, by its locality or else its city. Listings not linked
+// to the locality master have none.
+func blockOf(listing property.Property) (listingBlock, bool) {
+	place := listing.LocalityID
+	if place.IsZero() {
+		place = listing.CityID
+	}
+	return listingBlock{PlaceID: place, ListingType: listing.ListingType, PropertyType: listing.PropertyType}, !place.IsZero()
+}
+
+// duplicateBlock is a listing block narrowed to one number of bedrooms, as listings with different
+// numbers of bedrooms are never duplicates
+type duplicateBlock struct {
+	listingBlock
+	Bedrooms int
+}
+
+// duplicateBlockOf returns the block a listing is compared with others for duplicates in
+func duplicateBlockOf(listing property.Property) (duplicateBlock, bool) {
+	block, ok := blockOf(listing)
+	return duplicateBlock{listingBlock: block, Bedrooms: listing.Bedrooms}, ok
+}
+
+func (b duplicateBlock) filter() bson.M {
+	filter := b.listingBlock.filter()
+	filter["bedrooms"] = b.Bedrooms
+	return filter
+}
+
+func (b listingBlock) filter() bson.M {
+	return bson.M{
+		"$or": []bson.M{
+			{"localityId": b.PlaceID},
+			{"cityId": b.PlaceID, "localityId": bson.M{"$exists": false}},
+		},
+		"listingType":  b.ListingType,
+		"propertyType": b.PropertyType,
+	}
+}
+
+// DetectDuplicates fingerprints the listings changed since they were last fingerprinted, then
+// regroups the likely duplicates of every place they are or were in
+func DetectDuplicates(ctx context.Context) error {
+	changed, err := fingerprintListings(ctx)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+
+	blocks := map[duplicateBlock]bool{}
+	ids := make([]primitive.ObjectID, 0, len(changed))
+	for _, listing := range changed {
+		ids = append(ids, listing.ID)
+		if block, ok := duplicateBlockOf(listing); ok {
+			blocks[block] = true
+		}
+	}
+	// Listings that moved, changed type or went off the market leave their former groups
+	groups, err := findAll[models.DuplicateGroup](ctx, database.GetDuplicateGroupCollection(),
+		bson.M{"status": models.DuplicatesOpen, "propertyIds": bson.M{"$in": ids}})
+	if err != nil {
+		return fmt.Errorf("failed to load duplicate groups: %w", err)
+	}
+	for _, group := range groups {
+		blocks[duplicateBlock{
+			listingBlock: listingBlock{PlaceID: group.PlaceID, ListingType: group.ListingType, PropertyType: group.PropertyType},
+			Bedrooms:     group.Bedrooms,
+		}] = true
+	}
+
+	for block := range blocks {
+		if err = regroupDuplicates(ctx, block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fingerprintListings fingerprints a batch of listings updated since their last fingerprint, oldest
+// update first. The primary photo is only hashed again when it changed, and only for photos stored
+// in the upload directory; listings with remote photos are compared on the rest.
+func fingerprintListings(ctx context.Context) ([]property.Property, error) {
+	filter := bson.M{
+		"status": bson.M{"$ne": property.Draft},
+		"$expr":  bson.M{"$ne": bson.A{"$fingerprint.at", "$updatedAt"}},
+	}
+	if !fingerprintedThrough.IsZero() {
+		// The indexed range keeps runs from scanning every listing
+		filter["updatedAt"] = bson.M{"$gte": fingerprintedThrough.Add(-fingerprintOverlap)}
+	}
+	started := time.Now()
+	listings, err := findAll[property.Property](ctx, database.GetPropertyCollection(), filter,
+		options.Find().SetSort(bson.D{{Key: "updatedAt", Value: 1}}).SetLimit(fingerprintBatch))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load listings to fingerprint: %w", err)
+	}
+	defer func() {
+		switch {
+		case err != nil:
+		case len(listings) == fingerprintBatch:
+			// A full batch leaves listings after its last one for the next run
+			fingerprintedThrough = listings[len(listings)-1].UpdatedAt
+		default:
+			fingerprintedThrough = started
+		}
+	}()
+
+	for i, listing := range listings {
+		fingerprint := property.Fingerprint{
+			AddressWords: property.AddressWords(listing.Address),
+			Unit:         property.UnitNumber(listing.Address),
+			PriceBand:    property.PriceBand(listing.Price),
+			At:           listing.UpdatedAt,
+		}
+		if len(listing.Images) > 0 {
+			fingerprint.Image = listing.Images[0]
+			if listing.Fingerprint != nil && listing.Fingerprint.Image == fingerprint.Image {
+				fingerprint.ImageHash = listing.Fingerprint.ImageHash
+			} else if isDuplicateChecked(listing.Status) && strings.HasPrefix(fingerprint.Image, UploadURLPrefix) {
+				if hash, err := hashUploadedImage(fingerprint.Image); err == nil {
+					fingerprint.ImageHash = strconv.FormatUint(hash, 16)
+				} else {
+					logrus.WithError(err).Warn("Failed to hash photo of listing ", listing.ID.Hex())
+				}
+			}
+		}
+
+		_, err = database.GetPropertyCollection().UpdateOne(ctx,
+			bson.M{"_id": listing.ID, "updatedAt": listing.UpdatedAt},
+			bson.M{"$set": bson.M{"fingerprint": fingerprint}})
+		if err != nil {
+			return nil, fmt.Errorf("failed to store fingerprint of listing %s: %w", listing.ID.Hex(), err)
+		}
+		listings[i].Fingerprint = &fingerprint
+	}
+	return listings, nil
+}
+
+func isDuplicateChecked(status property.Status) bool {
+	for _, checked := range duplicateCheckedStatuses {
+		if status == checked {
+			return true
+		}
+	}
+	return false
+}
+
+// hashUploadedImage returns the difference hash of a photo stored in the upload directory
+func hashUploadedImage(urlPath string) (uint64, error) {
+	file, err := OpenUpload(urlPath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	header, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read image: %w", err)
+	}
+	if float64(header.Width)*float64(header.Height) > maxHashedPixels {
+		return 0, fmt.Errorf("image too large to hash: %dx%d", header.Width, header.Height)
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return utils.DifferenceHash(img), nil
+}
+
+// regroupDuplicates compares every active listing of a block with every other and replaces the
+// open duplicate groups of the block with the sets of likely duplicates found. Groups whose
+// members didn't change are kept as they are; listings of a dismissed group are never grouped again.
+func regroupDuplicates(ctx context.Context, block duplicateBlock) error {
+	filter := block.filter()
+	filter["status"] = bson.M{"$in": duplicateCheckedStatuses}
+	filter["fingerprint"] = bson.M{"$exists": true}
+	listings, err := findAll[property.Property](ctx, database.GetPropertyCollection(), filter)
+	if err != nil {
+		return fmt.Errorf("failed to load listings to compare: %w", err)
+	}
+	ids := make([]primitive.ObjectID, 0, len(listings))
+	for _, listing := range listings {
+		ids = append(ids, listing.ID)
+	}
+
+	dismissed, err := findAll[models.DuplicateGroup](ctx, database.GetDuplicateGroupCollection(),
+		bson.M{"status": models.DuplicatesDismissed, "propertyIds": bson.M{"$in": ids}})
+	if err != nil {
+		return fmt.Errorf("failed to load dismissed duplicate groups: %w", err)
+	}
+	notDuplicates := map[[2]primitive.ObjectID]bool{}
+	for _, group := range dismissed {
+		for i, a := range group.PropertyIDs {
+			for _, b := range group.PropertyIDs[i+1:] {
+				notDuplicates[listingPair(a, b)] = true
+			}
+		}
+	}
+
+	// Likely duplicate pairs join their listings' sets
+	parent := make([]int, len(listings))
+	for i := range parent {
+		parent[i] = i
+	}
+	find := func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+	type pair struct {
+		a     int
+		score float64
+	}
+	var pairs []pair
+	for i := range listings {
+		for j := i + 1; j < len(listings); j++ {
+			if notDuplicates[listingPair(listings[i].ID, listings[j].ID)] {
+				continue
+			}
+			if score, ok := property.DuplicateScore(listings[i], listings[j]); ok {
+				pairs = append(pairs, pair{a: i, score: score})
+				parent[find(i)] = find(j)
+			}
+		}
+	}
+	members := map[int][]int{}
+	for i := range listings {
+		members[find(i)] = append(members[find(i)], i)
+	}
+	scores := map[int][]float64{}
+	for _, p := range pairs {
+		scores[find(p.a)] = append(scores[find(p.a)], p.score)
+	}
+
+	now := time.Now()
+	found := map[string]*models.DuplicateGroup{}
+	grouped := map[primitive.ObjectID]bool{}
+	for root, indexes := range members {
+		if len(indexes) < 2 {
+			continue
+		}
+		primary := listings[indexes[0]]
+		group := &models.DuplicateGroup{
+			Status:       models.DuplicatesOpen,
+			PlaceID:      block.PlaceID,
+			ListingType:  block.ListingType,
+			PropertyType: block.PropertyType,
+			Bedrooms:     block.Bedrooms,
+			City:         primary.City,
+			Locality:     primary.Locality,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		for _, i := range indexes {
+			group.PropertyIDs = append(group.PropertyIDs, listings[i].ID)
+			grouped[listings[i].ID] = true
+			if listedFirst(listings[i], primary) {
+				primary = listings[i]
+			}
+		}
+		sort.Slice(group.PropertyIDs, func(i, j int) bool { return group.PropertyIDs[i].Hex() < group.PropertyIDs[j].Hex() })
+		keys := make([]string, 0, len(group.PropertyIDs))
+		for _, id := range group.PropertyIDs {
+			keys = append(keys, id.Hex())
+		}
+		group.MemberKey = strings.Join(keys, ",")
+		group.PrimaryID = primary.ID
+		var sum float64
+		for _, score := range scores[root] {
+			sum += score
+		}
+		group.Score = math.Round(sum/float64(len(scores[root]))*100) / 100
+		found[group.MemberKey] = group
+	}
+
+	collection := database.GetDuplicateGroupCollection()
+	// Groups of the block, and those its listings were in before changing their bedrooms
+	existing, err := findAll[models.DuplicateGroup](ctx, collection, bson.M{
+		"status":       models.DuplicatesOpen,
+		"placeId":      block.PlaceID,
+		"listingType":  block.ListingType,
+		"propertyType": block.PropertyType,
+		"$or":          []bson.M{{"bedrooms": block.Bedrooms}, {"propertyIds": bson.M{"$in": ids}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load duplicate groups: %w", err)
+	}
+	unlink := ids
+	for _, group := range existing {
+		if current, ok := found[group.MemberKey]; ok {
+			current.ID = group.ID
+			_, err = collection.UpdateOne(ctx, bson.M{"_id": group.ID}, bson.M{"$set": bson.M{"primaryId": current.PrimaryID, "score": current.Score}})
+		} else {
+			_, err = collection.DeleteOne(ctx, bson.M{"_id": group.ID, "status": models.DuplicatesOpen})
+			unlink = append(unlink, group.PropertyIDs...)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update duplicate group %s: %w", group.ID.Hex(), err)
+		}
+	}
+	for _, group := range found {
+		if !group.ID.IsZero() {
+			continue
+		}
+		result, err := collection.InsertOne(ctx, group)
+		if err != nil {
+			return fmt.Errorf("failed to store duplicate group: %w", err)
+		}
+		group.ID = result.InsertedID.(primitive.ObjectID)
+		logrus.Info("Found ", len(group.PropertyIDs), " likely duplicate listings in group ", group.ID.Hex())
+	}
+
+	// Point the listings at their current group
+	var ungrouped []primitive.ObjectID
+	for _, id := range unlink {
+		if !grouped[id] {
+			ungrouped = append(ungrouped, id)
+		}
+	}
+	if len(ungrouped) > 0 {
+		_, err = database.GetPropertyCollection().UpdateMany(ctx,
+			bson.M{"_id": bson.M{"$in": ungrouped}, "duplicateGroupId": bson.M{"$exists": true}},
+			bson.M{"$unset": bson.M{"duplicateGroupId": "", "duplicateOf": ""}})
+		if err != nil {
+			return fmt.Errorf("failed to unlink listings from duplicate groups: %w", err)
+		}
+	}
+	for _, group := range found {
+		if err = linkDuplicateGroup(ctx, *group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkDuplicateGroup marks the listings of a group as its members and the non-primary ones as
+// duplicates of the primary one
+func linkDuplicateGroup(ctx context.Context, group models.DuplicateGroup) error {
+	_, err := database.GetPropertyCollection().UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": group.PropertyIDs, "$ne": group.PrimaryID}},
+		bson.M{"$set": bson.M{"duplicateGroupId": group.ID, "duplicateOf": group.PrimaryID}})
+	if err == nil {
+		_, err = database.GetPropertyCollection().UpdateOne(ctx,
+			bson.M{"_id": group.PrimaryID},
+			bson.M{"$set": bson.M{"duplicateGroupId": group.ID}, "$unset": bson.M{"duplicateOf": ""}})
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link listings of duplicate group %s: %w", group.ID.Hex(), err)
+	}
+	return nil
+}
+
+// listingPair returns two listing IDs in a fixed order
+func listingPair(a, b primitive.ObjectID) [2]primitive.ObjectID {
+	if a.Hex() > b.Hex() {
+		a, b = b, a
+	}
+	return [2]primitive.ObjectID{a, b}
+}
+
+// listedFirst reports whether listing a was published before b, or created before it when
+// neither is published yet
+func listedFirst(a, b property.Property) bool {
+	if a.PublishedAt.IsZero() != b.PublishedAt.IsZero() {
+		return !a.PublishedAt.IsZero()
+	}
+	if !a.PublishedAt.Equal(b.PublishedAt) {
+		return a.PublishedAt.Before(b.PublishedAt)
+	}
+	return a.CreatedAt.Before(b.CreatedAt)
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models/property"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PurgeStaleDrafts deletes listing drafts nobody saved a step of for the configured number of
+// days, with their versions and the uploaded photos nothing else uses
+func PurgeStaleDrafts(ctx context.Context) error {
+	cutoff := time.Now().AddDate(0, 0, -config.GetCachedConfig().DraftRetentionDays)
+	stale := bson.M{"status": property.Draft, "updatedAt": bson.M{"$lte": cutoff}}
+	drafts, err := findAll[property.Property](ctx, database.GetPropertyCollection(), stale,
+		options.Find().SetProjection(bson.M{"images": 1}))
+	if err != nil || len(drafts) == 0 {
+		return err
+	}
+
+	purged := 0
+	for _, draft := range drafts {
+		// A draft saved since it was loaded is no longer stale
+		filter := bson.M{"_id": draft.ID, "status": property.Draft, "updatedAt": bson.M{"$lte": cutoff}}
+		result, err := database.GetPropertyCollection().DeleteOne(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("failed to purge stale draft %s: %w", draft.ID.Hex(), err)
+		}
+		if result.DeletedCount == 0 {
+			continue
+		}
+		purged++
+		// Drafts edited rather than saved step by step have versions
+		if _, err = database.GetListingVersionCollection().DeleteMany(ctx, bson.M{"propertyId": draft.ID}); err != nil {
+			return fmt.Errorf("failed to purge versions of stale draft %s: %w", draft.ID.Hex(), err)
+		}
+		for _, image := range draft.Images {
+			if err = deleteUnusedUpload(ctx, image); err != nil {
+				logrus.WithError(err).Warn("Failed to delete photo of stale draft ", draft.ID.Hex())
+			}
+		}
+	}
+	if purged > 0 {
+		logrus.Info("Purged ", purged, " stale listing drafts")
+	}
+	return nil
+}
+
+// deleteUnusedUpload removes a file of the upload directory unless a listing, project or profile
+// still shows it. Images hosted elsewhere are left alone.
+func deleteUnusedUpload(ctx context.Context, urlPath string) error {
+	if !strings.HasPrefix(urlPath, UploadURLPrefix) {
+		return nil
+	}
+	for _, use := range []struct {
+		collection *mongo.Collection
+		field      string
+	}{
+		{database.GetPropertyCollection(), "images"},
+		{database.GetProjectCollection(), "images"},
+		{database.GetUserCollection(), "avatarUrl"},
+	} {
+		count, err := use.collection.CountDocuments(ctx, bson.M{use.field: urlPath})
+		if err != nil || count > 0 {
+			return err
+		}
+	}
+	return DeleteUpload(urlPath)
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Most listings published before expiry dates existed that are given one per run
+const expiryBackfillBatch = 500
+
+// ArchiveLetRentals archives rent listings that were let longer ago than the configured number of days
+func ArchiveLetRentals(ctx context.Context) error {
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -config.GetCachedConfig().RentalLetArchiveDays)
+	let, err := findAll[property.Property](ctx, database.GetPropertyCollection(), bson.M{"status": property.Let, "letAt": bson.M{"$lte": cutoff}})
+	if err != nil {
+		return fmt.Errorf("failed to load let rentals: %w", err)
+	}
+	archived := 0
+	for _, listing := range let {
+		result, err := database.GetPropertyCollection().UpdateOne(ctx,
+			bson.M{"_id": listing.ID, "status": property.Let, "letAt": bson.M{"$lte": cutoff}},
+			bson.M{"$set": bson.M{"status": property.Archived, "updatedAt": now}})
+		if err != nil {
+			return fmt.Errorf("failed to archive let rental %s: %w", listing.ID.Hex(), err)
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		archived++
+		RecordListingUpdate(ctx, listing, primitive.NilObjectID, models.ChangedBySystem)
+		RefreshListingProject(ctx, listing)
+	}
+	if archived > 0 {
+		logrus.Info("Archived ", archived, " let rental listings")
+	}
+	return nil
+}
+
+// ListingExpiry returns when a listing its owner publishes at from expires, by the owner's plan
+func ListingExpiry(ctx context.Context, ownerID primitive.ObjectID, from time.Time) time.Time {
+	var owner models.User
+	if err := database.GetUserCollection().FindOne(ctx, bson.M{"_id": ownerID}).Decode(&owner); err != nil {
+		logrus.WithError(err).Warn("Failed to load owner ", ownerID.Hex(), " for listing expiry")
+	}
+	return from.AddDate(0, 0, models.ListingValidityDays(owner))
+}
+
+// ExpireListings tells owners their published listings expire within the configured number of
+// days, then takes down those past their expiry date until renewed
+func ExpireListings(ctx context.Context) error {
+	if err := backfillListingExpiry(ctx); err != nil {
+		logrus.WithError(err).Error("Failed to date listings without an expiry")
+	}
+	now := time.Now()
+	soon := now.AddDate(0, 0, config.GetCachedConfig().ExpiryNoticeDays)
+
+	expiring, err := findAll[property.Property](ctx, database.GetPropertyCollection(), bson.M{
+		"status":         property.Published,
+		"expiresAt":      bson.M{"$gt": now, "$lte": soon},
+		"expiryNoticeAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load expiring listings: %w", err)
+	}
+	for _, listing := range expiring {
+		// Claim the notice first so a slow run never sends it twice
+		result, err := database.GetPropertyCollection().UpdateOne(ctx,
+			bson.M{"_id": listing.ID, "expiryNoticeAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"expiryNoticeAt": now}})
+		if err != nil || result.ModifiedCount == 0 {
+			continue
+		}
+		notifyListingOwner(ctx, listing, models.NotificationListingExpiry, "Your listing expires soon",
+			"\""+listing.Title+"\" expires on "+listing.ExpiresAt.Format("2 Jan 2006")+". Renew it to keep it in search.")
+	}
+
+	expired, err := findAll[property.Property](ctx, database.GetPropertyCollection(), bson.M{"status": property.Published, "expiresAt": bson.M{"$lte": now}})
+	if err != nil {
+		return fmt.Errorf("failed to load expired listings: %w", err)
+	}
+	for _, listing := range expired {
+		result, err := database.GetPropertyCollection().UpdateOne(ctx,
+			bson.M{"_id": listing.ID, "status": property.Published, "expiresAt": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"status": property.Expired, "updatedAt": now}})
+		if err != nil {
+			return fmt.Errorf("failed to expire listing %s: %w", listing.ID.Hex(), err)
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+		RecordListingUpdate(ctx, listing, primitive.NilObjectID, models.ChangedBySystem)
+		RefreshListingProject(ctx, listing)
+		notifyListingOwner(ctx, listing, models.NotificationListingExpiry, "Your listing expired",
+			"\""+listing.Title+"\" is no longer shown in search. Renew it to put it back up.")
+	}
+	if len(expired) > 0 {
+		logrus.Info("Expired ", len(expired), " listings")
+	}
+	return nil
+}
+
+// backfillListingExpiry dates a batch of published listings from before expiry dates existed, by
+// their owner's plan, never sooner than the notice period so their owners hear of it first. Their
+// search sort date starts at their publication.
+func backfillListingExpiry(ctx context.Context) error {
+	_, err := database.GetPropertyCollection().UpdateMany(ctx,
+		bson.M{"bumpedAt": bson.M{"$exists": false}, "publishedAt": bson.M{"$exists": true}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"bumpedAt": "$publishedAt"}}}})
+	if err != nil {
+		return fmt.Errorf("failed to set listing sort dates: %w", err)
+	}
+
+	listings, err := findAll[property.Property](ctx, database.GetPropertyCollection(),
+		bson.M{"status": property.Published, "expiresAt": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"ownerId": 1, "publishedAt": 1}).SetLimit(expiryBackfillBatch))
+	if err != nil || len(listings) == 0 {
+		return err
+	}
+	ownerIDs := make([]primitive.ObjectID, 0, len(listings))
+	for _, listing := range listings {
+		ownerIDs = append(ownerIDs, listing.OwnerID)
+	}
+	owners, err := findAll[models.User](ctx, database.GetUserCollection(), bson.M{"_id": bson.M{"$in": ownerIDs}})
+	if err != nil {
+		return err
+	}
+	byID := make(map[primitive.ObjectID]models.User, len(owners))
+	for _, owner := range owners {
+		byID[owner.ID] = owner
+	}
+
+	earliest := time.Now().AddDate(0, 0, config.GetCachedConfig().ExpiryNoticeDays+1)
+	writes := make([]mongo.WriteModel, 0, len(listings))
+	for _, listing := range listings {
+		expiresAt := listing.PublishedAt.AddDate(0, 0, models.ListingValidityDays(byID[listing.OwnerID]))
+		if expiresAt.Before(earliest) {
+			expiresAt = earliest
+		}
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": listing.ID, "expiresAt": bson.M{"$exists": false}}).
+			SetUpdate(bson.M{"$set": bson.M{"expiresAt": expiresAt}}))
+	}
+	if _, err = database.GetPropertyCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to set listing expiry dates: %w", err)
+	}
+	return nil
+}
+
+// notifyListingOwner sends the owner of a listing a notice about it, logging failures
+func notifyListingOwner(ctx context.Context, listing property.Property, kind, title, body string) {
+	var owner models.User
+	err := database.GetUserCollection().FindOne(ctx, bson.M{"_id": listing.OwnerID}).Decode(&owner)
+	if err == nil {
+		err = Notify(ctx, owner, Notice{
+			Kind:  kind,
+			Title: title,
+			Body:  body,
+			Data:  map[string]interface{}{"propertyId": listing.ID.Hex()},
+		})
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to send ", kind, " notification to ", listing.OwnerID.Hex())
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Listing fields that change as a side effect and aren't part of its history, with their nested fields
+var untrackedListingFields = map[string]bool{
+	"updatedAt":         true,
+	"favoriteCount":     true,
+	"amenityLabels":     true,
+	"locationCheckedAt": true,
+	"bumpedAt":          true,
+	"expiryNoticeAt":    true,
+	"fingerprint":       true,
+	"duplicateGroupId":  true,
+	"duplicateOf":       true,
+	"quality":           true,
+}
+
+// ListingChanges returns the fields that differ between two states of a listing, nested
+// documents compared field by field
+func ListingChanges(before, after property.Property) ([]models.FieldChange, error) {
+	old, err := flattenListing(before)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := flattenListing(after)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]bool{}
+	for field := range old {
+		fields[field] = true
+	}
+	for field := range updated {
+		fields[field] = true
+	}
+	var changes []models.FieldChange
+	for field := range fields {
+		topLevel, _, _ := strings.Cut(field, ".")
+		if untrackedListingFields[topLevel] || reflect.DeepEqual(old[field], updated[field]) {
+			continue
+		}
+		changes = append(changes, models.FieldChange{Field: field, Old: old[field], New: updated[field]})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes, nil
+}
+
+// flattenListing returns the stored fields of a listing, those of nested documents under dotted names
+func flattenListing(listing property.Property) (map[string]interface{}, error) {
+	data, err := bson.Marshal(listing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode listing: %w", err)
+	}
+	var doc bson.D
+	if err = bson.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode listing: %w", err)
+	}
+	fields := map[string]interface{}{}
+	var flatten func(prefix string, doc bson.D)
+	flatten = func(prefix string, doc bson.D) {
+		for _, elem := range doc {
+			if nested, ok := elem.Value.(bson.D); ok {
+				flatten(prefix+elem.Key+".", nested)
+				continue
+			}
+			fields[prefix+elem.Key] = elem.Value
+		}
+	}
+	flatten("", doc)
+	return fields, nil
+}
+
+// RecordListingVersion stores what changed between two states of a listing, if anything did
+func RecordListingVersion(ctx context.Context, before, after property.Property, actorID primitive.ObjectID, changedBy string) error {
+	changes, err := ListingChanges(before, after)
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	_, err = database.GetListingVersionCollection().InsertOne(ctx, models.ListingVersion{
+		PropertyID: before.ID,
+		ActorID:    actorID,
+		ChangedBy:  changedBy,
+		Changes:    changes,
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record listing version: %w", err)
+	}
+	return nil
+}
+
+// RecordListingUpdate records what an update just made to a listing changed, comparing its stored
+// state with the one before. Failures are logged; the update stands without its version.
+func RecordListingUpdate(ctx context.Context, before property.Property, actorID primitive.ObjectID, changedBy string) {
+	var after property.Property
+	if err := database.GetPropertyCollection().FindOne(ctx, bson.M{"_id": before.ID}).Decode(&after); err != nil {
+		logrus.WithError(err).Error("Failed to reload listing ", before.ID.Hex(), " for its history")
+		return
+	}
+	if err := RecordListingVersion(ctx, before, after, actorID, changedBy); err != nil {
+		logrus.WithError(err).Error("Failed to record version of listing ", before.ID.Hex())
+	}
+}
+
+// PriceHistory returns the prices a listing was offered at, oldest first, from its change history
+func PriceHistory(ctx context.Context, listing property.Property) ([]property.PricePoint, error) {
+	versions, err := findAll[models.ListingVersion](ctx, database.GetListingVersionCollection(),
+		bson.M{"propertyId": listing.ID, "changes.field": "price"},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load price changes: %w", err)
+	}
+
+	history := []property.PricePoint{}
+	for _, version := range versions {
+		for _, change := range version.Changes {
+			if change.Field != "price" {
+				continue
+			}
+			if len(history) == 0 {
+				history = append(history, property.PricePoint{Price: toFloat(change.Old), From: listing.CreatedAt})
+			}
+			history = append(history, property.PricePoint{Price: toFloat(change.New), From: version.CreatedAt})
+		}
+	}
+	if len(history) == 0 {
+		history = append(history, property.PricePoint{Price: listing.Price, From: listing.CreatedAt})
+	}
+	return history, nil
+}
+
+// MarkPriceDrops flags the listings whose price was reduced within the configured number of days
+func MarkPriceDrops(listings []property.Property) {
+	since := time.Now().AddDate(0, 0, -config.GetCachedConfig().PriceDropDays)
+	for i := range listings {
+		listings[i].PriceDropped = listings[i].PriceDroppedAt.After(since)
+	}
+}
+
+func toFloat(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"context"
+	"fmt"
+	"image"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	qualityBatch        = 500                // most listings scored per run
+	qualityRescoreAfter = 7 * 24 * time.Hour // scores go stale as prices nearby move
+	minPriceComparables = 5                  // listings needed for a median price
+)
+
+// ScoreListings scores the listings pending review or published that changed since they were
+// last scored, or whose score is a week old. Listings pending review that fail a hard check are
+// rejected.
+func ScoreListings(ctx context.Context) error {
+	listings, err := findAll[property.Property](ctx, database.GetPropertyCollection(), bson.M{
+		"status": bson.M{"$in": []property.Status{property.PendingReview, property.Published}},
+		"$or": []bson.M{
+			{"$expr": bson.M{"$ne": bson.A{"$quality.updatedAt", "$updatedAt"}}},
+			{"quality.scoredAt": bson.M{"$lt": time.Now().Add(-qualityRescoreAfter)}},
+		},
+	}, options.Find().SetLimit(qualityBatch))
+	if err != nil {
+		return fmt.Errorf("failed to load listings to score: %w", err)
+	}
+	medians := map[listingBlock]float64{}
+	for _, listing := range listings {
+		if _, err = screenListing(ctx, listing, medians); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScreenListing scores a listing just submitted or edited and returns it as it is after: listings
+// pending review that fail a hard check are rejected straight away
+func ScreenListing(ctx context.Context, listingID primitive.ObjectID) (property.Property, error) {
+	var listing property.Property
+	if err := database.GetPropertyCollection().FindOne(ctx, bson.M{"_id": listingID}).Decode(&listing); err != nil {
+		return listing, fmt.Errorf("failed to load listing %s: %w", listingID.Hex(), err)
+	}
+	return screenListing(ctx, listing, map[listingBlock]float64{})
+}
+
+// screenListing scores a listing, with the median prices of the blocks already looked up
+func screenListing(ctx context.Context, listing property.Property, medians map[listingBlock]float64) (property.Property, error) {
+	median, err := medianPricePerSqft(ctx, listing, medians)
+	if err != nil {
+		return listing, err
+	}
+	quality := listing.CheckQuality(property.QualityInputs{MedianPricePerSqft: median, PhotoSizes: uploadedPhotoSizes(listing)})
+	quality.UpdatedAt, quality.ScoredAt = listing.UpdatedAt, time.Now()
+
+	// A listing edited meanwhile is scored again on the next run
+	filter := bson.M{"_id": listing.ID, "updatedAt": listing.UpdatedAt}
+	if _, err = database.GetPropertyCollection().UpdateOne(ctx, filter, bson.M{"$set": bson.M{"quality": quality}}); err != nil {
+		return listing, fmt.Errorf("failed to store quality of listing %s: %w", listing.ID.Hex(), err)
+	}
+	listing.Quality = &quality
+	if !quality.Blocked || listing.Status != property.PendingReview {
+		return listing, nil
+	}
+
+	var reasons []string
+	for _, issue := range quality.Issues {
+		if issue.Hard {
+			reasons = append(reasons, issue.Message)
+		}
+	}
+	reason := "Automatically rejected: " + strings.Join(reasons, "; ")
+	filter["status"] = property.PendingReview
+	result, err := database.GetPropertyCollection().UpdateOne(ctx, filter,
+		bson.M{"$set": bson.M{"status": property.Rejected, "rejectionReason": reason, "updatedAt": time.Now()}})
+	if err != nil {
+		return listing, fmt.Errorf("failed to reject listing %s: %w", listing.ID.Hex(), err)
+	}
+	if result.ModifiedCount == 0 {
+		return listing, nil
+	}
+	RecordListingUpdate(ctx, listing, primitive.NilObjectID, models.ChangedBySystem)
+	RefreshListingProject(ctx, listing)
+	notifyListingOwner(ctx, listing, models.NotificationListingQuality, "Your listing was rejected",
+		"\""+listing.Title+"\" can't be published yet. "+strings.Join(reasons, ". ")+".")
+	logrus.Info("Listing ", listing.ID.Hex(), " rejected by the quality checks")
+
+	listing.Status, listing.RejectionReason = property.Rejected, reason
+	return listing, nil
+}
+
+// medianPricePerSqft returns the median price per square foot of the listings of the locality, or
+// else the city, of listing from the price statistics, or 0 when there are too few of them
+func medianPricePerSqft(ctx context.Context, listing property.Property, medians map[listingBlock]float64) (float64, error) {
+	block, ok := blockOf(listing)
+	if !ok {
+		return 0, nil
+	}
+	if median, ok := medians[block]; ok {
+		return median, nil
+	}
+	stats, err := PriceStatsFor(ctx, block.PlaceID, block.ListingType, block.PropertyType)
+	if err != nil {
+		return 0, err
+	}
+	var median float64
+	if stats != nil && stats.Listings >= minPriceComparables {
+		median = stats.Median
+	}
+	medians[block] = median
+	return median, nil
+}
+
+// uploadedPhotoSizes returns the sizes of the photos of a listing stored in the upload directory.
+// Only the image headers are read.
+func uploadedPhotoSizes(listing property.Property) []image.Point {
+	var sizes []image.Point
+	for _, urlPath := range listing.Images {
+		if !strings.HasPrefix(urlPath, UploadURLPrefix) {
+			continue
+		}
+		file, err := OpenUpload(urlPath)
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to open photo of listing ", listing.ID.Hex())
+			continue
+		}
+		header, _, err := image.DecodeConfig(file)
+		file.Close()
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to read photo of listing ", listing.ID.Hex())
+			continue
+		}
+		sizes = append(sizes, image.Pt(header.Width, header.Height))
+	}
+	return sizes
+}
@@ -0,0 +1,291 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Most unlinked listings matched to the locality master per run
+const linkListingsBatch = 500
+
+// Location is a city of the locality master and optionally one of its localities
+type Location struct {
+	City     models.Locality
+	Locality *models.Locality
+}
+
+// IDs returns the city and locality IDs, the latter zero when only the city is known
+func (l Location) IDs() (primitive.ObjectID, primitive.ObjectID) {
+	if l.Locality == nil {
+		return l.City.ID, primitive.NilObjectID
+	}
+	return l.City.ID, l.Locality.ID
+}
+
+// Names returns the canonical city and locality names
+func (l Location) Names() (string, string) {
+	if l.Locality == nil {
+		return l.City.Name, ""
+	}
+	return l.City.Name, l.Locality.Name
+}
+
+// ResolveLocation finds a place in the locality master, by the ID of a city or locality when
+// localityID is set, otherwise by the city and locality names or one of their aliases. It returns
+// an error wrapping mongo.ErrNoDocuments when there is no such place.
+func ResolveLocation(ctx context.Context, localityID primitive.ObjectID, city, locality string) (Location, error) {
+	var location Location
+	collection := database.GetLocalityCollection()
+
+	if !localityID.IsZero() {
+		var place models.Locality
+		if err := collection.FindOne(ctx, bson.M{"_id": localityID, "level": bson.M{"$in": bson.A{models.LevelCity, models.LevelLocality}}}).Decode(&place); err != nil {
+			return location, fmt.Errorf("unknown locality: %w", err)
+		}
+		if place.Level == models.LevelCity {
+			location.City = place
+			return location, nil
+		}
+		location.Locality = &place
+		if err := collection.FindOne(ctx, bson.M{"_id": place.CityID}).Decode(&location.City); err != nil {
+			return location, fmt.Errorf("unknown city of locality %s: %w", place.ID.Hex(), err)
+		}
+		return location, nil
+	}
+
+	// A name shared by cities of several states resolves to the one with the most listings
+	err := collection.FindOne(ctx, bson.M{"level": models.LevelCity, "searchKeys": models.NormalizePlaceName(city)},
+		options.FindOne().SetSort(bson.D{{Key: "listingCount", Value: -1}})).Decode(&location.City)
+	if err != nil {
+		return location, fmt.Errorf("unknown city %q: %w", city, err)
+	}
+	if locality == "" {
+		return location, nil
+	}
+	var place models.Locality
+	err = collection.FindOne(ctx, bson.M{"level": models.LevelLocality, "cityId": location.City.ID, "searchKeys": models.NormalizePlaceName(locality)}).Decode(&place)
+	if err != nil {
+		return location, fmt.Errorf("unknown locality %q in %s: %w", locality, location.City.Name, err)
+	}
+	location.Locality = &place
+	return location, nil
+}
+
+// UncoveredLocation returns the city and locality as named when the locality master doesn't cover
+// them yet, linked to the part of them it knows: any city is taken until the master has cities,
+// and any locality of a known city until the master has localities of it. ok is false when the
+// master covers the place, so the names must be one of its places.
+func UncoveredLocation(ctx context.Context, city, locality string) (location Location, ok bool, err error) {
+	collection := database.GetLocalityCollection()
+	location, err = ResolveLocation(ctx, primitive.NilObjectID, city, "")
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		cities, err := collection.CountDocuments(ctx, bson.M{"level": models.LevelCity}, options.Count().SetLimit(1))
+		if err != nil || cities > 0 {
+			return location, false, err
+		}
+		location.City = models.Locality{Level: models.LevelCity, Name: city}
+	} else if err != nil {
+		return location, false, err
+	} else {
+		localities, err := collection.CountDocuments(ctx, bson.M{"level": models.LevelLocality, "cityId": location.City.ID}, options.Count().SetLimit(1))
+		if err != nil || localities > 0 || locality == "" {
+			return location, false, err
+		}
+	}
+	if locality != "" {
+		location.Locality = &models.Locality{Level: models.LevelLocality, Name: locality, City: location.City.Name, CityID: location.City.ID}
+	}
+	return location, true, nil
+}
+
+// CanonicalizeSearchFilters replaces free-text city and locality filters with the canonical names
+// of the places they name, so that "Bangalore" finds listings in Bengaluru. Unknown names are kept.
+func CanonicalizeSearchFilters(ctx context.Context, filters *property.SearchFilters) {
+	if filters.City == "" {
+		return
+	}
+	location, err := ResolveLocation(ctx, primitive.NilObjectID, filters.City, filters.Locality)
+	if err != nil {
+		if !errors.Is(err, mongo.ErrNoDocuments) {
+			logrus.WithError(err).Warn("Failed to resolve search location")
+		}
+		return
+	}
+	filters.City, filters.Locality = location.Names()
+}
+
+// RefreshLocalityStats links listings posted before the locality master had their place to it,
+// then recounts the published listings of every city and locality for autocomplete ranking
+func RefreshLocalityStats(ctx context.Context) error {
+	if err := linkListingLocalities(ctx); err != nil {
+		logrus.WithError(err).Error("Failed to link listings to localities")
+	}
+
+	counts := map[primitive.ObjectID]int{}
+	for _, field := range []string{"cityId", "localityId"} {
+		cursor, err := database.GetPropertyCollection().Aggregate(ctx, []bson.M{
+			{"$match": bson.M{"status": property.Published, field: bson.M{"$exists": true}}},
+			{"$group": bson.M{"_id": "$" + field, "count": bson.M{"$sum": 1}}},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to count listings by %s: %w", field, err)
+		}
+		var groups []struct {
+			ID    primitive.ObjectID `bson:"_id"`
+			Count int                `bson:"count"`
+		}
+		if err = cursor.All(ctx, &groups); err != nil {
+			return fmt.Errorf("failed to decode listing counts: %w", err)
+		}
+		for _, group := range groups {
+			counts[group.ID] = group.Count
+		}
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(counts))
+	writes := make([]mongo.WriteModel, 0, len(counts)+1)
+	for id, count := range counts {
+		ids = append(ids, id)
+		writes = append(writes, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": id}).SetUpdate(bson.M{"$set": bson.M{"listingCount": count}}))
+	}
+	writes = append(writes, mongo.NewUpdateManyModel().
+		SetFilter(bson.M{"_id": bson.M{"$nin": ids}, "listingCount": bson.M{"$ne": 0}}).
+		SetUpdate(bson.M{"$set": bson.M{"listingCount": 0}}))
+	if _, err := database.GetLocalityCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to store listing counts: %w", err)
+	}
+	return nil
+}
+
+// linkListingLocalities sets the city and locality IDs, and canonical names, of a batch of listings
+// that have none. Listings whose place isn't in the master yet are retried on the next run.
+func linkListingLocalities(ctx context.Context) error {
+	// Listings that didn't resolve are retried after those not tried as recently
+	listings, err := findAll[property.Property](ctx, database.GetPropertyCollection(),
+		bson.M{"cityId": bson.M{"$exists": false}, "status": bson.M{"$nin": []property.Status{property.Archived, property.Draft}}},
+		options.Find().SetProjection(bson.M{"city": 1, "locality": 1}).
+			SetSort(bson.D{{Key: "locationCheckedAt", Value: 1}}).SetLimit(linkListingsBatch))
+	if err != nil {
+		return err
+	}
+
+	linked := 0
+	for _, listing := range listings {
+		update := bson.M{"locationCheckedAt": time.Now()}
+		location, err := ResolveLocation(ctx, primitive.NilObjectID, listing.City, listing.Locality)
+		if errors.Is(err, mongo.ErrNoDocuments) && listing.Locality != "" {
+			// Keep the free-text locality but at least link the city
+			location, err = ResolveLocation(ctx, primitive.NilObjectID, listing.City, "")
+		}
+		if err == nil {
+			cityID, localityID := location.IDs()
+			city, locality := location.Names()
+			update["cityId"], update["city"] = cityID, city
+			if !localityID.IsZero() {
+				update["localityId"], update["locality"] = localityID, locality
+			}
+			linked++
+		}
+		if _, err = database.GetPropertyCollection().UpdateOne(ctx, bson.M{"_id": listing.ID}, bson.M{"$set": update}); err != nil {
+			return err
+		}
+	}
+	if linked > 0 {
+		logrus.Info("Linked ", linked, " listings to the locality master")
+	}
+	return nil
+}
+
+// RenamePlace carries a place's new name over to the places below it, the listings and projects
+// in it, the leads, reports, duplicate groups and price statistics that copy their names, and the
+// staff scopes naming it
+func RenamePlace(ctx context.Context, place models.Locality, oldName string) error {
+	if field, ok := map[string]string{models.LevelCountry: "country", models.LevelState: "state", models.LevelCity: "city"}[place.Level]; ok {
+		_, err := database.GetLocalityCollection().UpdateMany(ctx, bson.M{"ancestorIds": place.ID}, bson.M{"$set": bson.M{field: place.Name, "updatedAt": time.Now()}})
+		if err != nil {
+			return fmt.Errorf("failed to rename places below %s: %w", place.ID.Hex(), err)
+		}
+	}
+
+	var filter, set bson.M
+	switch place.Level {
+	case models.LevelCity:
+		filter, set = bson.M{"cityId": place.ID}, bson.M{"city": place.Name}
+	case models.LevelLocality:
+		filter, set = bson.M{"localityId": place.ID}, bson.M{"locality": place.Name}
+	default:
+		return nil
+	}
+	if _, err := database.GetPropertyCollection().UpdateMany(ctx, filter, bson.M{"$set": set}); err != nil {
+		return fmt.Errorf("failed to rename listing places: %w", err)
+	}
+	if _, err := database.GetProjectCollection().UpdateMany(ctx, filter, bson.M{"$set": set}); err != nil {
+		return fmt.Errorf("failed to rename project places: %w", err)
+	}
+
+	listingIDs, err := database.GetPropertyCollection().Distinct(ctx, "_id", filter)
+	if err != nil {
+		return fmt.Errorf("failed to load listings of %s: %w", place.ID.Hex(), err)
+	}
+	if len(listingIDs) > 0 {
+		inListings := bson.M{"propertyId": bson.M{"$in": listingIDs}}
+		if _, err = database.GetLeadCollection().UpdateMany(ctx, inListings, bson.M{"$set": set}); err != nil {
+			return fmt.Errorf("failed to rename lead places: %w", err)
+		}
+		if _, err = database.GetListingReportCollection().UpdateMany(ctx, inListings, bson.M{"$set": set}); err != nil {
+			return fmt.Errorf("failed to rename listing report places: %w", err)
+		}
+		if _, err = database.GetDuplicateGroupCollection().UpdateMany(ctx, bson.M{"propertyIds": bson.M{"$in": listingIDs}}, bson.M{"$set": set}); err != nil {
+			return fmt.Errorf("failed to rename duplicate group places: %w", err)
+		}
+	}
+	// The statistics of a city's localities carry its name too
+	placeIDs := []interface{}{place.ID}
+	if place.Level == models.LevelCity {
+		localityIDs, err := database.GetLocalityCollection().Distinct(ctx, "_id", bson.M{"cityId": place.ID})
+		if err != nil {
+			return fmt.Errorf("failed to load localities of %s: %w", place.ID.Hex(), err)
+		}
+		placeIDs = append(placeIDs, localityIDs...)
+	}
+	if _, err = database.GetPriceStatsCollection().UpdateMany(ctx, bson.M{"placeId": bson.M{"$in": placeIDs}}, bson.M{"$set": set}); err != nil {
+		return fmt.Errorf("failed to rename price statistics places: %w", err)
+	}
+	return renameScopePlace(ctx, place, oldName)
+}
+
+// renameScopePlace replaces the old name of a renamed city or locality in the scopes of the staff
+// it was assigned to. Scopes name places, so a name another place of the level still carries is
+// left alone: which of the places was meant can't be told.
+func renameScopePlace(ctx context.Context, place models.Locality, oldName string) error {
+	field := map[string]string{models.LevelCity: "scope.cities", models.LevelLocality: "scope.localities"}[place.Level]
+	if field == "" || oldName == "" {
+		return nil
+	}
+	shared, err := database.GetLocalityCollection().CountDocuments(ctx, bson.M{"level": place.Level, "name": oldName, "_id": bson.M{"$ne": place.ID}})
+	if err != nil {
+		return fmt.Errorf("failed to look up places named %q: %w", oldName, err)
+	}
+	if shared > 0 {
+		logrus.Warn("Staff scopes naming ", oldName, " left unchanged: other places have that name")
+		return nil
+	}
+	_, err = database.GetUserCollection().UpdateMany(ctx, bson.M{field: oldName},
+		bson.M{"$set": bson.M{field + ".$[name]": place.Name}},
+		options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{bson.M{"name": oldName}}}))
+	if err != nil {
+		return fmt.Errorf("failed to rename staff scopes: %w", err)
+	}
+	return nil
+}
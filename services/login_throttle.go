@@ -0,0 +1,101 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Failures allowed before progressive delays kick in, and the longest delay between two attempts
+const (
+	freeLoginAttempts = 2
+	maxLoginDelay     = 30 * time.Second
+)
+
+func usernameAttemptKey(username string) string { return "username:" + username }
+func ipAttemptKey(ip string) string             { return "ip:" + ip }
+
+// LoginRetryAfter returns how long the caller has to wait before another password attempt
+// for this username or from this IP is accepted. Zero means the attempt may proceed.
+func LoginRetryAfter(ctx context.Context, username, ip string) time.Duration {
+	now := time.Now()
+	var wait time.Duration
+
+	cursor, err := database.GetLoginAttemptCollection().Find(ctx, bson.M{"key": bson.M{"$in": []string{usernameAttemptKey(username), ipAttemptKey(ip)}}})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load login attempts")
+		return 0
+	}
+	var attempts []models.LoginAttempt
+	if err = cursor.All(ctx, &attempts); err != nil {
+		logrus.WithError(err).Error("Failed to decode login attempts")
+		return 0
+	}
+
+	for _, attempt := range attempts {
+		if attempt.LockedUntil.After(now) {
+			wait = max(wait, attempt.LockedUntil.Sub(now))
+			continue
+		}
+		if attempt.Failures >= freeLoginAttempts {
+			delay := min(time.Second<<(attempt.Failures-freeLoginAttempts), maxLoginDelay)
+			if next := attempt.LastFailure.Add(delay); next.After(now) {
+				wait = max(wait, next.Sub(now))
+			}
+		}
+	}
+	return wait
+}
+
+// RecordLoginFailure counts a failed password attempt against the username and the IP.
+// It returns true when this failure locked the username.
+func RecordLoginFailure(ctx context.Context, username, ip string) bool {
+	cfg := config.GetCachedConfig()
+	collection := database.GetLoginAttemptCollection()
+	now := time.Now()
+	usernameLocked := false
+
+	for _, key := range []string{usernameAttemptKey(username), ipAttemptKey(ip)} {
+		var attempt models.LoginAttempt
+		err := collection.FindOneAndUpdate(ctx,
+			bson.M{"key": key},
+			bson.M{"$inc": bson.M{"failures": 1}, "$set": bson.M{"lastFailure": now}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&attempt)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to record login failure for ", key)
+			continue
+		}
+		if attempt.Failures < cfg.MaxLoginAttempts {
+			continue
+		}
+
+		// Lock and start counting again once the lockout is over
+		lockedUntil := now.Add(time.Duration(cfg.LoginLockoutMinutes) * time.Minute)
+		_, err = collection.UpdateOne(ctx, bson.M{"_id": attempt.ID}, bson.M{"$set": bson.M{"failures": 0, "lockedUntil": lockedUntil}})
+		if err != nil {
+			logrus.WithError(err).Error("Failed to lock ", key)
+			continue
+		}
+		logrus.Warn("Login locked until ", lockedUntil.Format(time.RFC3339), " for ", key)
+		if key == usernameAttemptKey(username) {
+			usernameLocked = true
+		}
+	}
+	return usernameLocked
+}
+
+// ResetLoginFailures clears the failure counter of a username after a successful login.
+// The IP counter is left alone so one valid account can't be used to reset it.
+func ResetLoginFailures(ctx context.Context, username string) {
+	_, err := database.GetLoginAttemptCollection().DeleteOne(ctx, bson.M{"key": usernameAttemptKey(username)})
+	if err != nil {
+		logrus.WithError(err).Error("Failed to reset login failures")
+	}
+}
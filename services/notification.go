@@ -0,0 +1,47 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NotifyUser delivers a plain-text notice to a user over the channels available on their account
+func NotifyUser(user models.User, message string) error {
+	if user.PhoneNumber == nil || *user.PhoneNumber == "" {
+		return fmt.Errorf("no notification channel available for user %s", user.ID.Hex())
+	}
+	return SendSMS(*user.PhoneNumber, message)
+}
+
+// Notice is a notification sent to an app user through every channel
+type Notice struct {
+	Kind  string
+	Title string
+	Body  string
+	Data  map[string]interface{}
+}
+
+// Notify puts a notice in the user's in-app inbox and sends it by SMS. Only a failure
+// of the inbox is returned, the SMS is best effort.
+func Notify(ctx context.Context, user models.User, notice Notice) error {
+	_, err := database.GetNotificationCollection().InsertOne(ctx, models.Notification{
+		UserID:    user.ID,
+		Kind:      notice.Kind,
+		Title:     notice.Title,
+		Body:      notice.Body,
+		Data:      notice.Data,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store notification: %w", err)
+	}
+	if err = NotifyUser(user, notice.Title+"\n"+notice.Body); err != nil {
+		logrus.WithError(err).Warn("Failed to send ", notice.Kind, " notification by SMS")
+	}
+	return nil
+}
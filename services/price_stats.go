@@ -0,0 +1,168 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	priceStatsMonths      = 12 // months of listings the statistics cover
+	minPriceStatsListings = 3  // listings a place needs for statistics
+)
+
+// Statuses of the listings prices are taken from: on the market, or taken off it by a deal
+var pricedStatuses = []property.Status{property.Published, property.Sold, property.Let}
+
+// priceStatsKey identifies the statistics of one place, listing type and property type
+type priceStatsKey struct {
+	PlaceID      primitive.ObjectID `bson:"placeId"`
+	ListingType  string             `bson:"listingType"`
+	PropertyType string             `bson:"propertyType"`
+}
+
+// AggregatePriceStats recomputes the price per square foot statistics of every city and locality
+// by listing type and property type, from the listings published over the last year, and drops
+// those of places left without enough listings
+func AggregatePriceStats(ctx context.Context) error {
+	start := time.Now()
+	since := start.AddDate(0, -priceStatsMonths, 0)
+	stats := map[priceStatsKey]*models.PriceStats{}
+	prices := map[priceStatsKey][]float64{}
+
+	for _, level := range []string{models.LevelCity, models.LevelLocality} {
+		field := level + "Id"
+		cursor, err := database.GetPropertyCollection().Aggregate(ctx, []bson.M{
+			{"$match": bson.M{
+				"status":   bson.M{"$in": pricedStatuses},
+				field:      bson.M{"$exists": true},
+				"price":    bson.M{"$gt": 0},
+				"areaSqft": bson.M{"$gt": 0},
+				"$or":      listedSince(since),
+			}},
+			{"$group": bson.M{
+				"_id": bson.M{
+					"placeId":      "$" + field,
+					"listingType":  "$listingType",
+					"propertyType": "$propertyType",
+					"month":        bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": bson.M{"$ifNull": bson.A{"$publishedAt", "$createdAt"}}}},
+				},
+				"city":     bson.M{"$first": "$city"},
+				"locality": bson.M{"$first": "$locality"},
+				"prices":   bson.M{"$push": bson.M{"$divide": bson.A{"$price", "$areaSqft"}}},
+			}},
+		}, options.Aggregate().SetAllowDiskUse(true))
+		if err != nil {
+			return fmt.Errorf("failed to aggregate prices by %s: %w", field, err)
+		}
+		var months []struct {
+			ID struct {
+				PlaceID      primitive.ObjectID `bson:"placeId"`
+				ListingType  string             `bson:"listingType"`
+				PropertyType string             `bson:"propertyType"`
+				Month        string             `bson:"month"`
+			} `bson:"_id"`
+			City     string    `bson:"city"`
+			Locality string    `bson:"locality"`
+			Prices   []float64 `bson:"prices"`
+		}
+		if err = cursor.All(ctx, &months); err != nil {
+			return fmt.Errorf("failed to decode prices by %s: %w", field, err)
+		}
+
+		for _, month := range months {
+			key := priceStatsKey{PlaceID: month.ID.PlaceID, ListingType: month.ID.ListingType, PropertyType: month.ID.PropertyType}
+			row, ok := stats[key]
+			if !ok {
+				row = &models.PriceStats{
+					PlaceID:      key.PlaceID,
+					Level:        level,
+					City:         month.City,
+					ListingType:  key.ListingType,
+					PropertyType: key.PropertyType,
+					UpdatedAt:    start,
+				}
+				if level == models.LevelLocality {
+					row.Locality = month.Locality
+				}
+				stats[key] = row
+			}
+			sort.Float64s(month.Prices)
+			row.Trend = append(row.Trend, models.MonthlyPrice{
+				Month:    month.ID.Month,
+				Median:   math.Round(percentile(month.Prices, 50)),
+				Listings: len(month.Prices),
+			})
+			prices[key] = append(prices[key], month.Prices...)
+		}
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(stats)+1)
+	for key, row := range stats {
+		all := prices[key]
+		if len(all) < minPriceStatsListings {
+			continue
+		}
+		sort.Float64s(all)
+		row.Listings = len(all)
+		row.Median = math.Round(percentile(all, 50))
+		row.P10, row.P25 = math.Round(percentile(all, 10)), math.Round(percentile(all, 25))
+		row.P75, row.P90 = math.Round(percentile(all, 75)), math.Round(percentile(all, 90))
+		sort.Slice(row.Trend, func(i, j int) bool { return row.Trend[i].Month < row.Trend[j].Month })
+		writes = append(writes, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"placeId": key.PlaceID, "listingType": key.ListingType, "propertyType": key.PropertyType}).
+			SetReplacement(row).
+			SetUpsert(true))
+	}
+	writes = append(writes, mongo.NewDeleteManyModel().SetFilter(bson.M{"updatedAt": bson.M{"$lt": start}}))
+	if _, err := database.GetPriceStatsCollection().BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(true)); err != nil {
+		return fmt.Errorf("failed to store price statistics: %w", err)
+	}
+	return nil
+}
+
+// PriceStatsFor returns the price statistics of a city or locality for a listing type and
+// property type, or nil when it doesn't have enough listings
+func PriceStatsFor(ctx context.Context, placeID primitive.ObjectID, listingType, propertyType string) (*models.PriceStats, error) {
+	var stats models.PriceStats
+	err := database.GetPriceStatsCollection().FindOne(ctx, bson.M{"placeId": placeID, "listingType": listingType, "propertyType": propertyType}).Decode(&stats)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load price statistics: %w", err)
+	}
+	return &stats, nil
+}
+
+// listedSince matches listings published since a time, or created since then when never published
+func listedSince(since time.Time) []bson.M {
+	return []bson.M{
+		{"publishedAt": bson.M{"$gte": since}},
+		{"publishedAt": bson.M{"$exists": false}, "createdAt": bson.M{"$gte": since}},
+	}
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating between the nearest two
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (sorted[lower+1]-sorted[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,70 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models/property"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshProjectStats recomputes a project's price range and unit count from its published units
+func RefreshProjectStats(ctx context.Context, projectID primitive.ObjectID) error {
+	cursor, err := database.GetPropertyCollection().Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"projectId": projectID, "status": property.Published}},
+		{"$group": bson.M{"_id": nil, "minPrice": bson.M{"$min": "$price"}, "maxPrice": bson.M{"$max": "$price"}, "units": bson.M{"$sum": 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to aggregate project units: %w", err)
+	}
+	var stats []struct {
+		MinPrice float64 `bson:"minPrice"`
+		MaxPrice float64 `bson:"maxPrice"`
+		Units    int     `bson:"units"`
+	}
+	if err = cursor.All(ctx, &stats); err != nil {
+		return fmt.Errorf("failed to decode project stats: %w", err)
+	}
+
+	update := bson.M{"$set": bson.M{"publishedUnits": 0}, "$unset": bson.M{"minPrice": "", "maxPrice": ""}}
+	if len(stats) > 0 {
+		update = bson.M{"$set": bson.M{"publishedUnits": stats[0].Units, "minPrice": stats[0].MinPrice, "maxPrice": stats[0].MaxPrice}}
+	}
+	_, err = database.GetProjectCollection().UpdateOne(ctx, bson.M{"_id": projectID}, update)
+	return err
+}
+
+// RefreshListingProject refreshes the stats of the project a listing belongs to, if any. Called
+// after a unit's status or price changes; failures are logged and left to RefreshAllProjectStats.
+func RefreshListingProject(ctx context.Context, listing property.Property) {
+	if listing.ProjectID.IsZero() {
+		return
+	}
+	if err := RefreshProjectStats(ctx, listing.ProjectID); err != nil {
+		logrus.WithError(err).Warn("Failed to refresh stats of project ", listing.ProjectID.Hex())
+	}
+}
+
+// RefreshAllProjectStats recomputes the stats of every project whose units changed recently, catching
+// changes made outside the listing handlers such as expiry and report suspensions
+func RefreshAllProjectStats(ctx context.Context) error {
+	projectIDs, err := database.GetPropertyCollection().Distinct(ctx, "projectId",
+		bson.M{"projectId": bson.M{"$exists": true}, "updatedAt": bson.M{"$gte": time.Now().Add(-2 * time.Hour)}})
+	if err != nil {
+		return fmt.Errorf("failed to find changed projects: %w", err)
+	}
+	for _, id := range projectIDs {
+		projectID, ok := id.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+		if err = RefreshProjectStats(ctx, projectID); err != nil {
+			logrus.WithError(err).Error("Failed to refresh stats of project ", projectID.Hex())
+		}
+	}
+	return nil
+}
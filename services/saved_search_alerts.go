@@ -0,0 +1,123 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/utils"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Purpose of the signed saved-search unsubscribe links
+const UnsubscribePurpose = "saved-search-unsubscribe"
+
+// Most listings named in one digest
+const maxDigestListings = 20
+
+// SendSavedSearchAlerts sends the owners of saved searches a digest of the listings published since
+// each was last checked: on every run for instant alerts, and once their window has elapsed for
+// daily and weekly ones
+func SendSavedSearchAlerts(ctx context.Context) error {
+	searches, err := findAll[models.SavedSearch](ctx, database.GetSavedSearchCollection(), bson.M{"alertsEnabled": true})
+	if err != nil {
+		return fmt.Errorf("failed to load saved searches: %w", err)
+	}
+
+	users := map[primitive.ObjectID]*models.User{}
+	for _, search := range searches {
+		interval, ok := models.AlertInterval(search.Frequency)
+		if !ok {
+			continue
+		}
+		windowStart := search.LastDigestAt
+		if windowStart.IsZero() {
+			// Saved before digest windows were tracked
+			windowStart = search.CreatedAt
+		}
+		if time.Since(windowStart) < interval {
+			continue
+		}
+
+		user, loaded := users[search.UserID]
+		if !loaded {
+			var u models.User
+			if err = database.GetUserCollection().FindOne(ctx, bson.M{"_id": search.UserID, "deletedAt": bson.M{"$exists": false}}).Decode(&u); err == nil {
+				user = &u
+			}
+			users[search.UserID] = user
+		}
+		if user == nil || user.AlertsUnsubscribed {
+			continue
+		}
+
+		if err = alertSavedSearch(ctx, *user, search); err != nil {
+			logrus.WithError(err).Error("Failed to alert saved search ", search.ID.Hex())
+		}
+	}
+	return nil
+}
+
+func alertSavedSearch(ctx context.Context, user models.User, search models.SavedSearch) error {
+	checkedAt := time.Now()
+	query := search.Filters.Query()
+	query["publishedAt"] = bson.M{"$gt": search.LastCheckedAt, "$lte": checkedAt}
+
+	matches, err := findAll[property.Property](ctx, database.GetPropertyCollection(), query,
+		options.Find().SetSort(bson.D{{Key: "publishedAt", Value: -1}}).SetLimit(maxDigestListings))
+	if err != nil {
+		return fmt.Errorf("failed to match listings: %w", err)
+	}
+	total := int64(len(matches))
+	if total == maxDigestListings {
+		if total, err = database.GetPropertyCollection().CountDocuments(ctx, query); err != nil {
+			return fmt.Errorf("failed to count matching listings: %w", err)
+		}
+	}
+
+	// The next window starts now, whether or not anything matched in this one
+	update := bson.M{"lastCheckedAt": checkedAt, "lastDigestAt": checkedAt}
+	if len(matches) > 0 {
+		if err = Notify(ctx, user, savedSearchDigest(user, search, matches, total)); err != nil {
+			return err
+		}
+		update["lastNotifiedAt"] = checkedAt
+	}
+	_, err = database.GetSavedSearchCollection().UpdateOne(ctx, bson.M{"_id": search.ID}, bson.M{"$set": update})
+	return err
+}
+
+// savedSearchDigest is the notice naming the first matches of a saved search, out of total
+func savedSearchDigest(user models.User, search models.SavedSearch, matches []property.Property, total int64) Notice {
+	ids := make([]string, 0, len(matches))
+	lines := make([]string, 0, 3)
+	for i, listing := range matches {
+		ids = append(ids, listing.ID.Hex())
+		if i < 3 {
+			lines = append(lines, fmt.Sprintf("%s, %s - %.0f", listing.Title, listing.Locality, listing.Price))
+		}
+	}
+	if total > 3 {
+		lines = append(lines, fmt.Sprintf("and %d more", total-3))
+	}
+
+	token := utils.SignValue(UnsubscribePurpose, user.ID.Hex(), config.GetCachedConfig().JWTSecret)
+	return Notice{
+		Kind:  models.NotificationSavedSearchMatch,
+		Title: fmt.Sprintf("%d new listings for \"%s\"", total, search.Name),
+		Body:  strings.Join(lines, "\n"),
+		Data: map[string]interface{}{
+			"savedSearchId":    search.ID.Hex(),
+			"propertyIds":      ids,
+			"unsubscribeToken": token,
+		},
+	}
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RunPeriodically runs job in the background every interval until ctx is cancelled.
+// A failing run is logged and retried on the next tick.
+func RunPeriodically(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := job(ctx); err != nil {
+				logrus.WithError(err).Error("Background job failed: ", name)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
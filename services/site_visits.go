@@ -0,0 +1,96 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/models/property"
+	"PropertyAppBackend/utils"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Purpose of the signed links to a user's site visit calendar feed
+const VisitCalendarPurpose = "visit-calendar"
+
+// FormatVisitTime renders a visit time for notifications, in the app's default time zone
+func FormatVisitTime(t time.Time) string {
+	if loc, err := time.LoadLocation(config.GetCachedConfig().DefaultTimeZone); err == nil {
+		t = t.In(loc)
+	}
+	return t.Format("Mon 2 Jan, 3:04 PM")
+}
+
+// NotifyVisitParty tells one side of a site visit about a change to it
+func NotifyVisitParty(ctx context.Context, userID primitive.ObjectID, visit models.SiteVisit, title, body string) {
+	var user models.User
+	err := database.GetUserCollection().FindOne(ctx, bson.M{"_id": userID}).Decode(&user)
+	if err == nil {
+		err = Notify(ctx, user, Notice{
+			Kind:  models.NotificationSiteVisit,
+			Title: title,
+			Body:  body,
+			Data:  map[string]interface{}{"visitId": visit.ID.Hex(), "propertyId": visit.PropertyID.Hex()},
+		})
+	}
+	if err != nil {
+		logrus.WithError(err).Warn("Failed to notify user about site visit ", visit.ID.Hex())
+	}
+}
+
+// VisitCalendarEvent describes a site visit for calendar apps
+func VisitCalendarEvent(visit models.SiteVisit, listing property.Property) utils.CalendarEvent {
+	status := "TENTATIVE"
+	switch visit.Status {
+	case models.VisitConfirmed:
+		status = "CONFIRMED"
+	case models.VisitCancelled:
+		status = "CANCELLED"
+	}
+	location := listing.Address
+	if listing.Locality != "" {
+		location += ", " + listing.Locality
+	}
+	return utils.CalendarEvent{
+		UID:         visit.ID.Hex() + "@site-visits.propertyapp",
+		Start:       visit.Start,
+		End:         visit.End,
+		Summary:     "Site visit: " + listing.Title,
+		Description: fmt.Sprintf("%s in %s", listing.PropertyType, listing.City),
+		Location:    location + ", " + listing.City,
+		Status:      status,
+		Sequence:    visit.Sequence,
+	}
+}
+
+// SendVisitReminders reminds both sides of confirmed visits starting soon
+func SendVisitReminders(ctx context.Context) error {
+	now := time.Now()
+	soon := now.Add(time.Duration(config.GetCachedConfig().VisitReminderMinutes) * time.Minute)
+	visits, err := findAll[models.SiteVisit](ctx, database.GetSiteVisitCollection(), bson.M{
+		"status":         models.VisitConfirmed,
+		"start":          bson.M{"$gt": now, "$lte": soon},
+		"reminderSentAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load upcoming visits: %w", err)
+	}
+	for _, visit := range visits {
+		// Claim the reminder first so a slow run never sends it twice
+		result, err := database.GetSiteVisitCollection().UpdateOne(ctx,
+			bson.M{"_id": visit.ID, "reminderSentAt": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"reminderSentAt": now}})
+		if err != nil || result.ModifiedCount == 0 {
+			continue
+		}
+		body := "Your site visit is at " + FormatVisitTime(visit.Start) + "."
+		NotifyVisitParty(ctx, visit.BuyerID, visit, "Site visit reminder", body)
+		NotifyVisitParty(ctx, visit.OwnerID, visit, "Site visit reminder", body)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"PropertyAppBackend/config"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// UploadURLPrefix is the URL path the upload directory is served under
+const UploadURLPrefix = "/uploads/"
+
+// writeRandomFile writes data into root/folder under a random name and returns that name
+func writeRandomFile(root, folder string, data []byte, ext string, perm os.FileMode) (string, error) {
+	dir := filepath.Join(root, folder)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create upload folder: %w", err)
+	}
+
+	nameBytes := make([]byte, 16)
+	if _, err := rand.Read(nameBytes); err != nil {
+		return "", fmt.Errorf("failed to generate upload name: %w", err)
+	}
+	name := hex.EncodeToString(nameBytes) + ext
+
+	if err := os.WriteFile(filepath.Join(dir, name), data, perm); err != nil {
+		return "", fmt.Errorf("failed to write upload: %w", err)
+	}
+	return name, nil
+}
+
+// SaveUpload writes a file into a folder of the upload directory under a random name
+// and returns the URL path it is served from
+func SaveUpload(folder string, data []byte, ext string) (string, error) {
+	name, err := writeRandomFile(config.GetCachedConfig().UploadDir, folder, data, ext, 0o644)
+	if err != nil {
+		return "", err
+	}
+	return UploadURLPrefix + folder + "/" + name, nil
+}
+
+// SavePrivateFile writes a file that must never be public (identity documents, ...) into the
+// private upload directory and returns its path relative to that directory
+func SavePrivateFile(folder string, data []byte, ext string) (string, error) {
+	name, err := writeRandomFile(config.GetCachedConfig().PrivateUploadDir, folder, data, ext, 0o600)
+	if err != nil {
+		return "", err
+	}
+	return folder + "/" + name, nil
+}
+
+// PrivateFilePath resolves a path returned by SavePrivateFile on disk
+func PrivateFilePath(relative string) string {
+	return filepath.Join(config.GetCachedConfig().PrivateUploadDir, filepath.Clean("/"+relative))
+}
+
+// PrivateFileExists reports whether a path returned by SavePrivateFile names a stored file
+func PrivateFileExists(relative string) bool {
+	info, err := os.Stat(PrivateFilePath(relative))
+	return err == nil && info.Mode().IsRegular()
+}
+
+// uploadFilePath resolves the URL path of a file stored by SaveUpload on disk
+func uploadFilePath(urlPath string) (string, error) {
+	if len(urlPath) <= len(UploadURLPrefix) || urlPath[:len(UploadURLPrefix)] != UploadURLPrefix {
+		return "", fmt.Errorf("not an upload path: %s", urlPath)
+	}
+	relative := filepath.Clean("/" + urlPath[len(UploadURLPrefix):])
+	return filepath.Join(config.GetCachedConfig().UploadDir, relative), nil
+}
+
+// UploadExists reports whether a URL path names a file stored by SaveUpload
+func UploadExists(urlPath string) bool {
+	path, err := uploadFilePath(urlPath)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// DeleteUpload removes a file previously stored by SaveUpload, given its URL path
+func DeleteUpload(urlPath string) error {
+	path, err := uploadFilePath(urlPath)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// OpenUpload opens a file previously stored by SaveUpload, given its URL path
+func OpenUpload(urlPath string) (*os.File, error) {
+	path, err := uploadFilePath(urlPath)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
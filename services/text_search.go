@@ -0,0 +1,122 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models"
+	"PropertyAppBackend/utils"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How long the search vocabulary is reused before being reloaded
+const vocabularyTTL = 10 * time.Minute
+
+// Words worth correcting a typo to besides place and amenity names
+var baseVocabulary = []string{
+	"sea", "view", "facing", "garden", "park", "corner", "furnished", "semi", "new", "ready", "move",
+	"independent", "house", "penthouse", "duplex", "studio", "balcony", "terrace", "parking", "metro", "station",
+}
+
+var vocabulary struct {
+	sync.Mutex
+	words    map[string]bool
+	loadedAt time.Time
+}
+
+// AmenityLabels returns the catalogue labels of the amenities, which listings carry for the text index
+func AmenityLabels(ctx context.Context, ids []primitive.ObjectID) ([]string, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	amenities, err := findAll[models.Amenity](ctx, database.GetAmenityCollection(), bson.M{"_id": bson.M{"$in": ids}},
+		options.Find().SetProjection(bson.M{"label": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load amenity labels: %w", err)
+	}
+	labels := make([]string, 0, len(amenities))
+	for _, amenity := range amenities {
+		labels = append(labels, amenity.Label)
+	}
+	return labels, nil
+}
+
+// CorrectSearchTerms replaces search terms that are not known place, amenity or property words
+// with the closest known word, when one is close enough. It reports whether anything changed.
+func CorrectSearchTerms(ctx context.Context, terms []string) ([]string, bool, error) {
+	words, err := searchVocabulary(ctx)
+	if err != nil {
+		return terms, false, err
+	}
+
+	corrected := make([]string, len(terms))
+	changed := false
+	for i, term := range terms {
+		corrected[i] = term
+		length := len([]rune(term))
+		if words[term] || length < 4 {
+			continue
+		}
+		// One typo in short words, two in longer ones
+		best, bestDistance := "", 2
+		if length > 7 {
+			bestDistance = 3
+		}
+		for word := range words {
+			if d := utils.EditDistance(term, word); d < bestDistance || (d == bestDistance && best != "" && word < best) {
+				best, bestDistance = word, d
+			}
+		}
+		if best != "" {
+			corrected[i] = best
+			changed = true
+		}
+	}
+	return corrected, changed, nil
+}
+
+// searchVocabulary returns the words of place names, their aliases and amenity labels
+func searchVocabulary(ctx context.Context) (map[string]bool, error) {
+	vocabulary.Lock()
+	defer vocabulary.Unlock()
+	if vocabulary.words != nil && time.Since(vocabulary.loadedAt) < vocabularyTTL {
+		return vocabulary.words, nil
+	}
+
+	words := map[string]bool{}
+	add := func(text string) {
+		for _, word := range strings.Fields(models.NormalizePlaceName(text)) {
+			words[word] = true
+		}
+	}
+	for _, word := range baseVocabulary {
+		words[word] = true
+	}
+
+	places, err := findAll[models.Locality](ctx, database.GetLocalityCollection(),
+		bson.M{"level": bson.M{"$in": bson.A{models.LevelCity, models.LevelLocality}}}, options.Find().SetProjection(bson.M{"searchKeys": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load place names: %w", err)
+	}
+	for _, place := range places {
+		for _, key := range place.SearchKeys {
+			add(key)
+		}
+	}
+	amenities, err := findAll[models.Amenity](ctx, database.GetAmenityCollection(), bson.M{}, options.Find().SetProjection(bson.M{"label": 1}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load amenity labels: %w", err)
+	}
+	for _, amenity := range amenities {
+		add(amenity.Label)
+	}
+
+	vocabulary.words, vocabulary.loadedAt = words, time.Now()
+	return words, nil
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	database "PropertyAppBackend/db"
+	"PropertyAppBackend/models/property"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNoValuation is returned when there are neither comparables nor statistics to estimate a price from
+var ErrNoValuation = errors.New("not enough listings nearby to estimate a price")
+
+const (
+	valuationCandidates = 200 // most recent listings of a place compared with the property
+	comparableAreaRange = 0.3 // comparables are at most this share larger or smaller
+)
+
+// EstimatePrice estimates the price of a property from the comparable listings of the last year in
+// its locality, then its city when the locality has too few. Failing that, the estimate comes
+// from the price statistics of the locality or city, with low confidence.
+func EstimatePrice(ctx context.Context, subject property.Property) (property.Valuation, error) {
+	now := time.Now()
+	places := []struct {
+		field string
+		id    primitive.ObjectID
+	}{{"localityId", subject.LocalityID}, {"cityId", subject.CityID}}
+
+	var valuation property.Valuation
+	for _, place := range places {
+		if place.id.IsZero() {
+			continue
+		}
+		candidates, err := findAll[property.Property](ctx, database.GetPropertyCollection(), bson.M{
+			place.field:    place.id,
+			"status":       bson.M{"$in": pricedStatuses},
+			"listingType":  subject.ListingType,
+			"propertyType": subject.PropertyType,
+			"price":        bson.M{"$gt": 0},
+			"areaSqft":     bson.M{"$gte": subject.AreaSqft * (1 - comparableAreaRange), "$lte": subject.AreaSqft * (1 + comparableAreaRange)},
+			"$or":          listedSince(now.AddDate(0, -priceStatsMonths, 0)),
+		}, options.Find().SetSort(bson.D{{Key: "bumpedAt", Value: -1}}).SetLimit(valuationCandidates))
+		if err != nil {
+			return valuation, fmt.Errorf("failed to load comparable listings: %w", err)
+		}
+		found, ok := subject.Valuate(candidates, now)
+		if ok {
+			return found, nil
+		}
+		if len(found.Comparables) >= len(valuation.Comparables) {
+			valuation = found
+		}
+	}
+
+	for _, place := range places {
+		if place.id.IsZero() {
+			continue
+		}
+		stats, err := PriceStatsFor(ctx, place.id, subject.ListingType, subject.PropertyType)
+		if err != nil {
+			return valuation, err
+		}
+		if stats != nil {
+			valuation.Basis = stats.Level
+			valuation.SetRange(subject.AreaSqft, stats.Median, stats.P25, stats.P75)
+			valuation.Confidence = property.ConfidenceLow
+			return valuation, nil
+		}
+	}
+	return valuation, ErrNoValuation
+}
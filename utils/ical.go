@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarEvent is one VEVENT of an iCalendar (RFC 5545) file
+type CalendarEvent struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Status      string // TENTATIVE, CONFIRMED or CANCELLED
+	Sequence    int    // bumped on every change so calendar apps replace the old copy
+}
+
+var icalEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// WriteCalendar renders events as an iCalendar file
+func WriteCalendar(name string, events []CalendarEvent) []byte {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(fmt.Sprintf(format, args...))
+		b.WriteString("\r\n")
+	}
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//PropertyApp//Site visits//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:%s", icalEscaper.Replace(name))
+	stamp := time.Now().UTC().Format(icalTimeFormat)
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:%s", event.UID)
+		line("DTSTAMP:%s", stamp)
+		line("DTSTART:%s", event.Start.UTC().Format(icalTimeFormat))
+		line("DTEND:%s", event.End.UTC().Format(icalTimeFormat))
+		line("SEQUENCE:%d", event.Sequence)
+		line("SUMMARY:%s", icalEscaper.Replace(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:%s", icalEscaper.Replace(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION:%s", icalEscaper.Replace(event.Location))
+		}
+		if event.Status != "" {
+			line("STATUS:%s", event.Status)
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
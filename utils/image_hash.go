@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"image"
+)
+
+// DifferenceHash returns a 64-bit perceptual hash of an image: each bit tells whether a cell of
+// a 9x8 grayscale thumbnail is brighter than its right neighbour. Resized, recompressed or lightly
+// edited copies of a photo hash to values a few bits apart.
+func DifferenceHash(img image.Image) uint64 {
+	const width, height = 9, 8
+	bounds := img.Bounds()
+	var cells [height][width]float64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			// Average the pixels of the cell, sampling at most 4x4 of them
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/width
+			y0 := bounds.Min.Y + y*bounds.Dy()/height
+			y1 := bounds.Min.Y + (y+1)*bounds.Dy()/height
+			stepX, stepY := max((x1-x0)/4, 1), max((y1-y0)/4, 1)
+			var sum float64
+			var count int
+			for py := y0; py < max(y1, y0+1); py += stepY {
+				for px := x0; px < max(x1, x0+1); px += stepX {
+					r, g, b, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+					count++
+				}
+			}
+			cells[y][x] = sum / float64(count)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < height; y++ {
+		for x := 0; x < width-1; x++ {
+			hash <<= 1
+			if cells[y][x] > cells[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
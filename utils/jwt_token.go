@@ -8,28 +8,12 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var JWTSecret = []byte("Min-Admin@123")
-
-// GenerateJWT generates a JWT token for Admin, Mini-Admin, or User
-func GenerateJWT(userID primitive.ObjectID, role string) (string, error) {
-    claims := jwt.MapClaims{
-        "user_id": userID.Hex(),
-        "role":    role,
-        "exp":     jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-    }
-
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    tokenString, err := token.SignedString(JWTSecret)
-    if err != nil {
-        return "", fmt.Errorf("failed to sign JWT token: %w", err)
-    }
-    return tokenString, nil
-}
-// GenerateAccessToken generates a new short-lived JWT Access Token
-func GenerateAccessToken(userID primitive.ObjectID, secret string) (string, error) {
+// GenerateAccessToken generates a new short-lived JWT Access Token carrying the user's role
+func GenerateAccessToken(userID primitive.ObjectID, role string, secret string) (string, error) {
 	claims := jwt.MapClaims{
 		"authorized": true,
 		"user_id":    userID.Hex(),
+		"role":       role,
 		"exp":        jwt.NewNumericDate(time.Now().Add(time.Hour * 1)),
 	}
 
@@ -41,6 +25,39 @@ func GenerateAccessToken(userID primitive.ObjectID, secret string) (string, erro
 	return tokenString, nil
 }
 
+// GenerateImpersonationToken generates a short-lived access token acting as userID on behalf of actorID.
+// The actor is named in the "act" claim (RFC 8693) so every request made with it can be told apart.
+func GenerateImpersonationToken(userID primitive.ObjectID, role string, actorID primitive.ObjectID, secret string, lifetime time.Duration) (string, error) {
+	claims := jwt.MapClaims{
+		"authorized": true,
+		"user_id":    userID.Hex(),
+		"role":       role,
+		"act":        map[string]string{"sub": actorID.Hex()},
+		"exp":        jwt.NewNumericDate(time.Now().Add(lifetime)),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString([]byte(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to sign impersonation token: %w", err)
+	}
+	return tokenString, nil
+}
+
+// ActorFromClaims returns the ID of the admin an impersonation token was issued to, if any
+func ActorFromClaims(claims jwt.MapClaims) (primitive.ObjectID, bool) {
+	act, ok := claims["act"].(map[string]interface{})
+	if !ok {
+		return primitive.NilObjectID, false
+	}
+	sub, _ := act["sub"].(string)
+	actorID, err := primitive.ObjectIDFromHex(sub)
+	if err != nil {
+		return primitive.NilObjectID, false
+	}
+	return actorID, true
+}
+
 // GenerateRefreshToken generates a new long-lived JWT Refresh Token
 func GenerateRefreshToken(userID primitive.ObjectID, secret string, lifetimeHours int) (string, error) {
 	claims := jwt.MapClaims{
@@ -0,0 +1,20 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// SignValue returns an HMAC-SHA256 signature of purpose+value, for links that must work without a login
+// (unsubscribe links, ...). The purpose keeps a signature for one use from being replayed for another.
+func SignValue(purpose, value, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose + ":" + value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignedValue reports whether signature was produced by SignValue for purpose and value
+func VerifySignedValue(purpose, value, signature, secret string) bool {
+	return hmac.Equal([]byte(SignValue(purpose, value, secret)), []byte(signature))
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// EditDistance returns the Levenshtein distance between a and b, counting a swap of two
+// neighbouring letters as one edit
+func EditDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// rows[i%3] holds the distances of the first i runes of s
+	rows := [3][]int{make([]int, len(t)+1), make([]int, len(t)+1), make([]int, len(t)+1)}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		cur, prev, prev2 := rows[i%3], rows[(i-1)%3], rows[(i+1)%3]
+		cur[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				cur[j] = min(cur[j], prev2[j-2]+1)
+			}
+		}
+	}
+	return rows[len(s)%3][len(t)]
+}
+
+// Highlight returns a snippet of text of about maxLen characters around the first word matching
+// one of terms, HTML-escaped, with matching words wrapped in <em>. Words match a term they start
+// with, ignoring case and a plural s. It returns "" when no word matches.
+func Highlight(text string, terms []string, maxLen int) string {
+	stems := make([]string, 0, len(terms))
+	for _, term := range terms {
+		term = strings.ToLower(term)
+		if len(term) > 3 {
+			term = strings.TrimSuffix(term, "s")
+		}
+		if term != "" {
+			stems = append(stems, term)
+		}
+	}
+	matches := func(word string) bool {
+		word = strings.ToLower(word)
+		for _, stem := range stems {
+			if strings.HasPrefix(word, stem) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Word boundaries as rune offsets
+	runes := []rune(text)
+	type span struct{ start, end int }
+	var words []span
+	for i := 0; i < len(runes); {
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+			i++
+		}
+		words = append(words, span{start, i})
+	}
+	first := -1
+	for _, w := range words {
+		if matches(string(runes[w.start:w.end])) {
+			first = w.start
+			break
+		}
+	}
+	if first < 0 {
+		return ""
+	}
+
+	// Centre the window on the first match, without cutting words
+	from, to := max(0, first-maxLen/3), min(len(runes), first-maxLen/3+maxLen)
+	if from > 0 {
+		for from < first && runes[from-1] != ' ' {
+			from++
+		}
+	}
+	for to < len(runes) && to > first && runes[to] != ' ' {
+		to--
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, w := range words {
+		if w.start < from || w.end > to || !matches(string(runes[w.start:w.end])) {
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[pos:w.start])))
+		b.WriteString("<em>" + html.EscapeString(string(runes[w.start:w.end])) + "</em>")
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}